@@ -0,0 +1,3 @@
+data "shoreline_action" "shared" {
+  name = "shared_action"
+}
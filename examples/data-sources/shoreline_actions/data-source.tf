@@ -0,0 +1,7 @@
+data "shoreline_actions" "all" {
+  name_regex = "^team_a_"
+}
+
+output "actions" {
+  value = data.shoreline_actions.all.names
+}
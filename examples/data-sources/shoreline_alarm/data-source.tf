@@ -0,0 +1,3 @@
+data "shoreline_alarm" "shared" {
+  name = "shared_alarm"
+}
@@ -0,0 +1,7 @@
+data "shoreline_alarms" "all" {
+  name_regex = "^team_a_"
+}
+
+output "alarms" {
+  value = data.shoreline_alarms.all.names
+}
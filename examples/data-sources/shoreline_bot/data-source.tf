@@ -0,0 +1,3 @@
+data "shoreline_bot" "shared" {
+  name = "shared_bot"
+}
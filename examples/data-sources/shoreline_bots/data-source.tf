@@ -0,0 +1,7 @@
+data "shoreline_bots" "all" {
+  name_regex = "^team_a_"
+}
+
+output "bots" {
+  value = data.shoreline_bots.all.names
+}
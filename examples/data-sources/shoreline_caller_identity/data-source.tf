@@ -0,0 +1,14 @@
+data "shoreline_caller_identity" "current" {
+  lifecycle {
+    postcondition {
+      condition     = self.customer == "acme"
+      error_message = "These credentials are for the '${self.customer}' customer, not 'acme'."
+    }
+  }
+}
+
+resource "shoreline_action" "restart_app" {
+  name    = "restart_app"
+  command = "`systemctl restart app`"
+  editors = [data.shoreline_caller_identity.current.user]
+}
@@ -0,0 +1,3 @@
+data "shoreline_circuit_breaker" "shared" {
+  name = "shared_circuit_breaker"
+}
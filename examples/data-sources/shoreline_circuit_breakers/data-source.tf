@@ -0,0 +1,7 @@
+data "shoreline_circuit_breakers" "all" {
+  name_regex = "^team_a_"
+}
+
+output "circuit_breakers" {
+  value = data.shoreline_circuit_breakers.all.names
+}
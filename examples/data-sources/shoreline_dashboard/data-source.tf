@@ -0,0 +1,3 @@
+data "shoreline_dashboard" "shared" {
+  name = "shared_dashboard"
+}
@@ -0,0 +1,7 @@
+data "shoreline_dashboards" "all" {
+  name_regex = "^team_a_"
+}
+
+output "dashboards" {
+  value = data.shoreline_dashboards.all.names
+}
@@ -0,0 +1,3 @@
+data "shoreline_file" "shared" {
+  name = "shared_file"
+}
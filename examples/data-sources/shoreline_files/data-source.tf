@@ -0,0 +1,7 @@
+data "shoreline_files" "all" {
+  name_regex = "^team_a_"
+}
+
+output "files" {
+  value = data.shoreline_files.all.names
+}
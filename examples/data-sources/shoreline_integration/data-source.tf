@@ -0,0 +1,3 @@
+data "shoreline_integration" "shared" {
+  name = "shared_integration"
+}
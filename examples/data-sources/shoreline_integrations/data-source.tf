@@ -0,0 +1,7 @@
+data "shoreline_integrations" "all" {
+  name_regex = "^team_a_"
+}
+
+output "integrations" {
+  value = data.shoreline_integrations.all.names
+}
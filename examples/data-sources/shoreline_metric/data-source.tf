@@ -0,0 +1,3 @@
+data "shoreline_metric" "shared" {
+  name = "shared_metric"
+}
@@ -0,0 +1,18 @@
+data "shoreline_metric_query" "payments_cpu" {
+  metric         = "cpu_usage"
+  resource_query = "hosts | app=\"payments\""
+  start          = "-7d"
+  step           = "1h"
+  fail_if_empty  = true
+}
+
+resource "shoreline_alarm" "payments_cpu_high" {
+  name            = "payments_cpu_high"
+  fire_query      = "(cpu_usage > ${data.shoreline_metric_query.payments_cpu.p95} | sum(5)) >= 2.0"
+  clear_query     = "(cpu_usage < ${data.shoreline_metric_query.payments_cpu.p95} | sum(5)) >= 2.0"
+  resource_query  = "hosts | app=\"payments\""
+  metric_name     = "cpu_usage"
+  condition_type  = "above"
+  condition_value = data.shoreline_metric_query.payments_cpu.p95
+  enabled         = true
+}
@@ -0,0 +1,7 @@
+data "shoreline_metrics" "all" {
+  name_regex = "^team_a_"
+}
+
+output "metrics" {
+  value = data.shoreline_metrics.all.names
+}
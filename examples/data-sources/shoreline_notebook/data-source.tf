@@ -0,0 +1,3 @@
+data "shoreline_notebook" "shared" {
+  name = "shared_notebook"
+}
@@ -0,0 +1,7 @@
+data "shoreline_notebooks" "all" {
+  name_regex = "^team_a_"
+}
+
+output "notebooks" {
+  value = data.shoreline_notebooks.all.names
+}
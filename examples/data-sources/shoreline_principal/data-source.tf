@@ -0,0 +1,3 @@
+data "shoreline_principal" "shared" {
+  name = "shared_principal"
+}
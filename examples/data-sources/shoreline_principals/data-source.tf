@@ -0,0 +1,7 @@
+data "shoreline_principals" "all" {
+  name_regex = "^team_a_"
+}
+
+output "principals" {
+  value = data.shoreline_principals.all.names
+}
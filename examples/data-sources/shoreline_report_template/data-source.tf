@@ -0,0 +1,3 @@
+data "shoreline_report_template" "shared" {
+  name = "shared_report_template"
+}
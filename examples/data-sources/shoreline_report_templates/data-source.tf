@@ -0,0 +1,7 @@
+data "shoreline_report_templates" "all" {
+  name_regex = "^team_a_"
+}
+
+output "report_templates" {
+  value = data.shoreline_report_templates.all.names
+}
@@ -0,0 +1,3 @@
+data "shoreline_resource" "shared" {
+  name = "shared_resource"
+}
@@ -0,0 +1,7 @@
+data "shoreline_resource_objects" "all" {
+  name_regex = "^team_a_"
+}
+
+output "resource_objects" {
+  value = data.shoreline_resource_objects.all.names
+}
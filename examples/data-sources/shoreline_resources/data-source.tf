@@ -0,0 +1,8 @@
+data "shoreline_resources" "payments_hosts" {
+  resource_query = "hosts | app=\"payments\""
+  fail_if_empty  = true
+}
+
+output "payments_hostnames" {
+  value = data.shoreline_resources.payments_hosts.names
+}
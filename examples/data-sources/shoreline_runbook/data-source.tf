@@ -0,0 +1,3 @@
+data "shoreline_runbook" "shared" {
+  name = "shared_runbook"
+}
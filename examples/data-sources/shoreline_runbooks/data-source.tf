@@ -0,0 +1,7 @@
+data "shoreline_runbooks" "all" {
+  name_regex = "^team_a_"
+}
+
+output "runbooks" {
+  value = data.shoreline_runbooks.all.names
+}
@@ -0,0 +1,3 @@
+data "shoreline_system_settings" "shared" {
+  name = "shared_system_settings"
+}
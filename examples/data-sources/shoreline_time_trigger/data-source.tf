@@ -0,0 +1,3 @@
+data "shoreline_time_trigger" "shared" {
+  name = "shared_time_trigger"
+}
@@ -0,0 +1,7 @@
+data "shoreline_time_triggers" "all" {
+  name_regex = "^team_a_"
+}
+
+output "time_triggers" {
+  value = data.shoreline_time_triggers.all.names
+}
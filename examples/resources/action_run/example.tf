@@ -0,0 +1,16 @@
+resource "shoreline_action_run" "reload_nginx" {
+  action_name    = shoreline_action.reload_config.name
+  resource_query = "hosts | app=\"payments\""
+  params = {
+    "service" = "nginx"
+  }
+  # reload whenever the config changes
+  triggers = {
+    "config_sha" = sha256(file("${path.module}/nginx.conf"))
+  }
+  fail_on_error = true
+}
+
+output "reload_nginx_failures" {
+  value = [for r in shoreline_action_run.reload_nginx.results : "${r.name}: ${r.stderr}" if r.exit_code != 0]
+}
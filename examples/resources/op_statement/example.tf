@@ -0,0 +1,11 @@
+# a backend feature that the provider doesn't support yet
+resource "shoreline_op_statement" "web_check" {
+  create_statement  = "action web_check = `curl -sf localhost:8080/health`"
+  update_statement  = "web_check.command = `curl -sf localhost:8080/health`"
+  destroy_statement = "delete web_check"
+  read_statement    = "web_check.command"
+}
+
+output "web_check_command" {
+  value = jsondecode(shoreline_op_statement.web_check.read_result)
+}
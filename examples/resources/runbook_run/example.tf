@@ -0,0 +1,16 @@
+resource "shoreline_runbook_run" "post_deploy_checks" {
+  runbook_name   = shoreline_runbook.post_deploy_checks.name
+  resource_query = "hosts | app=\"payments\""
+  params = {
+    "version" = var.payments_version
+  }
+  # run the checks again for every deploy
+  triggers = {
+    "version" = var.payments_version
+  }
+  timeout_sec = 900
+}
+
+output "post_deploy_checks" {
+  value = [for cell in shoreline_runbook_run.post_deploy_checks.cells : "${cell.name}: ${cell.status}"]
+}
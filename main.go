@@ -5,6 +5,7 @@ package main
 
 import (
 	"flag"
+	"log"
 
 	"shoreline.io/terraform/terraform-provider-shoreline/provider"
 
@@ -44,4 +45,9 @@ func main() {
 	}
 
 	plugin.Serve(opts)
+
+	// terraform is done with the provider
+	if err := provider.WriteMetricsReports(); err != nil {
+		log.Printf("[WARN] %s", err)
+	}
 }
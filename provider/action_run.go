@@ -0,0 +1,174 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// validateActionParams checks that the param names can be passed to an action (e.g. `reload_config(service = "nginx")`).
+func validateActionParams(val interface{}, key string) (warns []string, errs []error) {
+	params, isMap := val.(map[string]interface{})
+	if !isMap {
+		return
+	}
+	for name := range params {
+		if !opIdentRegex.MatchString(name) {
+			errs = append(errs, fmt.Errorf("%q names must be alphanumeric/underscore strings, starting with a letter or underscore, got: '%s'", key, name))
+		}
+	}
+	return
+}
+
+// ResourceShorelineActionRun runs an action on the resources of a resource query when it's created (e.g. a cache warmup
+// or config reload), and optionally fails the apply if it fails on any of them.
+// Changing any of its inputs (e.g. 'triggers') runs the action again.
+func ResourceShorelineActionRun() *schema.Resource {
+	return &schema.Resource{
+		Description:   "A run of an action on the resources of a resource query, e.g. a config reload. The action is run when the resource is created, and again whenever its inputs (e.g. `triggers`) change.",
+		CreateContext: resourceShorelineActionRunCreate,
+		ReadContext:   resourceShorelineActionRunRead,
+		UpdateContext: resourceShorelineActionRunRead,
+		DeleteContext: resourceShorelineActionRunDelete,
+		Schema: map[string]*schema.Schema{
+			"action_name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringMatch(opIdentRegex, "must be an alphanumeric/underscore string, starting with a letter or underscore"),
+				Description:  "The name of the action to run, e.g. `shoreline_action.reload_config.name`.",
+			},
+			"resource_query": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateResourceQuery,
+				Description:  "The resources to run the action on, e.g. `hosts | app=\"payments\"`.",
+			},
+			"params": {
+				Type:         schema.TypeMap,
+				Optional:     true,
+				ForceNew:     true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				ValidateFunc: validateActionParams,
+				Description:  "The values of the action's params.",
+			},
+			"triggers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Arbitrary values that run the action again when they change, e.g. a config file's hash.",
+			},
+			"fail_on_error": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Fail the apply if the action fails (exits non-zero) on any of the resources.",
+			},
+			"result": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The (JSON) output of the action, as returned by the API server.",
+			},
+			"results": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The outcome of the action on each resource.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name":      {Type: schema.TypeString, Computed: true, Description: "The name of the resource (e.g. the hostname)."},
+						"type":      {Type: schema.TypeString, Computed: true, Description: "The type of the resource (HOST, POD or CONTAINER)."},
+						"exit_code": {Type: schema.TypeInt, Computed: true, Description: "The exit status of the action on the resource."},
+						"stdout":    {Type: schema.TypeString, Computed: true, Description: "The standard output of the action."},
+						"stderr":    {Type: schema.TypeString, Computed: true, Description: "The standard error of the action."},
+					},
+				},
+			},
+			"failed_count": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The number of resources the action failed on.",
+			},
+		},
+	}
+}
+
+// parseActionRunResults returns the outcome of an action on each resource, i.e. the entries with a name
+// in the action's output (see ExtractResultEntries()), and a description of each failed one.
+func parseActionRunResults(js map[string]interface{}) ([]interface{}, []string) {
+	results := []interface{}{}
+	failed := []string{}
+	_, entries := ExtractResultEntries(js, ToKeyPath("name"))
+	for _, resJs := range entries {
+		result := map[string]interface{}{
+			"name":      CastToString(GetNestedValueOrDefault(resJs, ToKeyPath("name"), "")),
+			"type":      strings.ToUpper(CastToString(GetNestedValueOrDefault(resJs, ToKeyPath("type"), ""))),
+			"exit_code": int(CastToNumber(GetNestedValueOrDefault(resJs, ToKeyPath("exit_code"), 0))),
+			"stdout":    CastToString(GetNestedValueOrDefault(resJs, ToKeyPath("stdout"), "")),
+			"stderr":    CastToString(GetNestedValueOrDefault(resJs, ToKeyPath("stderr"), "")),
+		}
+		if result["exit_code"] != 0 {
+			failed = append(failed, fmt.Sprintf("%s (exit code %d)", result["name"], result["exit_code"]))
+		}
+		results = append(results, result)
+	}
+	return results, failed
+}
+
+func resourceShorelineActionRunCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient)
+	name := d.Get("action_name").(string)
+	ctx = withResourceLogging(ctx, client, "action_run", name)
+	logInfo(ctx, logCrud, fmt.Sprintf("Running action: '%s'", name))
+
+	// the same idempotency key for any retries, so that the action only runs once
+	runID := GetIdempotencyKey()
+	ctx = withIdempotencyKey(ctx, runID)
+	statement := opActionRunStatement(d.Get("resource_query").(string), name, d.Get("params").(map[string]interface{}))
+	result, err := runOpCommand(ctx, client, statement, true)
+	if err != nil {
+		return diag.Errorf("Failed to run action '%s': %s", name, annotateBackendError(err, statement, "action", name).Error())
+	}
+	js := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(result), &js); err != nil {
+		return diag.Errorf("Failed to parse json from command '%s': %s", statement, err.Error())
+	}
+	results, failed := parseActionRunResults(js)
+
+	d.SetId(runID)
+	d.Set("result", result)
+	d.Set("results", results)
+	d.Set("failed_count", len(failed))
+	logInfo(ctx, logCrud, fmt.Sprintf("Ran action '%s' on %d resources, %d failed", name, len(results), len(failed)))
+
+	if len(failed) > 0 && d.Get("fail_on_error").(bool) {
+		// saved (as tainted), so that the next apply runs it again
+		return diag.Errorf("Action '%s' failed on %d of %d resources: %s", name, len(failed), len(results), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// resourceShorelineActionRunRead keeps the state of a run, which is only read while it's created
+// (e.g. the run history may be purged, which shouldn't run it again).
+// Updates (e.g. of 'fail_on_error') don't run it again either.
+func resourceShorelineActionRunRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return nil
+}
+
+// resourceShorelineActionRunDelete forgets a run, its history is kept by the backend.
+func resourceShorelineActionRunDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient)
+	ctx = withResourceLogging(ctx, client, "action_run", d.Get("action_name").(string))
+	logInfo(ctx, logCrud, fmt.Sprintf("Removing run %s from the state", d.Id()))
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider/mockbackend"
+)
+
+// testActionRun creates a shoreline_action_run against the payments hosts, returning its state and diagnostics.
+func testActionRun(t *testing.T, raw map[string]interface{}) (*schema.ResourceData, diag.Diagnostics) {
+	p, meta := testMockProvider(t)
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-1", Type: "HOST", Tags: map[string]string{"app": "payments"}})
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-2", Type: "HOST", Tags: map[string]string{"app": "payments"}})
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-3", Type: "HOST", Tags: map[string]string{"app": "books"}})
+	t.Cleanup(func() {
+		mockServer.ResetResources()
+		mockServer.ResetActionRuns()
+	})
+	res := p.ResourcesMap["shoreline_action_run"]
+	d := schema.TestResourceDataRaw(t, res.Schema, raw)
+	return d, res.CreateContext(context.Background(), d, meta)
+}
+
+func TestMockActionRun(t *testing.T) {
+	name := RandomAlphaPrefix(5) + "_reload"
+	mockServer.PutObject("action", name, map[string]interface{}{"enabled": true})
+	mockServer.PutActionResult(name, "ip-10-0-0-1", mockbackend.ActionResult{Stdout: "reloaded"})
+
+	d, diags := testActionRun(t, map[string]interface{}{
+		"action_name":    name,
+		"resource_query": `hosts | app="payments"`,
+		"params":         map[string]interface{}{"service": `nginx"; delete ` + name, "force": "true"},
+		"triggers":       map[string]interface{}{"config_sha": "abc123"},
+	})
+	if diags.HasError() {
+		t.Fatalf("Failed to run the action: %+v", diags)
+	}
+	if d.Id() == "" || d.Get("failed_count") != 0 || d.Get("results.#") != 2 {
+		t.Errorf("Unexpected run: %s, %v, %+v", d.Id(), d.Get("failed_count"), d.Get("results"))
+	}
+	if !strings.Contains(d.Get("result").(string), `"reloaded"`) {
+		t.Errorf("Expected the action's output, got: %s", d.Get("result"))
+	}
+	if d.Get("results.0.name") != "ip-10-0-0-1" || d.Get("results.0.type") != "HOST" || d.Get("results.0.stdout") != "reloaded" || d.Get("results.1.exit_code") != 0 {
+		t.Errorf("Unexpected results: %+v", d.Get("results"))
+	}
+	want := `hosts | app="payments" | ` + name + `(force = "true", service = "nginx\"; delete ` + name + `")`
+	if !containsString(mockServer.Statements(), want) {
+		t.Errorf("Expected the statement %s, got: %v", want, mockServer.Statements())
+	}
+	if _, _, found := mockServer.Object(name); !found {
+		t.Errorf("Expected the action to still exist")
+	}
+}
+
+func TestMockActionRunFailures(t *testing.T) {
+	name := RandomAlphaPrefix(5) + "_reload"
+	mockServer.PutObject("action", name, map[string]interface{}{"enabled": true})
+	mockServer.PutActionResult(name, "ip-10-0-0-2", mockbackend.ActionResult{ExitCode: 3, Stderr: "nginx: config test failed"})
+	raw := map[string]interface{}{"action_name": name, "resource_query": `hosts | app="payments"`}
+
+	d, diags := testActionRun(t, raw)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "failed on 1 of 2 resources: ip-10-0-0-2 (exit code 3)") {
+		t.Errorf("Expected the run to fail, got: %+v", diags)
+	}
+	// kept (as tainted), so that the next apply runs it again
+	if d.Id() == "" || d.Get("failed_count") != 1 || d.Get("results.1.stderr") != "nginx: config test failed" {
+		t.Errorf("Expected the failed run in the state, got: %s, %+v", d.Id(), d.Get("results"))
+	}
+
+	raw["fail_on_error"] = false
+	d, diags = testActionRun(t, raw)
+	if diags.HasError() || d.Get("failed_count") != 1 || d.Get("results.1.exit_code") != 3 {
+		t.Errorf("Expected the failures to be recorded, got: %+v, %+v", diags, d.Get("results"))
+	}
+}
+
+func TestMockActionRunDisabled(t *testing.T) {
+	name := RandomAlphaPrefix(5) + "_reload"
+	mockServer.PutObject("action", name, map[string]interface{}{"enabled": false})
+	d, diags := testActionRun(t, map[string]interface{}{"action_name": name, "resource_query": "hosts"})
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "is disabled") || d.Id() != "" {
+		t.Errorf("Expected a disabled action to fail, got: %+v", diags)
+	}
+}
+
+func TestActionRunSchema(t *testing.T) {
+	sch := ResourceShorelineActionRun().Schema
+	for key, s := range sch {
+		// only fail_on_error can change without running the action again
+		if s.Computed || key == "fail_on_error" {
+			if s.ForceNew {
+				t.Errorf("Expected %s not to force a new run", key)
+			}
+		} else if !s.ForceNew {
+			t.Errorf("Expected %s to force a new run", key)
+		}
+	}
+	if _, errs := sch["params"].ValidateFunc(map[string]interface{}{"ok_1": "a", "not ok": "b"}, "params"); len(errs) != 1 || !strings.Contains(errs[0].Error(), "'not ok'") {
+		t.Errorf("Expected an invalid param name to fail, got: %v", errs)
+	}
+}
+
+func TestMockActionRunRetriedOnce(t *testing.T) {
+	p, meta := testMockProviderWithConfig(t, map[string]interface{}{"retries": 3})
+	testFastRetries(t)
+	name := RandomAlphaPrefix(5) + "_reload"
+	mockServer.PutObject("action", name, map[string]interface{}{"enabled": true})
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-1", Type: "HOST", Tags: map[string]string{"app": "payments"}})
+	t.Cleanup(func() {
+		mockServer.ResetResources()
+		mockServer.ResetActionRuns()
+		mockServer.ResetFaults()
+	})
+	mockServer.ResetRequests()
+	// the action runs, but its response is lost
+	mockServer.InjectFault(mockbackend.Fault{Path: "/v1/execute", Status: 504, Body: "gateway timeout", Handled: true})
+
+	res := p.ResourcesMap["shoreline_action_run"]
+	d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{"action_name": name, "resource_query": "hosts"})
+	if diags := res.CreateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("Failed to run the action: %+v", diags)
+	}
+	if runs := mockServer.ActionRuns(); runs != 1 || d.Get("results.#") != 1 {
+		t.Errorf("Expected the retried action to run once, got %d runs: %+v", runs, d.Get("results"))
+	}
+	keys := map[string]bool{}
+	for _, req := range mockServer.Requests() {
+		if strings.HasPrefix(req.Statement, "hosts | "+name) {
+			keys[req.IdempotencyKey] = true
+		}
+	}
+	if len(keys) != 1 || keys[""] || !keys[d.Id()] {
+		t.Errorf("Expected the retries to share the run's idempotency key, got: %v", keys)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// how long to wait for another process (e.g. a parallel terraform run) to release a file (e.g. the auth file)
+	fileLockTimeout = 10 * time.Second
+	// a lock older than this was left behind by a crashed process
+	fileLockStale = 60 * time.Second
+)
+
+// lockFile takes an exclusive lock on a file shared with other processes (e.g. the auth file),
+// via a (portable) lock file next to it.
+// The returned function releases the lock.
+func lockFile(filename string) (func(), error) {
+	lockName := filename + ".lock"
+	deadline := time.Now().Add(fileLockTimeout)
+	for {
+		f, err := os.OpenFile(lockName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockName) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(lockName); statErr == nil && time.Since(info.ModTime()) > fileLockStale {
+			os.Remove(lockName)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Timed out waiting for the file lock '%s'", lockName)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// UpdateAuthFileToken replaces the refresh token for 'url' in the auth file (.ops_auth.yaml),
+// e.g. after the API server rotated it.
+// The token is only replaced if it still matches 'oldToken', so that a newer token
+// written by a concurrent run isn't clobbered. Returns whether the file was changed.
+func UpdateAuthFileToken(filename string, url string, oldToken string, newToken string) (bool, error) {
+	unlock, err := lockFile(filename)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+	// MapSlice keeps the key order (and any other keys) of the file intact
+	config := yaml.MapSlice{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return false, fmt.Errorf("Couldn't parse auth file '%s': %s", filename, err.Error())
+	}
+
+	changed := replaceAuthToken(config, url, oldToken, newToken)
+	for _, item := range config {
+		if item.Key != "Auth" {
+			continue
+		}
+		entries, isArr := item.Value.([]interface{})
+		if !isArr {
+			continue
+		}
+		for _, entry := range entries {
+			if entryMap, isMap := entry.(yaml.MapSlice); isMap && replaceAuthToken(entryMap, url, oldToken, newToken) {
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return false, err
+	}
+	return true, writeFileAtomic(filename, out, info.Mode().Perm())
+}
+
+// replaceAuthToken updates the 'Token' of a {Url, Token} map (in place), if it's for 'url' and 'oldToken'.
+func replaceAuthToken(entry yaml.MapSlice, url string, oldToken string, newToken string) bool {
+	urlIdx, tokenIdx := -1, -1
+	for i, item := range entry {
+		switch item.Key {
+		case "Url":
+			urlIdx = i
+		case "Token":
+			tokenIdx = i
+		}
+	}
+	if urlIdx < 0 || tokenIdx < 0 || entry[urlIdx].Value != url || entry[tokenIdx].Value != oldToken {
+		return false
+	}
+	entry[tokenIdx].Value = newToken
+	return true
+}
+
+// writeFileAtomic writes to a temp file in the same directory and renames it over the target,
+// so that readers never see a partially written file.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op after a successful rename
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, filename)
+}
@@ -0,0 +1,189 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type testAuthFile struct {
+	Url    string `yaml:"Url"`
+	Token  string `yaml:"Token"`
+	Editor string `yaml:"Editor"`
+	Auth   []struct {
+		Url   string `yaml:"Url"`
+		Token string `yaml:"Token"`
+	} `yaml:"Auth"`
+}
+
+func testWriteAuthFile(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), ".ops_auth.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write auth file: %s", err)
+	}
+	return filename
+}
+
+func testReadAuthFile(t *testing.T, filename string) testAuthFile {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read auth file: %s", err)
+	}
+	conf := testAuthFile{}
+	if err := yaml.Unmarshal(data, &conf); err != nil {
+		t.Fatalf("Failed to parse auth file: %s", err)
+	}
+	return conf
+}
+
+const testAuthFileContent = `Url: https://a.shoreline.io
+Token: old_a
+Editor: vim
+Auth:
+- Url: https://a.shoreline.io
+  Token: old_a
+- Url: https://b.shoreline.io
+  Token: old_b
+`
+
+func TestUpdateAuthFileToken(t *testing.T) {
+	filename := testWriteAuthFile(t, testAuthFileContent)
+
+	changed, err := UpdateAuthFileToken(filename, "https://b.shoreline.io", "old_b", "new_b")
+	if err != nil || !changed {
+		t.Fatalf("Expected the token to be updated, got changed: %v, err: %v", changed, err)
+	}
+	conf := testReadAuthFile(t, filename)
+	if conf.Auth[1].Token != "new_b" || conf.Auth[0].Token != "old_a" || conf.Token != "old_a" {
+		t.Errorf("Unexpected auth file after update: %+v", conf)
+	}
+	if conf.Editor != "vim" {
+		t.Errorf("Expected other settings to be kept, got: %+v", conf)
+	}
+
+	// the default (top-level) entry is updated along with its 'Auth' entry
+	if changed, err := UpdateAuthFileToken(filename, "https://a.shoreline.io", "old_a", "new_a"); err != nil || !changed {
+		t.Fatalf("Expected the token to be updated, got changed: %v, err: %v", changed, err)
+	}
+	conf = testReadAuthFile(t, filename)
+	if conf.Auth[0].Token != "new_a" || conf.Token != "new_a" {
+		t.Errorf("Expected the default and 'Auth' entries to be updated, got: %+v", conf)
+	}
+
+	if info, _ := os.Stat(filename); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the file permissions to be kept, got %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(filename))
+	if len(entries) != 1 {
+		t.Errorf("Expected no leftover temp or lock files, got %d files", len(entries))
+	}
+}
+
+func TestUpdateAuthFileTokenMismatch(t *testing.T) {
+	filename := testWriteAuthFile(t, testAuthFileContent)
+
+	// e.g. a concurrent run already saved a newer token
+	changed, err := UpdateAuthFileToken(filename, "https://b.shoreline.io", "stale_b", "new_b")
+	if err != nil || changed {
+		t.Errorf("Expected no update for a different token, got changed: %v, err: %v", changed, err)
+	}
+	changed, err = UpdateAuthFileToken(filename, "https://c.shoreline.io", "old_b", "new_b")
+	if err != nil || changed {
+		t.Errorf("Expected no update for an unknown url, got changed: %v, err: %v", changed, err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != testAuthFileContent {
+		t.Errorf("Expected the auth file to be untouched, got:\n%s", data)
+	}
+}
+
+func TestUpdateAuthFileTokenConcurrent(t *testing.T) {
+	content := "Auth:\n"
+	for i := 0; i < 10; i++ {
+		content += fmt.Sprintf("- Url: https://%d.shoreline.io\n  Token: old\n", i)
+	}
+	filename := testWriteAuthFile(t, content)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := UpdateAuthFileToken(filename, fmt.Sprintf("https://%d.shoreline.io", i), "old", fmt.Sprintf("new_%d", i)); err != nil {
+				t.Errorf("Failed to update the auth file: %s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	conf := testReadAuthFile(t, filename)
+	for i, entry := range conf.Auth {
+		if entry.Token != fmt.Sprintf("new_%d", i) {
+			t.Errorf("Expected every update to be kept, got: %+v", conf.Auth)
+			break
+		}
+	}
+}
+
+func TestUpdateAuthFileTokenStaleLock(t *testing.T) {
+	filename := testWriteAuthFile(t, testAuthFileContent)
+	lockName := filename + ".lock"
+	os.WriteFile(lockName, nil, 0600)
+	old := time.Now().Add(-2 * fileLockStale)
+	os.Chtimes(lockName, old, old)
+
+	if changed, err := UpdateAuthFileToken(filename, "https://b.shoreline.io", "old_b", "new_b"); err != nil || !changed {
+		t.Errorf("Expected a stale lock to be broken, got changed: %v, err: %v", changed, err)
+	}
+}
+
+func TestMockRotatedTokenSaved(t *testing.T) {
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	// differs from the (rotated) tokens the mock backend hands out
+	token := mockServer.NewToken("refresh", "test_customer", "test_user@shoreline.io", time.Now().Add(48*time.Hour))
+	client.opts.Token = token
+	filename := testWriteAuthFile(t, fmt.Sprintf("Auth:\n- Url: %s\n  Token: %s\n", client.opts.Url, token))
+	client.opts.AuthFile = filename
+	client.auth = nil
+	mockServer.ResetRequests()
+
+	if _, err := runOpCommand(context.Background(), client, "backend_version", false); err != nil {
+		t.Fatalf("Failed to run command: %s", err)
+	}
+	rotated := testReadAuthFile(t, filename).Auth[0].Token
+	if rotated == token || rotated != client.auth.Tokens.ApiToken() {
+		t.Fatalf("Expected the rotated refresh token to be saved, got '%s'", rotated)
+	}
+
+	// and used for the next refresh
+	client.auth.Tokens.Invalidate(mustAccessToken(t, client))
+	if _, err := runOpCommand(context.Background(), client, "backend_version", false); err != nil {
+		t.Fatalf("Failed to run command with the rotated token: %s", err)
+	}
+	refreshes := []string{}
+	for _, req := range mockServer.Requests() {
+		if req.Path == "/v1/token/refresh" {
+			refreshes = append(refreshes, req.Authorization)
+		}
+	}
+	if len(refreshes) != 2 || refreshes[1] != "Bearer "+rotated {
+		t.Errorf("Expected the second refresh to use the rotated token, got: %v", refreshes)
+	}
+}
+
+func mustAccessToken(t *testing.T, client *apiClient) string {
+	token, err := client.auth.Tokens.AccessToken(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get an access token: %s", err)
+	}
+	return token
+}
@@ -0,0 +1,250 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kinds of backend errors, for use with errors.Is(), e.g. errors.Is(err, ErrNotFound).
+var (
+	ErrNotFound         = errors.New("object not found")
+	ErrAlreadyExists    = errors.New("object already exists")
+	ErrValidation       = errors.New("invalid request")
+	ErrUnauthorized     = errors.New("not authenticated")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// BackendError is a failure reported by the API server, either as an HTTP error status,
+// or in the body of a successful response (e.g. a failed 'define_action').
+type BackendError struct {
+	StatusCode int    // HTTP status, or 0 for errors reported in the response body
+	Code       string // backend error code, if any
+	ObjectType string
+	Name       string
+	Statement  string // the op statement that failed
+	Message    string
+	// one of the Err* kinds above, or nil if unknown
+	Kind error
+	// the underlying (e.g. *HttpStatusError) error, if any
+	err error
+}
+
+func (e *BackendError) Error() string {
+	if e.StatusCode == 0 {
+		// same format as the CLI
+		return fmt.Sprintf("ERROR: %s.\n", e.Message)
+	}
+	return e.Message
+}
+
+func (e *BackendError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+func (e *BackendError) Unwrap() error {
+	return e.err
+}
+
+// backend (gRPC style) error codes
+var backendErrorCodes = map[string]error{
+	"not_found":           ErrNotFound,
+	"already_exists":      ErrAlreadyExists,
+	"invalid_argument":    ErrValidation,
+	"failed_precondition": ErrValidation,
+	"validation":          ErrValidation,
+	"unauthenticated":     ErrUnauthorized,
+	"permission_denied":   ErrPermissionDenied,
+}
+
+var backendErrorStatuses = map[int]error{
+	400: ErrValidation,
+	401: ErrUnauthorized,
+	403: ErrPermissionDenied,
+	// not 404: that's also what a wrong URL (or api_base_path) gets, so missing objects are only
+	// recognized by their error code or message
+	409: ErrAlreadyExists,
+	422: ErrValidation,
+}
+
+// messages for backends that don't send an error code
+var (
+	notFoundRegex      = regexp.MustCompile(`(?i)(\bis not defined\b|\bdoes not exist\b|\bnot found\b|\bno such\b)`)
+	alreadyExistsRegex = regexp.MustCompile(`(?i)\balready exists\b`)
+	permissionRegex    = regexp.MustCompile(`(?i)(\bpermission denied\b|\bnot authorized\b|\bforbidden\b|\binsufficient permissions?\b)`)
+)
+
+// backendErrorKind classifies an error by its code, then its HTTP status, and finally its message.
+func backendErrorKind(statusCode int, code string, message string) error {
+	if kind, found := backendErrorCodes[strings.ToLower(code)]; found {
+		return kind
+	}
+	if kind, found := backendErrorStatuses[statusCode]; found {
+		return kind
+	}
+	switch {
+	case alreadyExistsRegex.MatchString(message):
+		return ErrAlreadyExists
+	case notFoundRegex.MatchString(message):
+		return ErrNotFound
+	case permissionRegex.MatchString(message):
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// newHttpBackendError converts a non-200 response into a BackendError.
+// The body is either JSON ({"error": "..."} or {"error": {"code": ..., "message": ...}}) or plain text.
+func newHttpBackendError(statusErr *HttpStatusError, statement string) *BackendError {
+	code, message := "", ""
+	js := map[string]interface{}{}
+	isJson := json.Unmarshal([]byte(statusErr.Message), &js) == nil
+	if isJson {
+		switch errJs := js["error"].(type) {
+		case string:
+			message = errJs
+		case map[string]interface{}:
+			code, _ = errJs["code"].(string)
+			message, _ = errJs["message"].(string)
+		}
+		if msg, isStr := js["message"].(string); isStr && message == "" {
+			message = msg
+		}
+		if c, isStr := js["code"].(string); isStr && code == "" {
+			code = c
+		}
+	}
+	if message == "" {
+		message = statusErr.Message
+	}
+	// nested (e.g. gRPC) errors are escaped inside the outer message
+	message = GetInnerErrorStr(message)
+	// a plain text body may come from e.g. a proxy ("404 page not found"), rather than the backend
+	kindMessage := ""
+	if isJson {
+		kindMessage = message
+	}
+	return &BackendError{
+		StatusCode: statusErr.StatusCode,
+		Code:       code,
+		Statement:  statement,
+		Message:    message,
+		Kind:       backendErrorKind(statusErr.StatusCode, code, kindMessage),
+		err:        statusErr,
+	}
+}
+
+// resultKeyRegex matches the keys of update results, e.g. "define_action" or "update_time_trigger"
+var resultKeyRegex = regexp.MustCompile(`^(define|delete|update)_(\w+)$`)
+
+// CheckUpdateResult returns the error (if any) from the response to a define/update/delete statement.
+func CheckUpdateResult(result string) error {
+	js := map[string]interface{}{}
+	err := json.Unmarshal([]byte(result), &js)
+	if err != nil {
+		return fmt.Errorf("Failed parse json result from resource update %s", err.Error())
+	}
+
+	keys := []string{}
+	for key := range js {
+		if resultKeyRegex.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		typ := resultKeyRegex.FindStringSubmatch(key)[2]
+		errJs, isMap := GetNestedValueOrDefault(js, ToKeyPath(key+".error"), nil).(map[string]interface{})
+		if !isMap {
+			// success ...
+			return nil
+		}
+		message := ""
+		if errJs["message"] != nil {
+			message = CastToString(errJs["message"])
+		}
+		code, _ := errJs["code"].(string)
+		kind := error(nil)
+		if message == "" {
+			// e.g. notebooks report their (cell) validation errors separately
+			msgs := []string{}
+			if ve, isArray := errJs["validation_errors"].([]interface{}); isArray {
+				for _, v := range ve {
+					if msg, isStr := GetNestedValueOrDefault(v, ToKeyPath("message"), nil).(string); isStr && msg != "" {
+						msgs = append(msgs, msg)
+					}
+				}
+			}
+			if len(msgs) == 0 {
+				return nil
+			}
+			message = strings.Join(msgs, "\n")
+			kind = ErrValidation
+		}
+		message = GetInnerErrorStr(message)
+		if kind == nil {
+			kind = backendErrorKind(0, code, message)
+		}
+		name, _ := GetNestedValueOrDefault(js, ToKeyPath(key+".name"), "").(string)
+		return &BackendError{Code: code, ObjectType: typ, Name: name, Message: message, Kind: kind}
+	}
+
+	// errors for the statement as a whole (e.g. syntax or undefined symbols)
+	if stmtErrors, isArray := js["execute_statement_errors"].([]interface{}); isArray {
+		msgs := []string{}
+		for _, entry := range stmtErrors {
+			errorsArr, _ := GetNestedValueOrDefault(entry, ToKeyPath("errors"), nil).([]interface{})
+			for _, msg := range errorsArr {
+				if msgStr, isStr := msg.(string); isStr {
+					msgs = append(msgs, msgStr)
+				}
+			}
+		}
+		if len(msgs) > 0 {
+			message := strings.Join(msgs, "\n")
+			return &BackendError{Message: message, Kind: backendErrorKind(0, "", message)}
+		}
+	}
+
+	return nil
+}
+
+// attributeFailureRegex matches attribute values that are really errors,
+// e.g. "get file attribute failed: field does not exist"
+var attributeFailureRegex = regexp.MustCompile(`(?:get (\w+) attribute )?failed: (.*)$`)
+
+// attributeValueError returns an error if an attribute read returned a failure (as its value).
+func attributeValueError(name string, key string, val interface{}) error {
+	str, isStr := val.(string)
+	if !isStr {
+		return nil
+	}
+	m := attributeFailureRegex.FindStringSubmatch(str)
+	if m == nil {
+		return nil
+	}
+	return &BackendError{ObjectType: m[1], Name: name, Statement: name + "." + key, Message: m[2], Kind: backendErrorKind(0, "", m[2])}
+}
+
+// annotateBackendError adds the statement and object (if not already known) to a BackendError.
+func annotateBackendError(err error, statement string, typ string, name string) error {
+	var backendErr *BackendError
+	if errors.As(err, &backendErr) {
+		if backendErr.Statement == "" {
+			backendErr.Statement = statement
+		}
+		if backendErr.ObjectType == "" {
+			backendErr.ObjectType = typ
+		}
+		if backendErr.Name == "" {
+			backendErr.Name = name
+		}
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider/mockbackend"
+)
+
+func TestMockBackendErrorHttpStatus(t *testing.T) {
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	tests := []struct {
+		status int
+		body   string
+		kind   error
+		code   string
+		msg    string
+	}{
+		{403, `{"error": "user may not update actions"}`, ErrPermissionDenied, "", "user may not update actions"},
+		{400, `{"error": {"code": "NOT_FOUND", "message": "no such action"}}`, ErrNotFound, "NOT_FOUND", "no such action"},
+		{409, "conflict", ErrAlreadyExists, "", "conflict"},
+		// an endpoint (e.g. a wrong api_base_path) that doesn't exist isn't a missing object
+		{404, "404 page not found", nil, "", "404 page not found"},
+		{404, `{"error": {"code": "NOT_FOUND", "message": "no such action"}}`, ErrNotFound, "NOT_FOUND", "no such action"},
+		{404, `{"error": "action 'a1' does not exist"}`, ErrNotFound, "", "action 'a1' does not exist"},
+		{418, "teapot", nil, "", "teapot"},
+	}
+	for _, tt := range tests {
+		mockServer.InjectFault(mockbackend.Fault{Path: "/v1/execute", Status: tt.status, Body: tt.body})
+		_, err := runOpCommand(context.Background(), client, "backend_version", false)
+		var backendErr *BackendError
+		if !errors.As(err, &backendErr) {
+			t.Fatalf("%d: Expected a BackendError, got: %v", tt.status, err)
+		}
+		if backendErr.StatusCode != tt.status || backendErr.Code != tt.code || backendErr.Message != tt.msg || backendErr.Statement != "backend_version" {
+			t.Errorf("%d: Unexpected error fields: %+v", tt.status, backendErr)
+		}
+		if backendErr.Kind != tt.kind {
+			t.Errorf("%d: Expected kind %v, got %v", tt.status, tt.kind, backendErr.Kind)
+		}
+		var statusErr *HttpStatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.status {
+			t.Errorf("%d: Expected the HTTP status error to be wrapped", tt.status)
+		}
+	}
+	mockServer.ResetFaults()
+}
+
+func TestMockBackendErrorNotRetried(t *testing.T) {
+	_, meta := testMockProviderWithConfig(t, map[string]interface{}{"retries": 3})
+	client := meta.(*apiClient)
+	testFastRetries(t)
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.ResetRequests()
+
+	_, err := runOpCommand(context.Background(), client, "delete "+name, true)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected a not-found error, got: %v", err)
+	}
+	var backendErr *BackendError
+	if errors.As(err, &backendErr); backendErr.Statement != "delete "+name {
+		t.Errorf("Expected the failed statement in the error, got: %+v", backendErr)
+	}
+	if count := testMockExecuteCount(); count != 1 {
+		t.Errorf("Expected a not-found error not to be retried, got %d execute calls", count)
+	}
+}
+
+func TestMockCreateAlreadyExists(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.PutObject("action", name, map[string]interface{}{"command": "`hostname`"})
+
+	res := p.ResourcesMap["shoreline_action"]
+	d := res.TestResourceData()
+	d.Set("name", name)
+	d.Set("command", "`hostname`")
+	diags := res.CreateContext(context.Background(), d, meta)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "already exists") || !strings.Contains(diags[0].Summary, "terraform import") {
+		t.Errorf("Expected an 'already exists' error, got: %+v", diags)
+	}
+}
+
+func TestMockDeleteMissingObject(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_action"
+	d := testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":    name,
+		"command": "`hostname`",
+	})
+	// deleted outside of terraform
+	mockServer.DeleteObject(name)
+
+	if diags := p.ResourcesMap["shoreline_action"].DeleteContext(context.Background(), d, meta); diags.HasError() {
+		t.Errorf("Expected deleting a missing object to succeed, got: %+v", diags)
+	}
+}
+
+func TestMockDeleteEndpointNotFound(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_action"
+	d := testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":    name,
+		"command": "`hostname`",
+	})
+	mockServer.InjectFault(mockbackend.Fault{Path: "/v1/execute", Status: 404, Body: "404 page not found"})
+	defer mockServer.ResetFaults()
+
+	if diags := p.ResourcesMap["shoreline_action"].DeleteContext(context.Background(), d, meta); !diags.HasError() {
+		t.Errorf("Expected deleting through a missing endpoint to fail, rather than forget the object")
+	}
+}
+
+func TestAttributeValueError(t *testing.T) {
+	err := attributeValueError("f1", "file_data", "get file attribute failed: field does not exist")
+	var backendErr *BackendError
+	if !errors.As(err, &backendErr) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected a not-found BackendError, got: %v", err)
+	}
+	if backendErr.ObjectType != "file" || backendErr.Name != "f1" || backendErr.Statement != "f1.file_data" {
+		t.Errorf("Unexpected error fields: %+v", backendErr)
+	}
+	if err := attributeValueError("f1", "file_data", "https://bucket/f1"); err != nil {
+		t.Errorf("Expected no error for a valid value, got: %s", err)
+	}
+}
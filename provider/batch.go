@@ -0,0 +1,237 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// opBatch collects the op statements that update a single object, so that they are sent
+// to the API server in one request, instead of one round trip per attribute.
+type opBatch struct {
+	typ  string
+	name string
+	ops  []batchOp
+	// updates an existing object, so the applied updates are rolled back if one fails (see rollback())
+	isUpdate bool
+	// re-enable the object after a rollback (as the backend disables it on any attribute change)
+	reenable bool
+}
+
+type batchOp struct {
+	statement string
+	attr      string // terraform attribute that the statement came from, for error attribution
+	field     string // object field being set (differs from 'attr' for compound/renamed fields)
+	fallback  string // statement to run if this one fails (e.g. a deprecated field that was renamed)
+	action    string // for enable/disable statements
+	// statements restoring the previous value (of 'field', or the fallback's), for rollbacks, "" if unknown
+	undo         string
+	fallbackUndo string
+}
+
+// errBatchNotRun is the error of the statements after a failed one, which aren't sent.
+var errBatchNotRun = errors.New("not run, as an earlier statement failed")
+
+func newOpBatch(typ string, name string) *opBatch {
+	return &opBatch{typ: typ, name: name}
+}
+
+func (batch *opBatch) add(op batchOp) {
+	batch.ops = append(batch.ops, op)
+}
+
+// flush sends the collected statements, and returns an error diagnostic for each one that failed.
+// The field updates are sent first (with their fallbacks), and enable/disable only if they all succeeded,
+// as the backend disables the object on any attribute change. If a field update of an existing object fails,
+// the ones already applied are rolled back, so that it isn't left half updated.
+func (batch *opBatch) flush(ctx context.Context, client *apiClient) diag.Diagnostics {
+	if len(batch.ops) == 0 {
+		return nil
+	}
+	fields := []batchOp{}
+	actions := []batchOp{}
+	for _, op := range batch.ops {
+		if op.action != "" {
+			actions = append(actions, op)
+		} else {
+			fields = append(fields, op)
+		}
+	}
+	batch.ops = nil
+
+	applied, diags := batch.run(ctx, client, fields)
+	if diags.HasError() {
+		for _, op := range actions {
+			logWarn(ctx, logCrud, fmt.Sprintf("Not running '%s' on %s %s, as its fields failed to update", op.action, batch.typ, batch.name), map[string]interface{}{logFieldStatement: op.statement})
+		}
+		return append(diags, batch.rollback(ctx, client, applied)...)
+	}
+	_, diags = batch.run(ctx, client, actions)
+	return diags
+}
+
+// run sends the statements in order, in as few requests as possible (see runOpCommands()), and stops at the
+// first failure. A statement with a fallback ends its request, so that the fallback (if needed) runs in order.
+// Returns the statements that were applied (with their undo statements), and the diagnostics of the failed ones.
+func (batch *opBatch) run(ctx context.Context, client *apiClient, ops []batchOp) ([]batchOp, diag.Diagnostics) {
+	var applied []batchOp
+	var diags diag.Diagnostics
+	next := 0
+	for next < len(ops) && !diags.HasError() {
+		start := next
+		for next++; next < len(ops) && ops[next-1].fallback == ""; next++ {
+		}
+		chunk := ops[start:next]
+
+		statements := make([]string, len(chunk))
+		for i, op := range chunk {
+			statements[i] = op.statement
+		}
+		_, errs := runOpCommands(ctx, client, statements)
+
+		for i, op := range chunk {
+			err := errs[i]
+			if errors.Is(err, errBatchNotRun) {
+				next = start + i
+				break
+			}
+			if err != nil && op.fallback != "" {
+				logDebug(ctx, logCrud, fmt.Sprintf("Set deprecated/renamed field : %s: '%s'.'%s' op:'%s'", batch.typ, batch.name, op.field, op.fallback))
+				_, err = runOpCommand(ctx, client, op.fallback, true)
+				op.undo = op.fallbackUndo
+			}
+			if err == nil {
+				applied = append(applied, op)
+				continue
+			}
+			// the statements after it in the same request may have been applied, see rollback()
+			annotateBackendError(err, "", batch.typ, batch.name)
+			summary := fmt.Sprintf("Failed to update %s %s.%s: %s", batch.typ, batch.name, op.field, err.Error())
+			if op.action != "" {
+				summary = fmt.Sprintf("Failed to %s %s: %s", op.action, batch.typ, err.Error())
+			}
+			logError(ctx, logCrud, summary, map[string]interface{}{logFieldStatement: op.statement})
+			diagnostic := diag.Diagnostic{Severity: diag.Error, Summary: summary}
+			if op.attr != "" {
+				diagnostic.AttributePath = cty.GetAttrPath(op.attr)
+			}
+			diags = append(diags, diagnostic)
+		}
+	}
+	for _, op := range ops[next:] {
+		logWarn(ctx, logCrud, fmt.Sprintf("Not updating %s %s.%s, as an earlier update failed", batch.typ, batch.name, op.field), map[string]interface{}{logFieldStatement: op.statement})
+	}
+	return applied, diags
+}
+
+// rollback restores the fields that were applied before a failure (in reverse order, one at a time, so that a
+// failure doesn't stop the rest), and re-enables the object if it was enabled. Returns an error diagnostic for
+// each field that couldn't be restored, as the object is then left partially updated.
+func (batch *opBatch) rollback(ctx context.Context, client *apiClient, applied []batchOp) diag.Diagnostics {
+	if !batch.isUpdate || len(applied) == 0 {
+		return nil
+	}
+	logWarn(ctx, logCrud, fmt.Sprintf("Rolling back %d field updates of %s %s", len(applied), batch.typ, batch.name))
+	var diags diag.Diagnostics
+	for i := len(applied) - 1; i >= 0; i-- {
+		op := applied[i]
+		err := fmt.Errorf("its previous value is unknown")
+		if op.undo != "" {
+			_, err = runOpCommand(ctx, client, op.undo, true)
+		}
+		if err != nil {
+			summary := fmt.Sprintf("Failed to roll back %s %s.%s after the failed update: %s", batch.typ, batch.name, op.field, err.Error())
+			logError(ctx, logCrud, summary)
+			diagnostic := diag.Diagnostic{Severity: diag.Error, Summary: summary}
+			if op.attr != "" {
+				diagnostic.AttributePath = cty.GetAttrPath(op.attr)
+			}
+			diags = append(diags, diagnostic)
+		}
+	}
+	if batch.reenable {
+		if _, err := runOpCommand(ctx, client, opEnableStatement(true, batch.name), true); err != nil {
+			summary := fmt.Sprintf("Failed to re-enable %s %s after the failed update: %s", batch.typ, batch.name, err.Error())
+			logError(ctx, logCrud, summary)
+			diags = append(diags, diag.Diagnostic{Severity: diag.Error, Summary: summary, AttributePath: cty.GetAttrPath("enabled")})
+		}
+	}
+	return diags
+}
+
+// runOpCommands runs the op statements in order, in a single request if the API server supports it,
+// and checks the result of each one. Each statement gets its own error (or nil). When they're sent one at a
+// time, the ones after a failed statement aren't sent (and get errBatchNotRun).
+// Statements from the first transiently failing one onwards are retried, like runOpCommand().
+func runOpCommands(ctx context.Context, client *apiClient, commands []string) ([]string, []error) {
+	results := make([]string, len(commands))
+	errs := make([]error, len(commands))
+	if client == nil {
+		for i := range errs {
+			errs[i] = fmt.Errorf("No valid auth credentials.")
+		}
+		return results, errs
+	}
+	first := 0
+	start := time.Now()
+	batchCtx := withStatementKind(ctx, statementKindBatch)
+	for r := 0; first < len(commands); r += 1 {
+		if client.noBatch.Load() || len(commands)-first == 1 {
+			for i := first; i < len(commands); i++ {
+				if i > first && errs[i-1] != nil {
+					errs[i] = errBatchNotRun
+					continue
+				}
+				results[i], errs[i] = runOpCommand(ctx, client, commands[i], true)
+			}
+			break
+		}
+		logDebug(ctx, logHttp, fmt.Sprintf("Running OpLang batch of %d (retries %d/%d)", len(commands)-first, r, client.retryLimit), map[string]interface{}{
+			logFieldStatement: strings.Join(commands[first:], " ;; "),
+			logFieldRetry:     r,
+		})
+		rets, err := ExecuteOpBatch(batchCtx, client, commands[first:])
+		if errors.Is(err, ErrBatchUnsupported) {
+			logInfo(ctx, logHttp, "API server doesn't support multi-statement requests, sending one at a time")
+			client.noBatch.Store(true)
+			continue
+		}
+		retryFrom := -1
+		if err != nil {
+			logWarn(ctx, logHttp, fmt.Sprintf("Failed OpLang batch (retries %d/%d)", r, client.retryLimit), map[string]interface{}{logFieldRetry: r, logFieldError: err.Error()})
+			for i := first; i < len(commands); i++ {
+				errs[i] = err
+			}
+			retryFrom = first
+		} else {
+			for i, ret := range rets {
+				idx := first + i
+				results[idx] = ret
+				errs[idx] = annotateBackendError(CheckUpdateResult(ret), commands[idx], "", "")
+				if errs[idx] != nil {
+					logWarn(ctx, logHttp, fmt.Sprintf("Failed OpLang update (retries %d/%d)", r, client.retryLimit), map[string]interface{}{
+						logFieldStatement: commands[idx],
+						logFieldRetry:     r,
+						logFieldError:     errs[idx].Error(),
+					})
+					if retryFrom < 0 && isRetryableError(errs[idx]) {
+						retryFrom = idx
+					}
+				}
+			}
+		}
+		if retryFrom < 0 || !client.waitToRetry(batchCtx, r, start, errs[retryFrom]) {
+			break
+		}
+		first = retryFrom
+	}
+	return results, errs
+}
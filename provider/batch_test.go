@@ -0,0 +1,300 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider/mockbackend"
+)
+
+func testMockBatchRequests() []mockbackend.Request {
+	batches := []mockbackend.Request{}
+	for _, req := range mockServer.Requests() {
+		if req.Batch != nil {
+			batches = append(batches, req)
+		}
+	}
+	return batches
+}
+
+func TestMockBatchFieldUpdates(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.ResetRequests()
+
+	testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":                    name,
+		"command":                 "`hostname`",
+		"description":             "batched",
+		"timeout":                 30,
+		"start_title_template":    "started",
+		"complete_title_template": "completed",
+		"enabled":                 true,
+	})
+
+	batches := testMockBatchRequests()
+	if len(batches) != 1 {
+		t.Fatalf("Expected a single batched request for the field updates, got %d", len(batches))
+	}
+	stmts := batches[0].Batch
+	if len(stmts) < 5 {
+		t.Errorf("Expected all the field updates in the batch, got: %v", stmts)
+	}
+	if containsString(stmts, "enable "+name) {
+		t.Errorf("Expected the action to be enabled after the batch, got: %v", stmts)
+	}
+	enabled := false
+	for _, stmt := range mockServer.Statements() {
+		if stmt == "enable "+name {
+			enabled = true
+		} else if enabled && strings.HasPrefix(stmt, name+".") {
+			t.Errorf("Expected the action to be enabled after its field updates, got: %s", stmt)
+		}
+	}
+	for _, stmt := range mockServer.Statements() {
+		if strings.HasPrefix(stmt, name+".") && !containsString(stmts, stmt) {
+			t.Errorf("Field update sent outside of the batch: %s", stmt)
+		}
+	}
+	_, attrs, _ := mockServer.Object(name)
+	if attrs["description"] != "batched" || attrs["enabled"] != true {
+		t.Errorf("Unexpected backend attributes: %v", attrs)
+	}
+}
+
+func TestMockBatchErrorAttribution(t *testing.T) {
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.PutObject("action", name, map[string]interface{}{"command": "`hostname`", "description": "before", "shell": "/bin/bash"})
+	mockServer.FailStatements(`^`+name+`\.timeout = `, "timeout must be positive")
+	defer mockServer.ResetFaults()
+
+	batch := newOpBatch("action", name)
+	batch.isUpdate = true
+	batch.add(batchOp{statement: name + `.description = "ok"`, attr: "description", field: "description", undo: name + `.description = "before"`})
+	batch.add(batchOp{statement: name + `.timeout = -1`, attr: "timeout", field: "timeout", undo: name + `.timeout = 60`})
+	batch.add(batchOp{statement: name + `.shell = "/bin/sh"`, attr: "shell", field: "shell", undo: name + `.shell = "/bin/bash"`})
+	diags := batch.flush(context.Background(), client)
+
+	if len(diags) != 1 {
+		t.Fatalf("Expected a single error diagnostic, got: %+v", diags)
+	}
+	if !strings.Contains(diags[0].Summary, name+".timeout") || !strings.Contains(diags[0].Summary, "timeout must be positive") {
+		t.Errorf("Unexpected error summary: %s", diags[0].Summary)
+	}
+	if !diags[0].AttributePath.Equals(cty.GetAttrPath("timeout")) {
+		t.Errorf("Expected the error on the 'timeout' attribute, got %#v", diags[0].AttributePath)
+	}
+	// the fields updated in the same request (before and after the failed one) are rolled back
+	_, attrs, _ := mockServer.Object(name)
+	if attrs["description"] != "before" || attrs["shell"] != "/bin/bash" {
+		t.Errorf("Expected the other fields to be rolled back, got: %v", attrs)
+	}
+}
+
+func TestMockBatchStopsAtFirstFailure(t *testing.T) {
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	client.noBatch.Store(true)
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.PutObject("action", name, map[string]interface{}{"command": "`hostname`", "description": "before", "enabled": true})
+	mockServer.FailStatements(`^`+name+`\.timeout = `, "timeout must be positive")
+	defer mockServer.ResetFaults()
+	mockServer.ResetRequests()
+
+	batch := newOpBatch("action", name)
+	batch.isUpdate = true
+	batch.reenable = true
+	batch.add(batchOp{statement: name + `.description = "ok"`, attr: "description", field: "description", undo: name + `.description = "before"`})
+	batch.add(batchOp{statement: name + `.timeout = -1`, attr: "timeout", field: "timeout", undo: name + `.timeout = 60`})
+	batch.add(batchOp{statement: name + `.shell = "/bin/sh"`, attr: "shell", field: "shell"})
+	if diags := batch.flush(context.Background(), client); len(diags) != 1 {
+		t.Fatalf("Expected a single error diagnostic, got: %+v", diags)
+	}
+	stmts := mockServer.Statements()
+	if containsString(stmts, name+`.shell = "/bin/sh"`) {
+		t.Errorf("Expected the statements after the failed one not to be sent, got: %v", stmts)
+	}
+	if stmts[len(stmts)-1] != "enable "+name {
+		t.Errorf("Expected the action to be re-enabled after the rollback, got: %v", stmts)
+	}
+	if _, attrs, _ := mockServer.Object(name); attrs["description"] != "before" {
+		t.Errorf("Expected the description to be rolled back, got: %v", attrs)
+	}
+}
+
+func TestMockBatchFallbackOrder(t *testing.T) {
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.PutObject("action", name, map[string]interface{}{"command": "`hostname`"})
+	mockServer.FailStatements(`^`+name+`\.old_description = `, "field 'old_description' does not exist")
+	defer mockServer.ResetFaults()
+
+	batch := newOpBatch("action", name)
+	batch.add(batchOp{statement: name + `.old_description = "first"`, attr: "description", field: "old_description", fallback: name + `.description = "first"`})
+	batch.add(batchOp{statement: name + `.description = "second"`, attr: "description", field: "description"})
+	if diags := batch.flush(context.Background(), client); diags.HasError() {
+		t.Fatalf("Expected the fields to be set, got: %+v", diags)
+	}
+	if _, attrs, _ := mockServer.Object(name); attrs["description"] != "second" {
+		t.Errorf("Expected the fallback to run before the later statements, got: %v", attrs["description"])
+	}
+}
+
+func TestMockUpdateRollback(t *testing.T) {
+	p, meta := testMockProvider(t)
+	res := p.ResourcesMap["shoreline_action"]
+	name := RandomAlphaPrefix(5) + "_action"
+	config := map[string]interface{}{
+		"name":        name,
+		"command":     "`hostname`",
+		"description": "before",
+		"timeout":     30,
+		"enabled":     true,
+	}
+	state := testMockCreate(t, p, meta, "shoreline_action", config).State()
+	mockServer.FailStatements(`^`+name+`\.timeout = `, "timeout too long")
+	defer mockServer.ResetFaults()
+
+	config["description"] = "after"
+	config["timeout"] = 90
+	diff, err := res.Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), meta)
+	if err != nil {
+		t.Fatalf("Failed to plan: %s", err)
+	}
+	newState, diags := res.Apply(context.Background(), state, diff, meta)
+	if !diags.HasError() {
+		t.Fatalf("Expected the update to fail")
+	}
+	_, attrs, _ := mockServer.Object(name)
+	if attrs["description"] != "before" || attrs["enabled"] != true {
+		t.Errorf("Expected the description to be rolled back, and the action re-enabled, got: %v", attrs)
+	}
+	if newState == nil || newState.Attributes["description"] != "before" {
+		t.Errorf("Expected the previous state to be kept, got: %+v", newState)
+	}
+}
+
+func TestMockBatchRenamedFieldFallback(t *testing.T) {
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.PutObject("action", name, map[string]interface{}{"command": "`hostname`"})
+	mockServer.FailStatements(`^`+name+`\.old_description = `, "field 'old_description' does not exist")
+	defer mockServer.ResetFaults()
+
+	batch := newOpBatch("action", name)
+	batch.add(batchOp{statement: name + `.old_description = "renamed"`, attr: "description", field: "old_description", fallback: name + `.description = "renamed"`})
+	if diags := batch.flush(context.Background(), client); diags.HasError() {
+		t.Fatalf("Expected the renamed field to be set, got: %+v", diags)
+	}
+	_, attrs, _ := mockServer.Object(name)
+	if attrs["description"] != "renamed" {
+		t.Errorf("Expected the description to be set via the new field name, got: %v", attrs["description"])
+	}
+}
+
+func TestMockBatchUnsupportedFallback(t *testing.T) {
+	p, meta := testMockProvider(t)
+	mockServer.NoBatch = true
+	defer func() { mockServer.NoBatch = false }()
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.ResetRequests()
+
+	testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":        name,
+		"command":     "`hostname`",
+		"description": "one at a time",
+		"timeout":     30,
+	})
+
+	if !meta.(*apiClient).noBatch.Load() {
+		t.Errorf("Expected the provider to stop sending batches")
+	}
+	if batches := testMockBatchRequests(); len(batches) != 1 {
+		t.Errorf("Expected only the first (rejected) batch request, got %d", len(batches))
+	}
+	_, attrs, _ := mockServer.Object(name)
+	if attrs["description"] != "one at a time" {
+		t.Errorf("Expected the fields to be set one at a time, got: %v", attrs)
+	}
+}
+
+func containsString(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMockBatchSkipsEnableOnFailure(t *testing.T) {
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.PutObject("action", name, map[string]interface{}{"command": "`hostname`"})
+	mockServer.FailStatements(`^`+name+`\.timeout = `, "timeout must be positive")
+	defer mockServer.ResetFaults()
+	mockServer.ResetRequests()
+
+	batch := newOpBatch("action", name)
+	batch.add(batchOp{statement: name + `.timeout = -1`, attr: "timeout", field: "timeout"})
+	batch.add(batchOp{statement: "enable " + name, attr: "enabled", field: "enabled", action: "enable"})
+	if diags := batch.flush(context.Background(), client); len(diags) != 1 {
+		t.Fatalf("Expected a single error diagnostic, got: %+v", diags)
+	}
+	if containsString(mockServer.Statements(), "enable "+name) {
+		t.Errorf("Expected the action not to be enabled after a failed update, got: %v", mockServer.Statements())
+	}
+}
+
+func TestMockBatchEnableAfterRenamedField(t *testing.T) {
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.PutObject("action", name, map[string]interface{}{"command": "`hostname`"})
+	mockServer.FailStatements(`^`+name+`\.old_description = `, "field 'old_description' does not exist")
+	defer mockServer.ResetFaults()
+	mockServer.ResetRequests()
+
+	batch := newOpBatch("action", name)
+	batch.add(batchOp{statement: name + `.old_description = "renamed"`, attr: "description", field: "old_description", fallback: name + `.description = "renamed"`})
+	batch.add(batchOp{statement: "enable " + name, attr: "enabled", field: "enabled", action: "enable"})
+	if diags := batch.flush(context.Background(), client); diags.HasError() {
+		t.Fatalf("Expected the renamed field to be set, got: %+v", diags)
+	}
+	if stmts := mockServer.Statements(); stmts[len(stmts)-1] != "enable "+name {
+		t.Errorf("Expected the action to be enabled after the renamed field, got: %v", stmts)
+	}
+}
+
+func TestMockBatchBadRequestFallsBack(t *testing.T) {
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	client.noBatch.Store(false)
+	mockServer.InjectFault(mockbackend.Fault{Path: "/v1/execute", Status: 400, Body: "invalid request"})
+	defer mockServer.ResetFaults()
+
+	_, errs := runOpCommands(context.Background(), client, []string{"list actions", "list bots"})
+	if errs[0] != nil || errs[1] != nil {
+		t.Errorf("Expected the statements to be sent one at a time after the rejected batch, got: %v", errs)
+	}
+	if !client.noBatch.Load() {
+		t.Errorf("Expected a rejected batch to turn batching off")
+	}
+	for status, unsupported := range map[int]bool{400: true, 404: true, 422: true, 501: true, 401: false, 429: false, 500: false} {
+		if isBatchUnsupported(&HttpStatusError{StatusCode: status}) != unsupported {
+			t.Errorf("%d: expected unsupported=%v", status, unsupported)
+		}
+	}
+}
@@ -11,8 +11,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
-	"os/signal"
 	"strings"
 	"time"
 
@@ -25,6 +23,21 @@ const authEndpoint = "/v1/token/refresh?flow_type=cli"
 const requestTimeoutSec = 90
 const accessTokenTTL = 60 * 60 // one hour expiration for CLI access tokens
 
+// HttpStatusError is returned by Execute() for non-200 responses from the API server.
+type HttpStatusError struct {
+	StatusCode int
+	Message    string
+	// the delay requested by the API server (Retry-After header), e.g. for a 429
+	RetryAfter time.Duration
+}
+
+func (e *HttpStatusError) Error() string {
+	return e.Message
+}
+
+// ErrBatchUnsupported is returned by ExecuteBatch() when the API server only takes single statements.
+var ErrBatchUnsupported = errors.New("multi-statement execute requests are not supported by the API server")
+
 func GetTokenAuthUrl(GlobalOpts *CliOpts, manual bool) string {
 	// NOTE: there should be no trailing "/" on GlobalOpts.Url
 	if manual {
@@ -63,23 +76,23 @@ func DecodeAuthToken(token string) *AuthTokenStruct {
 	ats := AuthTokenStruct{}
 	ats.Parts = strings.Split(token, ".")
 	if len(ats.Parts) < 3 {
-		WriteMsg("Incomplete Auth Token! (%s)\n", token)
+		WriteMsg("Incomplete Auth Token! (%d parts)\n", len(ats.Parts))
 		return nil
 	}
 	//ats.ClaimStr, err = base64.URLEncoding.DecodeString(ats.Parts[1])
 	algoStr, err := base64.RawURLEncoding.DecodeString(ats.Parts[0])
 	if err != nil {
-		WriteMsg("Failed to decode Auth Token Algo! %v (%v)\n", err, ats.Parts[0])
+		WriteMsg("Failed to decode Auth Token Algo! %v\n", err)
 		return nil
 	}
 	claimStr, err := base64.RawURLEncoding.DecodeString(ats.Parts[1])
 	if err != nil {
-		WriteMsg("Failed to decode Auth Token Claim! %v (%v)\n", err, ats.Parts[1])
+		WriteMsg("Failed to decode Auth Token Claim! %v\n", err)
 		return nil
 	}
 	_, err = base64.RawURLEncoding.DecodeString(ats.Parts[2])
 	if err != nil {
-		WriteMsg("Failed to decode Auth Token Signature! %v (%v)\n", err, ats.Parts[2])
+		WriteMsg("Failed to decode Auth Token Signature! %v\n", err)
 		return nil
 	}
 	ats.AlgoStr = string(algoStr)
@@ -88,44 +101,58 @@ func DecodeAuthToken(token string) *AuthTokenStruct {
 	ats.Claim = map[string]interface{}{}
 	err = json.Unmarshal([]byte(ats.ClaimStr), &ats.Claim)
 	if err != nil {
-		WriteMsg("Auth Token claim is invalid JSON! %v (%v)\n", err, ats.ClaimStr)
+		WriteMsg("Auth Token claim is invalid JSON! %v\n", err)
 		return nil
 	}
 	ats.Customer = CastToString(GetNestedValueOrDefault(ats.Claim, ToKeyPath("cst"), ""))
 	ats.User = CastToString(GetNestedValueOrDefault(ats.Claim, ToKeyPath("sub"), ""))
 	ats.Type = CastToString(GetNestedValueOrDefault(ats.Claim, ToKeyPath("aud"), ""))
-	expiry := GetNestedValueOrDefault(ats.Claim, ToKeyPath("exp"), 0)
-	ats.Expiry = int64(expiry.(float64))
+	// 0 if the token doesn't expire (or has no 'exp' claim)
+	ats.Expiry = int64(CastToNumber(GetNestedValueOrDefault(ats.Claim, ToKeyPath("exp"), 0)))
 	t := time.Unix(ats.Expiry, 0)
 	ats.ExpiryStr = t.Format(time.UnixDate)
 	return &ats
 }
 
 type ClientAuth struct {
-	BaseURL      string
-	ApiToken     string
-	AccessToken  string
-	AccessExpiry int64
-	ApiKey       string
+	BaseURL  string
+	ApiToken string
+	ApiKey   string
+	// shared by all the requests with this auth, see NewClientAuth()
+	Tokens *TokenManager
 }
 
 // Client client for sending request to opslang backend service
 type Client struct {
 	httpClient *http.Client
 	authData   *ClientAuth
+	limiter    *RateLimiter
+	metrics    *ApiMetrics
 }
 
 type clientOption func(*Client)
 
 // NOTE: you can run with "GODEBUG=http2debug=2" on the commandline to print out client debugging info.
 
-// NewClient OpslangClient instance
+// NewClientAuth sets up the auth data for an API server, with a TokenManager that
+// exchanges the api token (a refresh token) for access tokens as needed.
 func NewClientAuth(host string, apiToken string, apiKey string, options ...clientOption) *ClientAuth {
+	return NewClientAuthWithRefresh(host, apiToken, apiKey, defaultTokenRefreshFraction, options...)
+}
+
+// NewClientAuthWithRefresh is NewClientAuth, refreshing access tokens after the given fraction of their lifetime.
+func NewClientAuthWithRefresh(host string, apiToken string, apiKey string, refreshFraction float64, options ...clientOption) *ClientAuth {
 	auth := &ClientAuth{
 		BaseURL:  host,
 		ApiToken: apiToken,
 		ApiKey:   apiKey,
 	}
+	auth.Tokens = NewTokenManager(apiToken, refreshFraction, func(ctx context.Context, refreshToken string) (string, string, error) {
+		// each refresh is its own request, so it gets its own idempotency key
+		refreshAuth := *auth
+		refreshAuth.ApiKey = GetIdempotencyKey()
+		return NewClient(&refreshAuth, options...).fetchAccessToken(ctx, refreshToken, false)
+	})
 	return auth
 }
 
@@ -151,63 +178,92 @@ func setHTTPClientOption(httpClient *http.Client) clientOption {
 	}
 }
 
-// Execute sends statement to shoreline backend
-func (client *Client) Execute(statement string, suppressErrors bool) (ret []byte, err error) {
-	if !client.maybeRefreshAccessToken(suppressErrors) {
-		return []byte(""), fmt.Errorf("Access token refresh failed.")
+func setRateLimiterOption(limiter *RateLimiter) clientOption {
+	return func(client *Client) {
+		client.limiter = limiter
 	}
-	ret, err, code := client.executeInner(statement, suppressErrors)
-	if code == 401 {
-		// Second chance (in case latency/etc causes an expired token).
-		// Force a token refresh
-		client.authData.AccessExpiry = 0
-		if !client.maybeRefreshAccessToken(suppressErrors) {
-			return []byte(""), err
-		}
-		ret, err, code = client.executeInner(statement, suppressErrors)
+}
+
+func setMetricsOption(metrics *ApiMetrics) clientOption {
+	return func(client *Client) {
+		client.metrics = metrics
 	}
-	return ret, err
 }
 
-func (client *Client) maybeRefreshAccessToken(suppressErrors bool) bool {
-	decoded := DecodeAuthToken(client.authData.ApiToken)
-	if decoded == nil {
-		if viper.GetBool("debug") {
-			WriteMsg("ApiToken is invalid.\n")
+// Execute sends statement to shoreline backend
+func (client *Client) Execute(ctx context.Context, statement string, suppressErrors bool) (ret []byte, err error) {
+	return client.executeWithRefresh(ctx, map[string]interface{}{"statement": statement}, suppressErrors)
+}
+
+// ExecuteBatch runs several op statements in a single request, and returns the result of each (in order).
+// The statements are run in order, and each one reports its own result (errors included).
+//
+//	request:  { "statements": [ "<stmt>", ... ] }
+//	response: { "results": [ <same as the single statement response>, ... ] }
+//
+// Returns ErrBatchUnsupported if the API server doesn't accept multi-statement requests.
+func (client *Client) ExecuteBatch(ctx context.Context, statements []string, suppressErrors bool) (rets [][]byte, err error) {
+	ret, err := client.executeWithRefresh(ctx, map[string]interface{}{"statements": statements}, suppressErrors)
+	if err != nil {
+		var statusErr *HttpStatusError
+		if errors.As(err, &statusErr) && isBatchUnsupported(statusErr) {
+			return nil, ErrBatchUnsupported
 		}
+		return nil, err
+	}
+	batch := struct {
+		Results []json.RawMessage `json:"results"`
+	}{}
+	if jsErr := json.Unmarshal(ret, &batch); jsErr != nil || len(batch.Results) != len(statements) {
+		return nil, fmt.Errorf("Unexpected response to a multi-statement request (%d statements): %s", len(statements), string(ret))
+	}
+	rets = make([][]byte, len(batch.Results))
+	for i, result := range batch.Results {
+		rets[i] = []byte(result)
+	}
+	return rets, nil
+}
+
+// isBatchUnsupported is true when the API server rejects a multi-statement request, i.e. with any client
+// error (4xx) other than the auth and rate limit ones, which fail (or retry) the batch as they would a single
+// statement. API servers without multi-statement support don't reliably tell why (e.g. a 400, 404 or 422).
+func isBatchUnsupported(statusErr *HttpStatusError) bool {
+	switch statusErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusRequestTimeout, http.StatusTooManyRequests:
 		return false
+	case http.StatusNotImplemented:
+		return true
 	}
-	if decoded.Type == "access" {
-		now := time.Now().Unix()
+	return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500
+}
+
+func (client *Client) executeWithRefresh(ctx context.Context, payload map[string]interface{}, suppressErrors bool) (ret []byte, err error) {
+	token, err := client.authData.Tokens.AccessToken(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return []byte(""), ctx.Err()
+		}
 		if viper.GetBool("debug") {
-			WriteMsg("ApiToken is an access token (not refresh) using it directly.\n")
+			WriteMsg("Access token refresh failed: %s\n", err.Error())
 		}
-		if decoded.Expiry <= now {
-			if !suppressErrors {
-				WriteMsg("ApiToken is an access token (not refresh) but has expired.\n")
-			}
-			return false
+		if isRetryableError(err) {
+			// e.g. rate limited, keep the status (and Retry-After) for the retries
+			return []byte(""), fmt.Errorf("Access token refresh failed: %w", err)
 		}
-		client.authData.AccessToken = client.authData.ApiToken
-		return true
+		return []byte(""), fmt.Errorf("Access token refresh failed.")
 	}
-	now := time.Now().Unix()
-	// To avoid the latency of getting an access token on every op-statement:
-	//   keep a timestamp (1 hour expiry)
-	//   only refresh if past expiry, or executeInner() returns 401
-	if client.authData.AccessExpiry <= now || client.authData.AccessToken == "" {
-		if viper.GetBool("debug") {
-			WriteMsg("Re-Authorizing... (%d - %d = %d) token: '%s'\n", client.authData.AccessExpiry, now, now-client.authData.AccessExpiry, client.authData.AccessToken)
-		}
-		auth, err := client.fetchAccessToken(suppressErrors)
-		if err != nil {
-			return false
+	ret, err, code := client.executeInner(ctx, token, payload, suppressErrors)
+	if code == 401 {
+		// Second chance (in case latency/etc causes an expired token).
+		// Force a token refresh
+		client.authData.Tokens.Invalidate(token)
+		token, refreshErr := client.authData.Tokens.AccessToken(ctx)
+		if refreshErr != nil {
+			return []byte(""), err
 		}
-		client.authData.AccessToken = string(auth)
-		// intentionally use old "now" to account for network delays
-		client.authData.AccessExpiry = now + accessTokenTTL
+		ret, err, code = client.executeInner(ctx, token, payload, suppressErrors)
 	}
-	return true
+	return ret, err
 }
 
 func maybePrintTimer(startTimeMs int64, label string) {
@@ -217,12 +273,16 @@ func maybePrintTimer(startTimeMs int64, label string) {
 	}
 }
 
-func (client *Client) callApi(suppressErrors bool, auth string, url string, body string, kind string) (ret []byte, err error, code int) {
+func (client *Client) callApi(ctx context.Context, suppressErrors bool, auth string, url string, body string, kind string) (ret []byte, err error, code int) {
 	startTimeMs := time.Now().UnixNano() / 1_000_000
 	defer maybePrintTimer(startTimeMs, kind)
+	start := time.Now()
+	defer func() {
+		client.metrics.recordCall(ctx, time.Since(start), len(body), len(ret), err != nil || code != http.StatusOK)
+	}()
 
 	authorization := fmt.Sprintf("Bearer %s", auth)
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(body)))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(body)))
 	if err != nil {
 		if !suppressErrors {
 			WriteMsg("ERROR creating HTTP request object.\n")
@@ -234,30 +294,14 @@ func (client *Client) callApi(suppressErrors bool, auth string, url string, body
 	req.Header.Set("idempotency-key", client.authData.ApiKey)
 	req.Header.Set("accept", "*/*")
 
-	// Allow Ctrl-C to cancel
-	canceled := false
-	ctx, cancel := context.WithCancel(context.Background())
-	req = req.WithContext(ctx)
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-	defer func() {
-		signal.Stop(interrupt) //, os.Interrupt)
-		close(interrupt)
-	}()
-	go func() {
-		sig := <-interrupt
-		canceled = true
-		if sig != nil {
-			//fmt.Printf("cancel signal %v\n", sig)
-			cancel()
-		}
-	}()
-
-	resp, err := client.httpClient.Do(req)
-	if canceled {
-		return ret, errors.New("User Cancelled"), 0
+	release, err := client.limiter.Acquire(ctx)
+	if err != nil {
+		return ret, err, 0
 	}
+	defer release()
 
+	// NOTE: cancellation (and deadlines) come from the caller's context, e.g. Terraform's per-operation timeouts
+	resp, err := client.httpClient.Do(req)
 	if err != nil {
 		if !suppressErrors {
 			WriteMsg("ERROR fetching HTTP response -- %s.\n", kind)
@@ -284,6 +328,11 @@ func (client *Client) callApi(suppressErrors bool, auth string, url string, body
 	}
 
 	ret, err = ioutil.ReadAll(resp.Body)
+	logTrace(ctx, logHttp, fmt.Sprintf("API response %d -- %s", resp.StatusCode, kind), map[string]interface{}{
+		"url":         url,
+		"status":      resp.StatusCode,
+		"duration_ms": time.Now().UnixNano()/1_000_000 - startTimeMs,
+	})
 
 	if err != nil {
 		if !suppressErrors {
@@ -292,23 +341,40 @@ func (client *Client) callApi(suppressErrors bool, auth string, url string, body
 		return ret, err, 0
 	}
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// hold back all requests (of this provider instance) for as long as the server asks
+		retryAfter := parseRetryAfter(resp.Header, time.Now())
+		client.limiter.PauseFor(retryAfter)
+		return ret, &HttpStatusError{StatusCode: resp.StatusCode, Message: string(ret), RetryAfter: retryAfter}, resp.StatusCode
+	}
+
 	return ret, err, resp.StatusCode
 }
 
-func (client *Client) fetchAccessToken(suppressErrors bool) (ret []byte, err error) {
+// fetchAccessToken exchanges the refresh token for an access token.
+// The API server may also return a new refresh token (rotation), otherwise 'refresh' is empty.
+func (client *Client) fetchAccessToken(ctx context.Context, refreshToken string, suppressErrors bool) (access string, refresh string, err error) {
 	url := fmt.Sprintf("%s%s", client.authData.BaseURL, authEndpoint)
-	auth := client.authData.ApiToken
+	auth := refreshToken
 	kind := "fetchAccessToken()"
-	body := "{\"refresh_token\": \"" + client.authData.ApiToken + "\"}"
-	ret, err, code := client.callApi(suppressErrors, auth, url, body, kind)
+	ctx = withStatementKind(ctx, statementKindTokenRefresh)
+	body := "{\"refresh_token\": \"" + refreshToken + "\"}"
+	ret, err, code := client.callApi(ctx, suppressErrors, auth, url, body, kind)
 
+	if err != nil && code == 0 {
+		return "", "", err
+	}
 	if code != 200 {
 		if !suppressErrors {
 			WriteMsg("ERROR Unexpected HTTP status code (%v) in auth response.\n", code)
 			WriteMsg("You may need to get a fresh authorization token! e.g\n")
 			WriteMsg(" 'auth %s'\n", client.authData.BaseURL)
 		}
-		return ret, fmt.Errorf(string(ret))
+		var statusErr *HttpStatusError
+		if errors.As(err, &statusErr) {
+			return "", "", statusErr
+		}
+		return "", "", fmt.Errorf(string(ret))
 	}
 
 	var js interface{}
@@ -317,40 +383,48 @@ func (client *Client) fetchAccessToken(suppressErrors bool) (ret []byte, err err
 		if !suppressErrors {
 			WriteMsg("ERROR Unmarshaling HTTP auth response.\n")
 		}
-		return ret, err
+		return "", "", jsErr
 	}
-	// NOTE: A refresh token is also returned, so we could also update the token saved in the config.
-	//refresh, isStr := GetNestedValueOrDefault(js, ToKeyPath("refresh_token"), "").(string)
 	access, isStr := GetNestedValueOrDefault(js, ToKeyPath("access_token"), "").(string)
 	if !isStr || access == "" {
 		if !suppressErrors {
 			WriteMsg("ERROR Missing token in auth response.\n")
 		}
-		return ret, fmt.Errorf("Missing access token in response.")
+		return "", "", fmt.Errorf("Missing access token in response.")
 	}
+	// A (rotated) refresh token may also be returned, which replaces the one we sent.
+	refresh, _ = GetNestedValueOrDefault(js, ToKeyPath("refresh_token"), "").(string)
 
-	return []byte(access), err
+	return access, refresh, nil
 }
 
-func (client *Client) executeInner(statement string, suppressErrors bool) (ret []byte, err error, code int) {
+func (client *Client) executeInner(ctx context.Context, accessToken string, payload map[string]interface{}, suppressErrors bool) (ret []byte, err error, code int) {
 	url := fmt.Sprintf("%s%s", client.authData.BaseURL, executeEndpoint)
-	auth := client.authData.AccessToken
+	auth := accessToken
 	kind := "Execute()"
-	body_data := map[string]string{"statement": statement}
-	body, err := json.Marshal(body_data)
+	body, err := json.Marshal(payload)
 	if err != nil {
 		if !suppressErrors {
 			WriteMsg("ERROR marshaling op statement body.\n")
 		}
 		return ret, err, 0
 	}
-	ret, err, code = client.callApi(suppressErrors, auth, url, string(body), kind)
+	ret, err, code = client.callApi(ctx, suppressErrors, auth, url, string(body), kind)
 
+	if err != nil && code == 0 {
+		// transport level failure, keep the original error (e.g. net.Error) for the caller
+		return ret, err, code
+	}
 	if code != 200 {
 		if ret == nil || len(ret) == 0 {
 			ret = []byte(fmt.Sprintf("ERROR: Unexpected HTTP status code (%v) in response.\n", code))
 		}
-		return ret, fmt.Errorf("%s", string(ret)), code
+		statusErr := &HttpStatusError{StatusCode: code, Message: string(ret)}
+		var apiErr *HttpStatusError
+		if errors.As(err, &apiErr) {
+			statusErr.RetryAfter = apiErr.RetryAfter
+		}
+		return ret, statusErr, code
 	}
 
 	return ret, err, code
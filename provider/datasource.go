@@ -0,0 +1,847 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// DataSourceShorelineObject reads an existing object (e.g. one managed in another state) by name.
+// It has the same attributes as the resource (except the sensitive ones), all computed.
+func DataSourceShorelineObject(configJsStr string, key string) *schema.Resource {
+	resource := ResourceShorelineObject(configJsStr, key)
+	objects, object, attributes := parseObjectConfig(configJsStr, key)
+	if resource == nil || object == nil {
+		return nil
+	}
+
+	params := map[string]*schema.Schema{}
+	for k, sch := range resource.Schema {
+		if sch.Sensitive || k == "adopt_existing" {
+			continue
+		}
+		params[k] = &schema.Schema{
+			Type:        sch.Type,
+			Elem:        sch.Elem,
+			Description: sch.Description,
+			Computed:    true,
+		}
+	}
+	params["name"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Required:     true,
+		ValidateFunc: resource.Schema["name"].ValidateFunc,
+		Description:  "The name of the " + key + " to read.",
+	}
+
+	// the sensitive attributes aren't in the schema, so they aren't read
+	readAttrs := map[string]interface{}{}
+	for k, attr := range attributes {
+		if sensitive, _ := GetNestedValueOrDefault(attr, ToKeyPath("sensitive"), false).(bool); !sensitive {
+			readAttrs[k] = attr
+		}
+	}
+
+	objDescription := CastToString(GetNestedValueOrDefault(objects, ToKeyPath("docs.objects."+key), ""))
+	objectDef, _ := object.(map[string]interface{})
+
+	return &schema.Resource{
+		Description: "Reads an existing Shoreline " + key + ". " + objDescription,
+		ReadContext: withRedaction(params, dataSourceShorelineObjectRead(key, readAttrs, objectDef)),
+		Schema:      params,
+	}
+}
+
+func dataSourceShorelineObjectRead(typ string, attrs map[string]interface{}, objectDef map[string]interface{}) func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		name := d.Get("name").(string)
+		d.SetId(name)
+		diags := resourceShorelineObjectRead(typ, attrs, objectDef)(ctx, d, meta)
+		if diags.HasError() {
+			return diags
+		}
+		if d.Id() == "" {
+			// removed from the state by the read, but a data source has to exist
+			return append(diags, diag.FromErr(fmt.Errorf("Failed to find %s '%s'", typ, name))...)
+		}
+		return diags
+	}
+}
+
+// the attributes returned for each object by the plural (list) data sources, if the type has them
+var listKeyAttributes = []string{"description", "enabled", "family", "labels", "resource_query"}
+
+// the key attributes that the plural data sources can filter on
+var listFilterAttributes = []string{"enabled", "family", "labels", "resource_query"}
+
+// the most get_<type>_class requests (one per object) a plural data source makes for a read
+var listMaxClassReads = 100
+
+// listObjectAttr reads a key attribute from a list record (or the class, for "step" attributes).
+func listObjectAttr(ctx context.Context, typ string, name string, key string, attrs map[string]interface{}, record map[string]interface{}, stepsJs map[string]interface{}) interface{} {
+	if key == "resource_query" && attrs[key] == nil {
+		// e.g. notebooks, where it has been renamed
+		key = "allowed_resources_query"
+	}
+	_, val, _ := resourceShorelineObjectReadSingleAttr(name, typ, key, attrs, record, stepsJs, ctx, nil, "", nil)
+	if val == nil {
+		val = GetNestedValueOrDefault(attrs, ToKeyPath(key+".default"), nil)
+	}
+	switch GetNestedValueOrDefault(attrs, ToKeyPath(key+".type"), "string").(string) {
+	case "bool", "intbool":
+		return CastToBool(val)
+	case "string[]", "string_set":
+		return CastToArray(val)
+	}
+	if val == nil {
+		return ""
+	}
+	return CastToString(val)
+}
+
+// listHasAttr checks if objects of a type have a key attribute (and if it's read from the class).
+func listHasAttr(attrs map[string]interface{}, key string) (found bool, fromClass bool) {
+	if key == "resource_query" && attrs[key] == nil {
+		key = "allowed_resources_query"
+	}
+	attr, found := attrs[key].(map[string]interface{})
+	if !found || GetNestedValueOrDefault(attr, ToKeyPath("internal"), false).(bool) {
+		return false, false
+	}
+	_, fromClass = attr["step"].(string)
+	return true, fromClass
+}
+
+// DataSourceShorelineObjectList lists the objects of a type (e.g. 'shoreline_actions'), with optional filters and paging.
+func DataSourceShorelineObjectList(configJsStr string, key string) *schema.Resource {
+	objects, object, attributes := parseObjectConfig(configJsStr, key)
+	if object == nil {
+		return nil
+	}
+
+	itemSchema := map[string]*schema.Schema{
+		"name": {Type: schema.TypeString, Computed: true, Description: "The name of the " + key + "."},
+	}
+	params := map[string]*schema.Schema{
+		"name_regex": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Only include the objects with a name matching this regular expression.",
+			ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+				if _, err := regexp.Compile(val.(string)); err != nil {
+					errs = append(errs, fmt.Errorf("%q must be a valid regular expression, got: %s", key, err.Error()))
+				}
+				return
+			},
+		},
+		"limit": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Description: "The maximum number of objects to return (0 for all). Applied client-side: the full list is still fetched from the backend.",
+			ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+				if val.(int) < 0 {
+					errs = append(errs, fmt.Errorf("%q must be >= 0, got: %d", key, val.(int)))
+				}
+				return
+			},
+		},
+		"offset": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Description: "The number of (matching, sorted by name) objects to skip, to slice the result with `limit`. Applied client-side: the full list is still fetched from the backend.",
+			ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+				if val.(int) < 0 {
+					errs = append(errs, fmt.Errorf("%q must be >= 0, got: %d", key, val.(int)))
+				}
+				return
+			},
+		},
+		"names": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The names of the matching objects (in the requested page), sorted.",
+		},
+		"total_count": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The number of matching objects (in all pages).",
+		},
+	}
+	classAttrs := []string{}
+	for _, k := range listKeyAttributes {
+		found, fromClass := listHasAttr(attributes, k)
+		if !found {
+			continue
+		}
+		if fromClass {
+			classAttrs = append(classAttrs, "`"+k+"`")
+		}
+		description := CastToString(GetNestedValueOrDefault(objects, ToKeyPath("docs.attributes."+k), ""))
+		switch k {
+		case "enabled":
+			itemSchema[k] = &schema.Schema{Type: schema.TypeBool, Computed: true, Description: description}
+			params[k] = &schema.Schema{Type: schema.TypeBool, Optional: true, Description: "Only include the enabled (or disabled) objects."}
+		case "labels":
+			itemSchema[k] = &schema.Schema{Type: schema.TypeList, Computed: true, Elem: &schema.Schema{Type: schema.TypeString}, Description: description}
+			params[k] = &schema.Schema{Type: schema.TypeList, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}, Description: "Only include the objects with all of these labels."}
+		case "family":
+			itemSchema[k] = &schema.Schema{Type: schema.TypeString, Computed: true, Description: description}
+			params[k] = &schema.Schema{Type: schema.TypeString, Optional: true, Description: "Only include the objects of this family."}
+		case "resource_query":
+			itemSchema[k] = &schema.Schema{Type: schema.TypeString, Computed: true, Description: description}
+			params[k] = &schema.Schema{Type: schema.TypeString, Optional: true, Description: "Only include the objects with a resource query containing this string."}
+		default:
+			itemSchema[k] = &schema.Schema{Type: schema.TypeString, Computed: true, Description: description}
+		}
+	}
+	if len(classAttrs) > 0 {
+		params["include_class_attributes"] = &schema.Schema{
+			Type:     schema.TypeBool,
+			Optional: true,
+			Description: fmt.Sprintf("Also read the %s of the objects in the requested page (one request per object, so at most %d). "+
+				"Otherwise they are only set when filtering on them.", strings.Join(classAttrs, ", "), listMaxClassReads),
+		}
+	}
+	params["items"] = &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Elem:        &schema.Resource{Schema: itemSchema},
+		Description: "The matching objects (in the requested page), with their key attributes.",
+	}
+
+	objDescription := CastToString(GetNestedValueOrDefault(objects, ToKeyPath("docs.objects."+key), ""))
+	return &schema.Resource{
+		Description: "Lists the existing Shoreline " + key + "s. " + objDescription,
+		ReadContext: dataSourceShorelineObjectListRead(key, attributes),
+		Schema:      params,
+	}
+}
+
+func dataSourceShorelineObjectListRead(typ string, attrs map[string]interface{}) func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		client := meta.(*apiClient)
+		ctx = withResourceLogging(ctx, client, typ, "")
+
+		op := opListAllStatement(typ)
+		js, err := runOpCommandToJson(ctx, client, op)
+		if err != nil {
+			return diag.Errorf("Failed to list %ss: %s", typ, err.Error())
+		}
+		symbols, isArray := GetNestedValueOrDefault(js, ToKeyPath("list_type.symbol"), nil).([]interface{})
+		if !isArray {
+			return diag.Errorf("Failed to list %ss: unexpected result: %s", typ, CastToString(js))
+		}
+
+		// the filters on attributes only in the class need a get_<type>_class per object,
+		// so the ones in the list records are applied first
+		var nameRegex *regexp.Regexp
+		if pattern := d.Get("name_regex").(string); pattern != "" {
+			nameRegex = regexp.MustCompile(pattern)
+		}
+		filters := map[string]interface{}{}
+		for _, k := range listFilterAttributes {
+			val, isSet := d.GetOk(k)
+			if k == "enabled" {
+				// GetOk() ignores zero values, but 'enabled = false' is a filter too
+				val, isSet = d.GetOkExists(k)
+			}
+			if isSet {
+				filters[k] = val
+			}
+		}
+
+		type listItem struct {
+			name    string
+			record  map[string]interface{}
+			stepsJs map[string]interface{}
+		}
+		items := []*listItem{}
+		for _, sym := range symbols {
+			record, isMap := sym.(map[string]interface{})
+			name, isStr := GetNestedValueOrDefault(record, ToKeyPath("attributes.name"), "").(string)
+			if !isMap || !isStr || name == "" {
+				continue
+			}
+			if nameRegex != nil && !nameRegex.MatchString(name) {
+				continue
+			}
+			items = append(items, &listItem{name: name, record: record})
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].name < items[j].name })
+
+		classReads := 0
+		readClass := func(item *listItem) diag.Diagnostics {
+			if item.stepsJs != nil {
+				return nil
+			}
+			classReads += 1
+			if classReads > listMaxClassReads {
+				return diag.Errorf("Too many %ss to read one by one (more than %d), narrow them down with 'name_regex' or 'limit'", typ, listMaxClassReads)
+			}
+			classJs, err := runOpCommandToJson(ctx, client, opGetClassStatement(typ, item.name))
+			if err != nil {
+				return diag.Errorf("Failed to read %s - %s: %s", typ, item.name, err.Error())
+			}
+			item.stepsJs = getNamedObjectFromClassDef(item.name, typ, classJs)
+			return nil
+		}
+		// the class attributes are only read (and returned) when asked for, or filtered on
+		withClass := d.Get("include_class_attributes") == true
+		for k := range filters {
+			if _, fromClass := listHasAttr(attrs, k); fromClass {
+				withClass = true
+			}
+		}
+
+		matches := func(item *listItem, fromClass bool) (bool, diag.Diagnostics) {
+			for k, want := range filters {
+				if _, classAttr := listHasAttr(attrs, k); classAttr != fromClass {
+					continue
+				}
+				if fromClass {
+					if diags := readClass(item); diags != nil {
+						return false, diags
+					}
+				}
+				got := listObjectAttr(ctx, typ, item.name, k, attrs, item.record, item.stepsJs)
+				switch k {
+				case "labels":
+					has := map[interface{}]bool{}
+					for _, label := range CastToArray(got) {
+						has[label] = true
+					}
+					for _, label := range CastToArray(want) {
+						if !has[label] {
+							return false, nil
+						}
+					}
+				case "resource_query":
+					if !strings.Contains(CastToString(got), CastToString(want)) {
+						return false, nil
+					}
+				default:
+					if got != want {
+						return false, nil
+					}
+				}
+			}
+			return true, nil
+		}
+		// cheap (list record) filters first, then the ones that need the class
+		for _, fromClass := range []bool{false, true} {
+			filtered := []*listItem{}
+			for _, item := range items {
+				ok, diags := matches(item, fromClass)
+				if diags != nil {
+					return diags
+				}
+				if ok {
+					filtered = append(filtered, item)
+				}
+			}
+			items = filtered
+		}
+
+		// the backend has no paging for lists, so limit/offset only slice the (full) result here
+		total := len(items)
+		offset, limit := d.Get("offset").(int), d.Get("limit").(int)
+		if offset > len(items) {
+			offset = len(items)
+		}
+		items = items[offset:]
+		if limit > 0 && limit < len(items) {
+			items = items[:limit]
+		}
+
+		names := []interface{}{}
+		out := []interface{}{}
+		for _, item := range items {
+			if withClass {
+				if diags := readClass(item); diags != nil {
+					return diags
+				}
+			}
+			vals := map[string]interface{}{"name": item.name}
+			for _, k := range listKeyAttributes {
+				if found, fromClass := listHasAttr(attrs, k); found && (withClass || !fromClass) {
+					vals[k] = listObjectAttr(ctx, typ, item.name, k, attrs, item.record, item.stepsJs)
+				}
+			}
+			names = append(names, item.name)
+			out = append(out, vals)
+		}
+		logDebug(ctx, logCrud, fmt.Sprintf("Listed %ss: %d matching, %d returned", typ, total, len(out)))
+
+		d.Set("names", names)
+		d.Set("items", out)
+		d.Set("total_count", total)
+		d.SetId(typ + "s")
+		return nil
+	}
+}
+
+// the resources matched by a resource query, e.g. {"list_type": {"resources": [{"name": "ip-10-0-0-1", "type": "HOST", "tags": {...}}]}}
+const resourceQueryResultPath = "list_type.resources"
+
+// parseResourceQueryResult returns the name, type and tags of each resource in a resource query result.
+func parseResourceQueryResult(js map[string]interface{}) ([]map[string]interface{}, error) {
+	resources, isArray := GetNestedValueOrDefault(js, ToKeyPath(resourceQueryResultPath), nil).([]interface{})
+	if !isArray {
+		return nil, fmt.Errorf("the statement didn't return resources (is it a resource query?)")
+	}
+	out := []map[string]interface{}{}
+	for _, res := range resources {
+		name := CastToString(GetNestedValueOrDefault(res, ToKeyPath("name"), ""))
+		if name == "" {
+			continue
+		}
+		tags := map[string]interface{}{}
+		switch tagVals := GetNestedValueOrDefault(res, ToKeyPath("tags"), nil).(type) {
+		case map[string]interface{}:
+			for k, v := range tagVals {
+				tags[k] = CastToString(v)
+			}
+		case []interface{}:
+			// e.g. [{"key": "app", "value": "payments"}]
+			for _, tag := range tagVals {
+				key := CastToString(GetNestedValueOrDefault(tag, ToKeyPath("key"), ""))
+				if key != "" {
+					tags[key] = CastToString(GetNestedValueOrDefault(tag, ToKeyPath("value"), ""))
+				}
+			}
+		}
+		out = append(out, map[string]interface{}{
+			"name": name,
+			"type": strings.ToUpper(CastToString(GetNestedValueOrDefault(res, ToKeyPath("type"), ""))),
+			"tags": tags,
+		})
+	}
+	return out, nil
+}
+
+// DataSourceShorelineResources runs a resource query, e.g. `hosts | app="payments"`, and returns the matching resources.
+func DataSourceShorelineResources() *schema.Resource {
+	return &schema.Resource{
+		Description: "The hosts, pods or containers that a resource query currently matches.",
+		ReadContext: dataSourceShorelineResourcesRead,
+		Schema: map[string]*schema.Schema{
+			"resource_query": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateResourceQuery,
+				Description:  "The resource query to run, e.g. `hosts | app=\"payments\"`.",
+			},
+			"fail_if_empty": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Fail if the query doesn't match any resources, e.g. before enabling a bot that uses it.",
+			},
+			"resources": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The matching resources.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {Type: schema.TypeString, Computed: true, Description: "The name of the resource (e.g. the hostname)."},
+						"type": {Type: schema.TypeString, Computed: true, Description: "The type of the resource (HOST, POD or CONTAINER)."},
+						"tags": {Type: schema.TypeMap, Computed: true, Elem: &schema.Schema{Type: schema.TypeString}, Description: "The tags of the resource."},
+					},
+				},
+			},
+			"names": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "The names of the matching resources.",
+			},
+			"total_count": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The number of matching resources.",
+			},
+		},
+	}
+}
+
+func dataSourceShorelineResourcesRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient)
+	query := d.Get("resource_query").(string)
+	ctx = withStatementKind(withLogging(ctx, client, map[string]interface{}{logFieldResourceType: "resources"}), statementKindResourceQuery)
+
+	js, err := runOpCommandToJson(ctx, client, query)
+	if err != nil {
+		return diag.Errorf("Failed to run resource query '%s': %s", query, err.Error())
+	}
+	resources, err := parseResourceQueryResult(js)
+	if err != nil {
+		return diag.Errorf("Failed to run resource query '%s': %s", query, err.Error())
+	}
+	if len(resources) == 0 && d.Get("fail_if_empty").(bool) {
+		return diag.Errorf("The resource query '%s' doesn't match any resources", query)
+	}
+
+	names := []interface{}{}
+	out := []interface{}{}
+	for _, res := range resources {
+		names = append(names, res["name"])
+		out = append(out, res)
+	}
+	logDebug(ctx, logCrud, fmt.Sprintf("Resource query '%s' matched %d resources", query, len(out)))
+	d.Set("resources", out)
+	d.Set("names", names)
+	d.Set("total_count", len(out))
+	d.SetId(query)
+	return nil
+}
+
+// metricQueryStats summarizes metric values (e.g. the p95 to use as an alarm threshold), all 0 without values.
+func metricQueryStats(values []float64) map[string]interface{} {
+	stats := map[string]interface{}{"min": 0.0, "max": 0.0, "mean": 0.0, "p50": 0.0, "p95": 0.0, "p99": 0.0}
+	if len(values) == 0 {
+		return stats
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	stats["min"] = sorted[0]
+	stats["max"] = sorted[len(sorted)-1]
+	stats["mean"] = sum / float64(len(sorted))
+	stats["p50"] = percentile(sorted, 50)
+	stats["p95"] = percentile(sorted, 95)
+	stats["p99"] = percentile(sorted, 99)
+	return stats
+}
+
+// parseMetricQueryTime parses "now", a time relative to now (e.g. "-7d", see timeSuffixToIntSec()) or an RFC3339 time.
+func parseMetricQueryTime(val string, now time.Time) (time.Time, error) {
+	val = strings.TrimSpace(val)
+	switch {
+	case val == "now":
+		return now, nil
+	case strings.HasPrefix(val, "-") && opDurationRegex.MatchString(val[1:]):
+		return now.Add(-time.Duration(timeSuffixToIntSec(val[1:])) * time.Second), nil
+	}
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return now, fmt.Errorf("must be \"now\", a time relative to now (e.g. \"-7d\") or an RFC3339 time, got: %s", val)
+	}
+	return t, nil
+}
+
+// metricQueryRange is the time range (epoch milliseconds, 0 if unbounded) and step (0 for the backend's
+// resolution) of a metric query's values. The backend's window always ends now, so they're applied to the result.
+type metricQueryRange struct {
+	from int64
+	to   int64
+	step int64
+}
+
+// bucket returns the time to report a value at (the start of its step), or false if it's outside of the range.
+func (r metricQueryRange) bucket(ts int64) (int64, bool) {
+	if (r.from > 0 && ts < r.from) || (r.to > 0 && ts > r.to) {
+		return 0, false
+	}
+	if r.step > 0 {
+		ts -= ts % r.step
+	}
+	return ts, true
+}
+
+// parseMetricQueryResult returns the series of each resource (skipping missing values, averaged per step),
+// and all of their timestamps (sorted and de-duplicated), within the range.
+func parseMetricQueryResult(js map[string]interface{}, rng metricQueryRange) ([]map[string]interface{}, []interface{}, error) {
+	// e.g. {"<kind>": [{"name": "ip-10-0-0-1", "timestamps": [<ms>, ...], "values": [0.5, ...]}]}
+	parent, resources := ExtractResultEntries(js, ToKeyPath("timestamps"))
+	if parent == nil {
+		return nil, nil, fmt.Errorf("the statement didn't return metric values")
+	}
+	timestamps := []interface{}{}
+	for _, ts := range ExtractAlignmentArray(parent, ToKeyPath("timestamps")) {
+		bucket, inRange := rng.bucket(int64(CastToNumber(ts)))
+		if inRange && (len(timestamps) == 0 || timestamps[len(timestamps)-1] != int(bucket)) {
+			timestamps = append(timestamps, int(bucket))
+		}
+	}
+
+	out := []map[string]interface{}{}
+	for _, res := range resources {
+		name := CastToString(GetNestedValueOrDefault(res, ToKeyPath("name"), ""))
+		if name == "" {
+			continue
+		}
+		resTimestamps := CastToArray(GetNestedValueOrDefault(res, ToKeyPath("timestamps"), []interface{}{}))
+		resValues := CastToArray(GetNestedValueOrDefault(res, ToKeyPath("values"), []interface{}{}))
+		series := map[string]interface{}{
+			"name":       name,
+			"type":       strings.ToUpper(CastToString(GetNestedValueOrDefault(res, ToKeyPath("type"), ""))),
+			"timestamps": []interface{}{},
+			"values":     []interface{}{},
+		}
+		buckets := []int64{}
+		sums := map[int64]float64{}
+		counts := map[int64]int{}
+		for i, ts := range resTimestamps {
+			if i >= len(resValues) || resValues[i] == nil {
+				continue
+			}
+			bucket, inRange := rng.bucket(int64(CastToNumber(ts)))
+			if !inRange {
+				continue
+			}
+			if counts[bucket] == 0 {
+				buckets = append(buckets, bucket)
+			}
+			sums[bucket] += CastToNumber(resValues[i])
+			counts[bucket]++
+		}
+		values := []float64{}
+		for _, bucket := range buckets {
+			values = append(values, sums[bucket]/float64(counts[bucket]))
+			series["timestamps"] = append(series["timestamps"].([]interface{}), int(bucket))
+			series["values"] = append(series["values"].([]interface{}), values[len(values)-1])
+		}
+		for k, v := range metricQueryStats(values) {
+			series[k] = v
+		}
+		out = append(out, series)
+	}
+	return out, timestamps, nil
+}
+
+// metricQueryStatsSchema adds the summary statistics of metric values to a schema.
+func metricQueryStatsSchema(sch map[string]*schema.Schema, of string) map[string]*schema.Schema {
+	for stat, desc := range map[string]string{
+		"min":  "The minimum",
+		"max":  "The maximum",
+		"mean": "The mean",
+		"p50":  "The median",
+		"p95":  "The 95th percentile",
+		"p99":  "The 99th percentile",
+	} {
+		sch[stat] = &schema.Schema{Type: schema.TypeFloat, Computed: true, Description: desc + " of " + of + " (0 without values)."}
+	}
+	return sch
+}
+
+// DataSourceShorelineMetricQuery queries a metric (or metric query), optionally of the resources matched by a
+// resource query, over a window or time range, e.g. to base an alarm threshold on the last week's p95.
+func DataSourceShorelineMetricQuery() *schema.Resource {
+	return &schema.Resource{
+		Description: "The values of a metric (or metric query), per resource, over a window or time range.",
+		ReadContext: dataSourceShorelineMetricQueryRead,
+		Schema: metricQueryStatsSchema(map[string]*schema.Schema{
+			"metric": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateMetricQuery,
+				Description:  "The metric to query, by name (e.g. `cpu_usage`), or a metric query, e.g. `metric_query(metric_names=\"cpu_usage\") | app=\"payments\"` or `cpu_usage | app=\"payments\" | mean(60)`.",
+			},
+			"resource_query": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateResourceQuery,
+				Description:  "The resources to query the metric of, e.g. `hosts | app=\"payments\"`. Defaults to all the resources with the metric.",
+			},
+			"window": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ValidateFunc:  validateMetricQueryWindow,
+				ConflictsWith: []string{"start"},
+				Description:   "The time window of the values (up to now), e.g. `30m`, `1h` or `7d`. Defaults to the backend's.",
+			},
+			"start": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ValidateFunc:  validateMetricQueryTime,
+				ConflictsWith: []string{"window"},
+				Description:   "The start of the time range: a time relative to now (e.g. `-7d`) or an RFC3339 time. The values are queried over a window from the start (up to now), and the earlier ones are dropped.",
+			},
+			"end": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateMetricQueryTime,
+				Description:  "The end of the time range: `now`, a time relative to now (e.g. `-1d`) or an RFC3339 time. The later values are dropped. Defaults to now.",
+			},
+			"step": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateMetricQueryWindow,
+				Description:  "The resolution of the values, e.g. `5m` or `1h`: the values of each resource are averaged per step (aligned to multiples of the step since the epoch). Defaults to the backend's resolution.",
+			},
+			"fail_if_empty": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Fail if the query doesn't return any values, e.g. instead of computing a threshold of 0.",
+			},
+			"resources": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The values of each matching resource.",
+				Elem: &schema.Resource{
+					Schema: metricQueryStatsSchema(map[string]*schema.Schema{
+						"name":       {Type: schema.TypeString, Computed: true, Description: "The name of the resource (e.g. the hostname)."},
+						"type":       {Type: schema.TypeString, Computed: true, Description: "The type of the resource (HOST, POD or CONTAINER)."},
+						"timestamps": {Type: schema.TypeList, Computed: true, Elem: &schema.Schema{Type: schema.TypeInt}, Description: "The times of the values (epoch milliseconds)."},
+						"values":     {Type: schema.TypeList, Computed: true, Elem: &schema.Schema{Type: schema.TypeFloat}, Description: "The values, missing values are skipped."},
+					}, "the resource's values"),
+				},
+			},
+			"timestamps": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeInt},
+				Description: "The times of all the resources' values (epoch milliseconds), sorted and de-duplicated.",
+			},
+		}, "all the values"),
+	}
+}
+
+func dataSourceShorelineMetricQueryRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient)
+	metric := d.Get("metric").(string)
+	ctx = withStatementKind(withLogging(ctx, client, map[string]interface{}{logFieldResourceType: "metric_query"}), statementKindMetricQuery)
+
+	now := time.Now()
+	window := d.Get("window").(string)
+	rng := metricQueryRange{step: int64(timeSuffixToIntSec(d.Get("step").(string))) * 1000}
+	to := now
+	if end := d.Get("end").(string); end != "" {
+		var err error
+		if to, err = parseMetricQueryTime(end, now); err != nil {
+			return diag.Errorf("Invalid end: %s", err.Error())
+		}
+		rng.to = to.UnixMilli()
+	}
+	if start := d.Get("start").(string); start != "" {
+		from, err := parseMetricQueryTime(start, now)
+		if err != nil {
+			return diag.Errorf("Invalid start: %s", err.Error())
+		}
+		if !from.Before(to) || !from.Before(now) {
+			return diag.Errorf("The start (%s) must be before the end (%s) and now", from.Format(time.RFC3339), to.Format(time.RFC3339))
+		}
+		// the backend's windows end now
+		window = fmt.Sprintf("%ds", int64(math.Ceil(now.Sub(from).Seconds())))
+		rng.from = from.UnixMilli()
+	}
+	statement := opMetricQueryStatement(d.Get("resource_query").(string), metric, window)
+
+	js, err := runOpCommandToJson(ctx, client, statement)
+	if err != nil {
+		return diag.Errorf("Failed to query metric '%s': %s", metric, err.Error())
+	}
+	resources, timestamps, err := parseMetricQueryResult(js, rng)
+	if err != nil {
+		return diag.Errorf("Failed to query metric '%s': %s", metric, err.Error())
+	}
+
+	out := []interface{}{}
+	values := []float64{}
+	for _, res := range resources {
+		out = append(out, res)
+		for _, v := range res["values"].([]interface{}) {
+			values = append(values, v.(float64))
+		}
+	}
+	if len(values) == 0 && d.Get("fail_if_empty").(bool) {
+		return diag.Errorf("The metric '%s' doesn't have any values for the resources and time range", metric)
+	}
+	logDebug(ctx, logCrud, fmt.Sprintf("Metric query '%s' returned %d values for %d resources", metric, len(values), len(out)))
+	d.Set("resources", out)
+	d.Set("timestamps", timestamps)
+	for k, v := range metricQueryStats(values) {
+		d.Set(k, v)
+	}
+	d.SetId(statement)
+	return nil
+}
+
+// DataSourceShorelineCallerIdentity returns who the provider's credentials belong to, e.g. to add the
+// automation user as an editor, or to check the customer (tenant) before applying.
+func DataSourceShorelineCallerIdentity() *schema.Resource {
+	return &schema.Resource{
+		Description: "The customer and user of the provider's credentials.",
+		ReadContext: dataSourceShorelineCallerIdentityRead,
+		Schema: map[string]*schema.Schema{
+			"customer": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The customer (tenant) of the credentials.",
+			},
+			"user": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The user of the credentials.",
+			},
+			"token_type": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The type of the configured token, `refresh` or `access`.",
+			},
+			"expiry": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "When the configured token expires (epoch seconds), 0 if it doesn't.",
+			},
+			"expiry_time": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "When the configured token expires (RFC3339), empty if it doesn't.",
+			},
+			"url": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The URL of the Shoreline API server.",
+			},
+		},
+	}
+}
+
+func dataSourceShorelineCallerIdentityRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient)
+	ctx = withLogging(ctx, client, map[string]interface{}{logFieldResourceType: "caller_identity"})
+
+	opClient, err := newOpClient(client)
+	if err != nil {
+		return diag.Errorf("Failed to read the caller identity: %s", err.Error())
+	}
+	// the credentials are checked (e.g. the refresh token exchanged), rather than only decoded
+	if _, err := opClient.authData.Tokens.AccessToken(ctx); err != nil {
+		return diag.Errorf("Failed to read the caller identity: %s", err.Error())
+	}
+	// the current token, in case the API server rotated it
+	decoded := DecodeAuthToken(opClient.authData.Tokens.ApiToken())
+	if decoded == nil {
+		return diag.Errorf("Failed to read the caller identity: Invalid auth token.")
+	}
+
+	client.authMu.Lock()
+	url := client.opts.Url
+	client.authMu.Unlock()
+
+	d.Set("customer", decoded.Customer)
+	d.Set("user", decoded.User)
+	d.Set("token_type", decoded.Type)
+	d.Set("expiry", decoded.Expiry)
+	d.Set("expiry_time", "")
+	if decoded.Expiry > 0 {
+		d.Set("expiry_time", time.Unix(decoded.Expiry, 0).UTC().Format(time.RFC3339))
+	}
+	d.Set("url", url)
+	d.SetId(decoded.Customer + ":" + decoded.User)
+	return nil
+}
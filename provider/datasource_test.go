@@ -0,0 +1,474 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider/mockbackend"
+)
+
+func TestDataSourceSchemas(t *testing.T) {
+	p := New("dev")()
+	objects, _, _ := parseObjectConfig(ObjectConfigJsonStr, "action")
+	types := GetNestedValueOrDefault(objects, ToKeyPath("docs.objects"), map[string]interface{}{}).(map[string]interface{})
+	if len(types) == 0 {
+		t.Fatalf("Expected the object types in the config")
+	}
+	for typ := range types {
+		resType := "shoreline_" + typ
+		res := p.ResourcesMap[resType]
+		if res == nil {
+			t.Errorf("Expected a %s resource", resType)
+			continue
+		}
+		ds := p.DataSourcesMap[resType]
+		if ds == nil {
+			t.Errorf("Expected a %s data source", resType)
+			continue
+		}
+		if !ds.Schema["name"].Required {
+			t.Errorf("Expected %s.name to be required", resType)
+		}
+		for key, sch := range res.Schema {
+			dsSch, found := ds.Schema[key]
+			switch {
+			case sch.Sensitive || key == "adopt_existing":
+				if found {
+					t.Errorf("Expected %s.%s not to be in the data source", resType, key)
+				}
+			case !found:
+				t.Errorf("Expected %s.%s in the data source", resType, key)
+			case key != "name" && (!dsSch.Computed || dsSch.Required || dsSch.Optional || dsSch.Type != sch.Type):
+				t.Errorf("Expected %s.%s to be computed (%v), got: %+v", resType, key, sch.Type, dsSch)
+			}
+		}
+	}
+	if err := p.InternalValidate(); err != nil {
+		t.Errorf("Invalid provider schema: %s", err)
+	}
+}
+
+func TestMockDataSourceRead(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_action"
+	testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":        name,
+		"command":     "`hostname`",
+		"description": "shared action",
+		"timeout":     30,
+	})
+
+	ds := p.DataSourcesMap["shoreline_action"]
+	d := schema.TestResourceDataRaw(t, ds.Schema, map[string]interface{}{"name": name})
+	if diags := ds.ReadContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("Failed to read the action: %+v", diags)
+	}
+	if d.Id() != name || d.Get("command") != "`hostname`" || d.Get("description") != "shared action" || d.Get("timeout") != 30 {
+		t.Errorf("Unexpected data source attributes: %+v", d.State())
+	}
+
+	missing := schema.TestResourceDataRaw(t, ds.Schema, map[string]interface{}{"name": RandomAlphaPrefix(5) + "_missing"})
+	diags := ds.ReadContext(context.Background(), missing, meta)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "Failed to find action") {
+		t.Errorf("Expected a 'not found' error, got: %+v", diags)
+	}
+}
+
+func TestMockDataSourceSkipsSensitive(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_integration"
+	secret := "integration-secret-" + RandomAlphaPrefix(8)
+	testMockCreate(t, p, meta, "shoreline_integration", map[string]interface{}{
+		"name":          name,
+		"service_name":  "datadog",
+		"serial_number": "123",
+		"api_key":       secret,
+	})
+
+	ds := p.DataSourcesMap["shoreline_integration"]
+	d := schema.TestResourceDataRaw(t, ds.Schema, map[string]interface{}{"name": name})
+	if diags := ds.ReadContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("Failed to read the integration: %+v", diags)
+	}
+	if d.Get("service_name") != "datadog" || d.Get("serial_number") != "123" {
+		t.Errorf("Unexpected data source attributes: %+v", d.State())
+	}
+	for key, val := range d.State().Attributes {
+		if strings.Contains(val, secret) {
+			t.Errorf("Expected no sensitive values in the data source, got %s = %s", key, val)
+		}
+	}
+}
+
+func testMockListRead(t *testing.T, p *schema.Provider, meta interface{}, dsType string, raw map[string]interface{}) *schema.ResourceData {
+	ds := p.DataSourcesMap[dsType]
+	d := schema.TestResourceDataRaw(t, ds.Schema, raw)
+	if diags := ds.ReadContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("Failed to read %s: %+v", dsType, diags)
+	}
+	return d
+}
+
+func TestMockDataSourceList(t *testing.T) {
+	p, meta := testMockProvider(t)
+	prefix := RandomAlphaPrefix(5)
+	mockServer.PutObject("alarm", prefix+"_a1", map[string]interface{}{"fire_query": "cpu_usage > 1", "enabled": true, "family": "custom", "resource_query": "host | app='web'"})
+	mockServer.PutObject("alarm", prefix+"_a2", map[string]interface{}{"fire_query": "cpu_usage > 2", "enabled": false, "family": "custom", "resource_query": "host | app='db'"})
+	mockServer.PutObject("alarm", prefix+"_b3", map[string]interface{}{"fire_query": "cpu_usage > 3", "enabled": true, "family": "system", "resource_query": "pod | app='web'"})
+	nameRegex := "^" + prefix + "_"
+
+	tests := []struct {
+		filters map[string]interface{}
+		names   []string
+	}{
+		{map[string]interface{}{}, []string{"_a1", "_a2", "_b3"}},
+		{map[string]interface{}{"name_regex": nameRegex + "a"}, []string{"_a1", "_a2"}},
+		{map[string]interface{}{"enabled": true}, []string{"_a1", "_b3"}},
+		{map[string]interface{}{"enabled": false}, []string{"_a2"}},
+		{map[string]interface{}{"family": "system"}, []string{"_b3"}},
+		{map[string]interface{}{"resource_query": "app='web'"}, []string{"_a1", "_b3"}},
+		{map[string]interface{}{"resource_query": "app='web'", "family": "custom"}, []string{"_a1"}},
+	}
+	for _, tc := range tests {
+		if _, hasRegex := tc.filters["name_regex"]; !hasRegex {
+			tc.filters["name_regex"] = nameRegex
+		}
+		d := testMockListRead(t, p, meta, "shoreline_alarms", tc.filters)
+		want := []interface{}{}
+		for _, n := range tc.names {
+			want = append(want, prefix+n)
+		}
+		if got := d.Get("names").([]interface{}); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v for %v, got %v", want, tc.filters, got)
+		}
+		if d.Get("total_count").(int) != len(want) {
+			t.Errorf("Expected a total of %d for %v, got %v", len(want), tc.filters, d.Get("total_count"))
+		}
+	}
+
+	d := testMockListRead(t, p, meta, "shoreline_alarms", map[string]interface{}{"name_regex": nameRegex, "family": "custom"})
+	if d.Get("items.0.name") != prefix+"_a1" || d.Get("items.0.enabled") != true || d.Get("items.0.family") != "custom" || d.Get("items.0.resource_query") != "host | app='web'" {
+		t.Errorf("Unexpected item attributes: %+v", d.Get("items"))
+	}
+}
+
+func TestMockDataSourceListPaging(t *testing.T) {
+	p, meta := testMockProvider(t)
+	prefix := RandomAlphaPrefix(5)
+	for _, n := range []string{"_1", "_2", "_3", "_4", "_5"} {
+		mockServer.PutObject("action", prefix+n, map[string]interface{}{"command": "`hostname`"})
+	}
+	mockServer.ResetRequests()
+
+	d := testMockListRead(t, p, meta, "shoreline_actions", map[string]interface{}{"name_regex": "^" + prefix, "offset": 1, "limit": 2})
+	if got := d.Get("names").([]interface{}); !reflect.DeepEqual(got, []interface{}{prefix + "_2", prefix + "_3"}) {
+		t.Errorf("Expected the 2nd page of 2, got %v", got)
+	}
+	if d.Get("total_count") != 5 {
+		t.Errorf("Expected a total of 5, got %v", d.Get("total_count"))
+	}
+	// only the objects in the page are read (for their class attributes)
+	classReads := 0
+	for _, stmt := range mockServer.Statements() {
+		if strings.HasPrefix(stmt, "get_action_class(") {
+			classReads += 1
+		}
+	}
+	if classReads > 2 {
+		t.Errorf("Expected at most 2 class reads, got %d", classReads)
+	}
+
+	d = testMockListRead(t, p, meta, "shoreline_actions", map[string]interface{}{"name_regex": "^" + prefix, "offset": 10})
+	if got := d.Get("names").([]interface{}); len(got) != 0 || d.Get("total_count") != 5 {
+		t.Errorf("Expected an empty page past the end, got %v", got)
+	}
+}
+
+func TestMockDataSourceListClassReads(t *testing.T) {
+	p, meta := testMockProvider(t)
+	prefix := RandomAlphaPrefix(5)
+	for _, n := range []string{"_1", "_2", "_3"} {
+		mockServer.PutObject("alarm", prefix+n, map[string]interface{}{"fire_query": "cpu_usage > 1", "family": "custom"})
+	}
+	countClassReads := func() int {
+		reads := 0
+		for _, stmt := range mockServer.Statements() {
+			if strings.HasPrefix(stmt, "get_alarm_class(") {
+				reads += 1
+			}
+		}
+		return reads
+	}
+
+	// the (per object) class is only read when asked for
+	mockServer.ResetRequests()
+	d := testMockListRead(t, p, meta, "shoreline_alarms", map[string]interface{}{"name_regex": "^" + prefix})
+	if reads := countClassReads(); reads != 0 || d.Get("items.0.family") != "" {
+		t.Errorf("Expected no class reads (or family), got %d (%v)", reads, d.Get("items.0.family"))
+	}
+	mockServer.ResetRequests()
+	d = testMockListRead(t, p, meta, "shoreline_alarms", map[string]interface{}{"name_regex": "^" + prefix, "include_class_attributes": true, "limit": 2})
+	if reads := countClassReads(); reads != 2 || d.Get("items.1.family") != "custom" {
+		t.Errorf("Expected a class read per object in the page, got %d (%v)", reads, d.Get("items"))
+	}
+
+	defer func(max int) { listMaxClassReads = max }(listMaxClassReads)
+	listMaxClassReads = 2
+	ds := p.DataSourcesMap["shoreline_alarms"]
+	tooMany := schema.TestResourceDataRaw(t, ds.Schema, map[string]interface{}{"name_regex": "^" + prefix, "family": "custom"})
+	if diags := ds.ReadContext(context.Background(), tooMany, meta); !diags.HasError() || !strings.Contains(diags[0].Summary, "Too many alarms") {
+		t.Errorf("Expected too many class reads to fail, got: %+v", diags)
+	}
+}
+
+func TestMockDataSourceListLabels(t *testing.T) {
+	p, meta := testMockProvider(t)
+	prefix := RandomAlphaPrefix(5)
+	mockServer.PutObject("notebook", prefix+"_r1", map[string]interface{}{"labels": []interface{}{"team-a", "prod"}})
+	mockServer.PutObject("notebook", prefix+"_r2", map[string]interface{}{"labels": []interface{}{"team-a"}})
+
+	d := testMockListRead(t, p, meta, "shoreline_runbooks", map[string]interface{}{"name_regex": "^" + prefix, "labels": []interface{}{"prod", "team-a"}})
+	if got := d.Get("names").([]interface{}); !reflect.DeepEqual(got, []interface{}{prefix + "_r1"}) {
+		t.Errorf("Expected the runbook with both labels, got %v", got)
+	}
+	if got := d.Get("items.0.labels").([]interface{}); len(got) != 2 {
+		t.Errorf("Expected the runbook labels, got %v", got)
+	}
+}
+
+func TestMockDataSourceResources(t *testing.T) {
+	p, meta := testMockProvider(t)
+	defer mockServer.ResetResources()
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-1", Type: "HOST", Tags: map[string]string{"app": "payments", "az": "us-west-2a"}})
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-2", Type: "HOST", Tags: map[string]string{"app": "payments", "az": "us-west-2b"}})
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-3", Type: "HOST", Tags: map[string]string{"app": "books"}})
+	mockServer.PutResource(mockbackend.Resource{Name: "payments-7d9f", Type: "POD", Tags: map[string]string{"app": "payments"}})
+
+	d := testMockListRead(t, p, meta, "shoreline_resources", map[string]interface{}{"resource_query": `hosts | app="payments"`})
+	if got := d.Get("names").([]interface{}); !reflect.DeepEqual(got, []interface{}{"ip-10-0-0-1", "ip-10-0-0-2"}) {
+		t.Errorf("Expected the payments hosts, got %v", got)
+	}
+	if d.Get("total_count") != 2 || d.Get("resources.0.type") != "HOST" || d.Get("resources.1.tags.az") != "us-west-2b" {
+		t.Errorf("Unexpected resources: %+v", d.Get("resources"))
+	}
+
+	d = testMockListRead(t, p, meta, "shoreline_resources", map[string]interface{}{"resource_query": `pods | app="payments"`})
+	if d.Get("total_count") != 1 || d.Get("resources.0.name") != "payments-7d9f" || d.Get("resources.0.type") != "POD" {
+		t.Errorf("Unexpected resources: %+v", d.Get("resources"))
+	}
+
+	ds := p.DataSourcesMap["shoreline_resources"]
+	empty := schema.TestResourceDataRaw(t, ds.Schema, map[string]interface{}{"resource_query": `hosts | app="missing"`, "fail_if_empty": true})
+	if diags := ds.ReadContext(context.Background(), empty, meta); !diags.HasError() || !strings.Contains(diags[0].Summary, "doesn't match any resources") {
+		t.Errorf("Expected an empty query to fail, got: %+v", diags)
+	}
+	notResources := schema.TestResourceDataRaw(t, ds.Schema, map[string]interface{}{"resource_query": "backend_version"})
+	if diags := ds.ReadContext(context.Background(), notResources, meta); !diags.HasError() || !strings.Contains(diags[0].Summary, "resource query") {
+		t.Errorf("Expected a non-resource statement to fail, got: %+v", diags)
+	}
+}
+
+func TestMockDataSourceResourceObjects(t *testing.T) {
+	p, meta := testMockProvider(t)
+	prefix := RandomAlphaPrefix(5)
+	mockServer.PutObject("resource", prefix+"_r1", map[string]interface{}{"value": "host"})
+
+	// the 'shoreline_resource' objects are listed separately from the live resources
+	d := testMockListRead(t, p, meta, "shoreline_resource_objects", map[string]interface{}{"name_regex": "^" + prefix})
+	if got := d.Get("names").([]interface{}); !reflect.DeepEqual(got, []interface{}{prefix + "_r1"}) {
+		t.Errorf("Expected the resource object, got %v", got)
+	}
+}
+
+func TestDataSourceResourcesValidation(t *testing.T) {
+	validate := New("dev")().DataSourcesMap["shoreline_resources"].Schema["resource_query"].ValidateFunc
+	for stmt, want := range map[string]string{
+		`hosts | app="payments"`:                 "",
+		"host | pod | app='bookstore' | limit=1": "",
+		"":                                       "must not be empty",
+		"hosts | `rm -rf /tmp/x`":                "shell command at column 9",
+		"hosts; delete a1":                       "';' at column 6",
+		"if hosts then a1 fi":                    "'if' at column 1",
+		"hosts | app='x":                         "not a valid op statement",
+
+		// anything but resource types and filters could run something
+		"disable bot1":                "'disable' at column 1",
+		"hosts | delete a1":           "'delete' at column 9",
+		"hosts | my_action(x=1)":      "'my_action' at column 9",
+		`host | "rm -rf /tmp/x"`:      "at column 8",
+		`hosts | app="a" | limit=1 x`: "invalid limit at column 19",
+		`hosts | region=~"us-.*" | pods | namespace=["a", "b"]`: "",
+	} {
+		_, errs := validate(stmt, "resource_query")
+		switch {
+		case want == "" && len(errs) != 0:
+			t.Errorf("Expected %q to be valid, got: %v", stmt, errs)
+		case want != "" && (len(errs) != 1 || !strings.Contains(errs[0].Error(), want)):
+			t.Errorf("Expected %q to fail with %q, got: %v", stmt, want, errs)
+		}
+	}
+}
+
+func TestMockDataSourceMetricQuery(t *testing.T) {
+	p, meta := testMockProvider(t)
+	defer mockServer.ResetResources()
+	now := time.Now().UnixMilli()
+	old := now - 2*3600*1000
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-1", Type: "HOST", Tags: map[string]string{"app": "payments"}, Metrics: map[string][]mockbackend.MetricPoint{
+		"cpu_usage": {{Time: old, Value: 99}, {Time: now - 120000, Value: 10}, {Time: now - 60000, Value: 30}},
+	}})
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-2", Type: "HOST", Tags: map[string]string{"app": "payments"}, Metrics: map[string][]mockbackend.MetricPoint{
+		"cpu_usage": {{Time: now - 60000, Value: 20}},
+	}})
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-3", Type: "HOST", Tags: map[string]string{"app": "books"}, Metrics: map[string][]mockbackend.MetricPoint{
+		"cpu_usage": {{Time: now - 60000, Value: 90}},
+	}})
+
+	d := testMockListRead(t, p, meta, "shoreline_metric_query", map[string]interface{}{
+		"metric": `metric_query(metric_names="cpu_usage") | app="payments"`,
+		"window": "1h",
+	})
+	if got := d.Get("timestamps").([]interface{}); !reflect.DeepEqual(got, []interface{}{int(now - 120000), int(now - 60000)}) {
+		t.Errorf("Expected the timestamps of the last hour, got %v", got)
+	}
+	if got := d.Get("resources.0.values").([]interface{}); d.Get("resources.0.name") != "ip-10-0-0-1" || !reflect.DeepEqual(got, []interface{}{10.0, 30.0}) {
+		t.Errorf("Unexpected values: %+v", d.Get("resources"))
+	}
+	if d.Get("resources.1.timestamps.0") != int(now-60000) || d.Get("resources.1.p95") != 20.0 {
+		t.Errorf("Unexpected values: %+v", d.Get("resources"))
+	}
+	if d.Get("min") != 10.0 || d.Get("max") != 30.0 || d.Get("mean") != 20.0 || d.Get("p95") != 30.0 {
+		t.Errorf("Unexpected stats: min %v, max %v, mean %v, p95 %v", d.Get("min"), d.Get("max"), d.Get("mean"), d.Get("p95"))
+	}
+
+	d = testMockListRead(t, p, meta, "shoreline_metric_query", map[string]interface{}{
+		"metric": "cpu_usage",
+		"window": "3h",
+	})
+	if got := d.Get("resources").([]interface{}); len(got) != 3 || d.Get("max") != 99.0 {
+		t.Errorf("Expected the values of all the resources over the last 3 hours, got %v", got)
+	}
+	if stmt := mockServer.Statements()[len(mockServer.Statements())-1]; stmt != `metric_query(metric_names = "cpu_usage") | window(3h)` {
+		t.Errorf("Unexpected statement: %s", stmt)
+	}
+
+	// the time range is applied to the values of the window up to now
+	d = testMockListRead(t, p, meta, "shoreline_metric_query", map[string]interface{}{
+		"metric":         "cpu_usage",
+		"resource_query": `hosts | app="payments"`,
+		"start":          "-3h",
+		"end":            "-90s",
+	})
+	if stmt := mockServer.Statements()[len(mockServer.Statements())-1]; stmt != `hosts | app="payments" | metric_query(metric_names = "cpu_usage") | window(10800s)` {
+		t.Errorf("Unexpected statement: %s", stmt)
+	}
+	if got := d.Get("resources.0.values").([]interface{}); len(d.Get("resources").([]interface{})) != 2 || !reflect.DeepEqual(got, []interface{}{99.0, 10.0}) {
+		t.Errorf("Expected the payments hosts' values up to 90s ago, got %+v", d.Get("resources"))
+	}
+	if got := d.Get("timestamps").([]interface{}); !reflect.DeepEqual(got, []interface{}{int(old), int(now - 120000)}) || d.Get("resources.1.values.#") != 0 {
+		t.Errorf("Expected the timestamps of the time range, got %v, %+v", got, d.Get("resources"))
+	}
+
+	ds := p.DataSourcesMap["shoreline_metric_query"]
+	empty := schema.TestResourceDataRaw(t, ds.Schema, map[string]interface{}{"metric": `cpu_usage | app="none"`, "fail_if_empty": true})
+	if diags := ds.ReadContext(context.Background(), empty, meta); !diags.HasError() || !strings.Contains(diags[0].Summary, "doesn't have any values") {
+		t.Errorf("Expected a query without values to fail, got: %+v", diags)
+	}
+}
+
+func TestDataSourceMetricQueryValidation(t *testing.T) {
+	sch := New("dev")().DataSourcesMap["shoreline_metric_query"].Schema
+	for val, want := range map[string]string{
+		"cpu_usage": "",
+		`metric_query(metric_names="elasticsearch_cluster_health_status") | color="red"`: "",
+		"cpu_usage | window(60s) | mean(60)":                                             "",
+		`cpu_usage | app=~"pay.*" | max(5)`:                                              "",
+		"hosts":                                                                          "instead of a metric",
+		"disable bot1":                                                                   "'disable' at column 1",
+		"cpu_usage | my_action(x=1)":                                                     "'my_action' at column 13",
+		"cpu_usage | `rm -rf /tmp/x`":                                                    "at column 13",
+		`metric_query(metric_names="cpu", x=1)`:                                          "invalid metric_query arguments",
+		"cpu_usage | window(60s, 1)":                                                     "invalid window arguments",
+	} {
+		_, errs := sch["metric"].ValidateFunc(val, "metric")
+		switch {
+		case want == "" && len(errs) != 0:
+			t.Errorf("Expected %q to be valid, got: %v", val, errs)
+		case want != "" && (len(errs) != 1 || !strings.Contains(errs[0].Error(), want)):
+			t.Errorf("Expected %q to fail with %q, got: %v", val, want, errs)
+		}
+	}
+	for val, valid := range map[string]bool{"30s": true, "5m": true, "1h": true, "60": true, "0s": false, "5w": false, "-1m": false} {
+		if _, errs := sch["window"].ValidateFunc(val, "window"); valid != (len(errs) == 0) {
+			t.Errorf("Expected window %q to be valid: %v, got: %v", val, valid, errs)
+		}
+		if _, errs := sch["step"].ValidateFunc(val, "step"); valid != (len(errs) == 0) {
+			t.Errorf("Expected step %q to be valid: %v, got: %v", val, valid, errs)
+		}
+	}
+	for val, valid := range map[string]bool{"now": true, "-7d": true, "-90s": true, "2024-01-02T03:04:05Z": true, "7d": false, "yesterday": false} {
+		if _, errs := sch["start"].ValidateFunc(val, "start"); valid != (len(errs) == 0) {
+			t.Errorf("Expected start %q to be valid: %v, got: %v", val, valid, errs)
+		}
+	}
+	if _, errs := sch["resource_query"].ValidateFunc("disable bot1", "resource_query"); len(errs) == 0 {
+		t.Errorf("Expected a resource query that isn't one to be invalid")
+	}
+}
+
+func TestMetricQueryResultRange(t *testing.T) {
+	js := map[string]interface{}{"metric_query": []interface{}{
+		map[string]interface{}{"name": "h1", "type": "host", "timestamps": []interface{}{1000.0, 61000.0, 119000.0, 121000.0, 200000.0}, "values": []interface{}{1.0, 2.0, 4.0, nil, 8.0}},
+	}}
+	// [61s, 200s) in 1m steps
+	resources, timestamps, err := parseMetricQueryResult(js, metricQueryRange{from: 61000, to: 199999, step: 60000})
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	if !reflect.DeepEqual(timestamps, []interface{}{60000, 120000}) {
+		t.Errorf("Unexpected timestamps: %v", timestamps)
+	}
+	if got := resources[0]; !reflect.DeepEqual(got["timestamps"], []interface{}{60000}) || !reflect.DeepEqual(got["values"], []interface{}{3.0}) || got["type"] != "HOST" {
+		t.Errorf("Expected the values in range, averaged per step, got: %+v", got)
+	}
+}
+
+func TestMockDataSourceCallerIdentity(t *testing.T) {
+	p, meta := testMockProvider(t)
+	d := testMockListRead(t, p, meta, "shoreline_caller_identity", map[string]interface{}{})
+	if d.Get("customer") != "test_customer" || d.Get("user") != "test_user@shoreline.io" || d.Get("token_type") != "refresh" || d.Get("url") != mockServer.URL {
+		t.Errorf("Unexpected identity: %s, %s, %s, %s", d.Get("customer"), d.Get("user"), d.Get("token_type"), d.Get("url"))
+	}
+	if expiry, err := time.Parse(time.RFC3339, d.Get("expiry_time").(string)); err != nil || expiry.Unix() != int64(d.Get("expiry").(int)) || expiry.Before(time.Now()) {
+		t.Errorf("Unexpected expiry: %v, %v", d.Get("expiry"), d.Get("expiry_time"))
+	}
+	if d.Id() != "test_customer:test_user@shoreline.io" {
+		t.Errorf("Unexpected ID: %s", d.Id())
+	}
+
+	// an access token is used as is, and must not have expired
+	expired := mockServer.NewToken("access", "test_customer", "ci@shoreline.io", time.Now().Add(-time.Minute))
+	p, meta = testMockProviderWithConfig(t, map[string]interface{}{"token": expired})
+	ds := p.DataSourcesMap["shoreline_caller_identity"]
+	d = schema.TestResourceDataRaw(t, ds.Schema, map[string]interface{}{})
+	if diags := ds.ReadContext(context.Background(), d, meta); !diags.HasError() || !strings.Contains(diags[0].Summary, "has expired") {
+		t.Errorf("Expected expired credentials to fail, got: %+v", diags)
+	}
+}
+
+func TestDecodeAuthTokenWithoutExpiry(t *testing.T) {
+	token := "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(`{"aud":"access","cst":"c1","sub":"u1"}`)) + ".c2ln"
+	decoded := DecodeAuthToken(token)
+	if decoded == nil || decoded.Customer != "c1" || decoded.User != "u1" || decoded.Expiry != 0 {
+		t.Errorf("Unexpected decoded token: %+v", decoded)
+	}
+}
@@ -18,7 +18,8 @@ import (
 var debug = true
 
 func WriteMsg(format string, a ...interface{}) {
-	msg := fmt.Sprintf(format, a...)
+	// secrets are scrubbed, as for the log (see redactSecrets())
+	msg := redactSecrets(fmt.Sprintf(format, a...))
 	fmt.Print(msg)
 	//UpdateSessionLogging()
 	//if sessionLog != nil {
@@ -517,6 +518,32 @@ func SortAndDedupArray(arr []interface{}) []interface{} {
 	return MapToSortedDedupedArray(elementMap)
 }
 
+// This is used to find the entries (e.g. the series of metrics, or per-resource outputs) of a statement's result:
+// the objects with a value under 'key' (e.g. timestamps) in its top-level arrays, optionally nested under the
+// statement's result key. The returned parent (the object holding the arrays) is nil if there aren't any arrays.
+func ExtractResultEntries(js map[string]interface{}, key []string) (parent map[string]interface{}, entries []interface{}) {
+	for _, val := range js {
+		arr, isArray := val.([]interface{})
+		if !isArray {
+			continue
+		}
+		parent = js
+		for _, v := range arr {
+			if GetNestedValueOrDefault(v, key, nil) != nil {
+				entries = append(entries, v)
+			}
+		}
+	}
+	if parent == nil && len(js) == 1 {
+		for _, val := range js {
+			if inner, isMap := val.(map[string]interface{}); isMap {
+				return ExtractResultEntries(inner, key)
+			}
+		}
+	}
+	return parent, entries
+}
+
 // This is used to extract arrays (e.g. timestamps) from a set of top-level objects (e.g. metrics).
 // The returned data (an array) is each of the sub-arrays combined, with values de-duped and sorted.
 func ExtractAlignmentArray(js interface{}, align_key []string) []interface{} {
@@ -0,0 +1,294 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Log subsystems, each with its own level, e.g. TF_LOG_PROVIDER_SHORELINE_HTTP=trace
+const (
+	logHttp   = "http"
+	logAuth   = "auth"
+	logSchema = "schema"
+	logCrud   = "crud"
+)
+
+var logSubsystems = []string{logHttp, logAuth, logSchema, logCrud}
+
+// Structured fields of the log entries.
+const (
+	logFieldResourceType = "resource_type"
+	logFieldResourceName = "resource_name"
+	logFieldStatement    = "statement"
+	logFieldRetry        = "retry"
+	logFieldError        = "error"
+)
+
+type logLevel int
+
+const (
+	logLevelTrace logLevel = iota
+	logLevelDebug
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+)
+
+var logLevelNames = []string{"trace", "debug", "info", "warn", "error"}
+
+func (level logLevel) String() string {
+	return logLevelNames[level]
+}
+
+func parseLogLevel(name string) (logLevel, bool) {
+	for i, n := range logLevelNames {
+		if strings.EqualFold(name, n) {
+			return logLevel(i), true
+		}
+	}
+	return logLevelInfo, false
+}
+
+const (
+	defaultLogFileName   = "tf-shoreline.log"
+	defaultLogMaxSizeMb  = 10
+	defaultLogMaxBackups = 3
+)
+
+func defaultLogFile() string {
+	return filepath.Join(os.TempDir(), defaultLogFileName)
+}
+
+type logContextKey struct{}
+
+// logContext is the provider specific logging state carried in a context.
+type logContext struct {
+	sink   *logFileSink
+	fields map[string]interface{}
+}
+
+// withLogging sets up the log subsystems (for tflog), the file sink of 'client',
+// and 'fields' for all entries logged with the returned context.
+func withLogging(ctx context.Context, client *apiClient, fields map[string]interface{}) context.Context {
+	prev, hasPrev := ctx.Value(logContextKey{}).(*logContext)
+	lc := &logContext{fields: map[string]interface{}{}}
+	if hasPrev {
+		lc.sink = prev.sink
+		for k, v := range prev.fields {
+			lc.fields[k] = v
+		}
+	} else {
+		for _, sub := range logSubsystems {
+			// the offset skips the log*() helpers below
+			ctx = tflog.NewSubsystem(ctx, sub,
+				tflog.WithLevelFromEnv("TF_LOG_PROVIDER_SHORELINE", strings.ToUpper(sub)),
+				tflog.WithAdditionalLocationOffset(3))
+		}
+	}
+	if client != nil {
+		lc.sink = client.logSink
+	}
+	for k, v := range fields {
+		lc.fields[k] = v
+	}
+	return context.WithValue(ctx, logContextKey{}, lc)
+}
+
+// withResourceLogging sets up logging for an operation on a resource.
+func withResourceLogging(ctx context.Context, client *apiClient, typ string, name string) context.Context {
+	return withLogging(ctx, client, map[string]interface{}{logFieldResourceType: typ, logFieldResourceName: name})
+}
+
+func logTrace(ctx context.Context, subsystem string, msg string, fields ...map[string]interface{}) {
+	writeLog(ctx, logLevelTrace, subsystem, msg, fields)
+}
+
+func logDebug(ctx context.Context, subsystem string, msg string, fields ...map[string]interface{}) {
+	writeLog(ctx, logLevelDebug, subsystem, msg, fields)
+}
+
+func logInfo(ctx context.Context, subsystem string, msg string, fields ...map[string]interface{}) {
+	writeLog(ctx, logLevelInfo, subsystem, msg, fields)
+}
+
+func logWarn(ctx context.Context, subsystem string, msg string, fields ...map[string]interface{}) {
+	writeLog(ctx, logLevelWarn, subsystem, msg, fields)
+}
+
+func logError(ctx context.Context, subsystem string, msg string, fields ...map[string]interface{}) {
+	writeLog(ctx, logLevelError, subsystem, msg, fields)
+}
+
+func writeLog(ctx context.Context, level logLevel, subsystem string, msg string, fields []map[string]interface{}) {
+	lc, hasLc := ctx.Value(logContextKey{}).(*logContext)
+	if !hasLc {
+		// e.g. DiffSuppressFunc callbacks, which don't get a context (or the provider meta),
+		// so there's no log file to write to
+		lc = &logContext{}
+	}
+	// secrets are scrubbed from everything logged, see redactSecrets()
+	msg = redactSecrets(msg)
+	all := make(map[string]interface{}, len(lc.fields))
+	for k, v := range lc.fields {
+		all[k] = redactValue(v)
+	}
+	for _, f := range fields {
+		for k, v := range f {
+			all[k] = redactValue(v)
+		}
+	}
+
+	switch level {
+	case logLevelTrace:
+		tflog.SubsystemTrace(ctx, subsystem, msg, all)
+	case logLevelDebug:
+		tflog.SubsystemDebug(ctx, subsystem, msg, all)
+	case logLevelInfo:
+		tflog.SubsystemInfo(ctx, subsystem, msg, all)
+	case logLevelWarn:
+		tflog.SubsystemWarn(ctx, subsystem, msg, all)
+	default:
+		tflog.SubsystemError(ctx, subsystem, msg, all)
+	}
+	lc.sink.write(time.Now(), level, subsystem, msg, all)
+}
+
+// logFileSink appends JSON log entries to a file, rotating it when it reaches 'maxSize'.
+// Provider instances logging to the same path share a sink. A nil sink discards everything.
+type logFileSink struct {
+	path string
+
+	mu         sync.Mutex
+	level      logLevel
+	maxSize    int64 // bytes, zero for no rotation
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+var (
+	logFileSinksMu sync.Mutex
+	logFileSinks   = map[string]*logFileSink{}
+)
+
+// openLogFileSink returns the sink for 'path', (re)configured with the given level and rotation.
+func openLogFileSink(path string, level logLevel, maxSizeMb int, maxBackups int) *logFileSink {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	logFileSinksMu.Lock()
+	sink, found := logFileSinks[path]
+	if !found {
+		sink = &logFileSink{path: path}
+		logFileSinks[path] = sink
+	}
+	logFileSinksMu.Unlock()
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.level = level
+	sink.maxSize = int64(maxSizeMb) * 1024 * 1024
+	sink.maxBackups = maxBackups
+	return sink
+}
+
+func (sink *logFileSink) write(ts time.Time, level logLevel, subsystem string, msg string, fields map[string]interface{}) {
+	if sink == nil {
+		return
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if level < sink.level {
+		return
+	}
+
+	entry := make(map[string]interface{}, len(fields)+4)
+	for k, v := range fields {
+		entry[k] = v
+	}
+	entry["@timestamp"] = ts.Format(time.RFC3339Nano)
+	entry["@level"] = level.String()
+	entry["@module"] = "shoreline." + subsystem
+	entry["@message"] = msg
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]interface{}{
+			"@timestamp": entry["@timestamp"],
+			"@level":     entry["@level"],
+			"@module":    entry["@module"],
+			"@message":   fmt.Sprintf("%s (unencodable fields: %s)", msg, err),
+		})
+	}
+	line = append(line, '\n')
+
+	if sink.file == nil && !sink.open() {
+		// logging is best-effort
+		return
+	}
+	if sink.maxSize > 0 && sink.size > 0 && sink.size+int64(len(line)) > sink.maxSize {
+		sink.rotate()
+		if !sink.open() {
+			return
+		}
+	}
+	n, _ := sink.file.Write(line)
+	sink.size += int64(n)
+}
+
+func (sink *logFileSink) open() bool {
+	f, err := os.OpenFile(sink.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return false
+	}
+	sink.file = f
+	sink.size = 0
+	if info, err := f.Stat(); err == nil {
+		sink.size = info.Size()
+	}
+	return true
+}
+
+// rotate closes the current file, and shifts the backups: path -> path.1 -> path.2 ...
+// The caller holds sink.mu, and reopens the file.
+func (sink *logFileSink) rotate() {
+	if sink.file != nil {
+		sink.file.Close()
+		sink.file = nil
+	}
+	sink.size = 0
+	if sink.maxBackups <= 0 {
+		os.Remove(sink.path)
+		return
+	}
+	os.Remove(fmt.Sprintf("%s.%d", sink.path, sink.maxBackups))
+	for i := sink.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", sink.path, i), fmt.Sprintf("%s.%d", sink.path, i+1))
+	}
+	os.Rename(sink.path, sink.path+".1")
+}
+
+// Close flushes and closes the log file (it's reopened on the next write).
+func (sink *logFileSink) Close() error {
+	if sink == nil {
+		return nil
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.file == nil {
+		return nil
+	}
+	err := sink.file.Close()
+	sink.file = nil
+	return err
+}
@@ -0,0 +1,122 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReadLogEntries(t *testing.T, path string) []map[string]interface{} {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %s", err)
+	}
+	defer f.Close()
+	entries := []map[string]interface{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Expected JSON log entries, got %q: %s", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLogFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	sink := openLogFileSink(path, logLevelInfo, 0, 0)
+	defer sink.Close()
+	if openLogFileSink(path, logLevelInfo, 0, 0) != sink {
+		t.Errorf("Expected the sink to be shared for the same path")
+	}
+
+	ctx := withLogging(context.Background(), &apiClient{logSink: sink}, map[string]interface{}{logFieldResourceType: "action"})
+	logDebug(ctx, logCrud, "not written")
+	logInfo(ctx, logHttp, "written", map[string]interface{}{logFieldStatement: "list actions", logFieldRetry: 2})
+
+	entries := testReadLogEntries(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry at or above the sink's level, got: %+v", entries)
+	}
+	entry := entries[0]
+	if entry["@message"] != "written" || entry["@level"] != "info" || entry["@module"] != "shoreline.http" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if entry[logFieldResourceType] != "action" || entry[logFieldStatement] != "list actions" || entry[logFieldRetry] != 2.0 {
+		t.Errorf("Expected the context and entry fields, got: %+v", entry)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, entry["@timestamp"].(string)); err != nil {
+		t.Errorf("Expected an RFC3339 timestamp, got: %v", entry["@timestamp"])
+	}
+}
+
+func TestLogFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rotate.log")
+	sink := openLogFileSink(path, logLevelTrace, 1, 2)
+	defer sink.Close()
+	// ~1KB per entry, to rotate every ~1000 entries
+	msg := strings.Repeat("x", 1000)
+	for i := 0; i < 3500; i++ {
+		sink.write(time.Now(), logLevelDebug, logCrud, msg, nil)
+	}
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("Expected log file %s: %s", p, err)
+		}
+		if info.Size() > 1024*1024 {
+			t.Errorf("Expected %s to be rotated at 1MB, got %d bytes", p, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected at most 2 backups")
+	}
+}
+
+func TestLogLevels(t *testing.T) {
+	for _, name := range logLevelNames {
+		if level, valid := parseLogLevel(strings.ToUpper(name)); !valid || level.String() != name {
+			t.Errorf("Failed to parse log level %s", name)
+		}
+	}
+	if _, valid := parseLogLevel("verbose"); valid {
+		t.Errorf("Expected an invalid log level")
+	}
+}
+
+func TestMockLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "provider.log")
+	p, meta := testMockProviderWithConfig(t, map[string]interface{}{
+		"log_file":  path,
+		"log_level": "debug",
+	})
+	defer meta.(*apiClient).logSink.Close()
+	name := RandomAlphaPrefix(5) + "_action"
+	testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":    name,
+		"command": "`hostname`",
+	})
+
+	found := false
+	for _, entry := range testReadLogEntries(t, path) {
+		if entry["@module"] == "shoreline.http" && strings.HasPrefix(CastToString(entry[logFieldStatement]), "action "+name+" =") {
+			found = true
+			if entry[logFieldResourceType] != "action" || entry[logFieldResourceName] != name || entry[logFieldRetry] != 0.0 {
+				t.Errorf("Expected the resource and retry fields, got: %+v", entry)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected the create statement to be logged")
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// statement kinds, checked in order (e.g. a field set before a define)
+var statementKinds = []struct {
+	kind  string
+	regex *regexp.Regexp
+}{
+	{"get_class", regexp.MustCompile(`^get_\w+_class\s*\(`)},
+	{"list", regexp.MustCompile(`^list\s`)},
+	{"update_configuration", regexp.MustCompile(`^update_configuration\s*\(`)},
+	{"enable", regexp.MustCompile(`^(enable|disable)\s`)},
+	{"delete", regexp.MustCompile(`^delete\s`)},
+	{"backend_version", regexp.MustCompile(`^backend_version\b`)},
+	{"set_field", regexp.MustCompile(`^\w+\.\w+\s*=`)},
+	{"get_field", regexp.MustCompile(`^\w+\.\w+\s*$`)},
+	{"define", regexp.MustCompile(`^\w+\s+\w+\s*=`)},
+}
+
+const (
+	statementKindBatch         = "batch"
+	statementKindTokenRefresh  = "token_refresh"
+	statementKindResourceQuery = "resource_query"
+	statementKindMetricQuery   = "metric_query"
+	statementKindOther         = "other"
+	// API calls outside of a resource operation, e.g. configuring the provider
+	metricsResourceProvider = "provider"
+)
+
+// statementKind classifies an op statement for the metrics report.
+func statementKind(statement string) string {
+	for _, sk := range statementKinds {
+		if sk.regex.MatchString(statement) {
+			return sk.kind
+		}
+	}
+	return statementKindOther
+}
+
+type metricsKindKey struct{}
+
+// withStatementKind labels the API calls made with the returned context.
+func withStatementKind(ctx context.Context, kind string) context.Context {
+	return context.WithValue(ctx, metricsKindKey{}, kind)
+}
+
+func metricsLabels(ctx context.Context) (kind string, resourceType string) {
+	kind, _ = ctx.Value(metricsKindKey{}).(string)
+	if kind == "" {
+		kind = statementKindOther
+	}
+	resourceType = metricsResourceProvider
+	if lc, hasLc := ctx.Value(logContextKey{}).(*logContext); hasLc {
+		if typ, isStr := lc.fields[logFieldResourceType].(string); isStr && typ != "" {
+			resourceType = typ
+		}
+	}
+	return kind, resourceType
+}
+
+type callStats struct {
+	calls         int
+	errors        int
+	retries       int
+	bytesSent     int64
+	bytesReceived int64
+	latencies     []float64 // milliseconds
+}
+
+func (st *callStats) add(latency time.Duration, sent int, received int, failed bool) {
+	st.calls += 1
+	if failed {
+		st.errors += 1
+	}
+	st.bytesSent += int64(sent)
+	st.bytesReceived += int64(received)
+	st.latencies = append(st.latencies, float64(latency.Microseconds())/1000)
+}
+
+// percentile (nearest rank) of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+func (st *callStats) report() map[string]interface{} {
+	sorted := append([]float64{}, st.latencies...)
+	sort.Float64s(sorted)
+	return map[string]interface{}{
+		"calls":          st.calls,
+		"errors":         st.errors,
+		"retries":        st.retries,
+		"bytes_sent":     st.bytesSent,
+		"bytes_received": st.bytesReceived,
+		"latency_ms": map[string]interface{}{
+			"p50": percentile(sorted, 50),
+			"p95": percentile(sorted, 95),
+			"max": percentile(sorted, 100),
+		},
+	}
+}
+
+// ApiMetrics collects the API calls of the provider instances reporting to the same file.
+// A nil ApiMetrics doesn't collect anything.
+type ApiMetrics struct {
+	path    string
+	started time.Time
+
+	mu             sync.Mutex
+	total          callStats
+	byKind         map[string]*callStats
+	byResourceType map[string]*callStats
+}
+
+var (
+	apiMetricsMu sync.Mutex
+	apiMetrics   = map[string]*ApiMetrics{}
+)
+
+// metricsForFile returns the (shared) collector that reports to 'path'.
+func metricsForFile(path string) *ApiMetrics {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	apiMetricsMu.Lock()
+	defer apiMetricsMu.Unlock()
+	metrics, found := apiMetrics[path]
+	if !found {
+		metrics = &ApiMetrics{
+			path:           path,
+			started:        time.Now(),
+			byKind:         map[string]*callStats{},
+			byResourceType: map[string]*callStats{},
+		}
+		apiMetrics[path] = metrics
+	}
+	return metrics
+}
+
+func (metrics *ApiMetrics) stats(kind string, resourceType string) []*callStats {
+	kindStats, found := metrics.byKind[kind]
+	if !found {
+		kindStats = &callStats{}
+		metrics.byKind[kind] = kindStats
+	}
+	typeStats, found := metrics.byResourceType[resourceType]
+	if !found {
+		typeStats = &callStats{}
+		metrics.byResourceType[resourceType] = typeStats
+	}
+	return []*callStats{&metrics.total, kindStats, typeStats}
+}
+
+// recordCall adds an API call (request), labelled by the statement kind and resource type in 'ctx'.
+func (metrics *ApiMetrics) recordCall(ctx context.Context, latency time.Duration, sent int, received int, failed bool) {
+	if metrics == nil {
+		return
+	}
+	kind, resourceType := metricsLabels(ctx)
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	for _, st := range metrics.stats(kind, resourceType) {
+		st.add(latency, sent, received, failed)
+	}
+}
+
+// recordRetry counts a retry of a failed statement.
+func (metrics *ApiMetrics) recordRetry(ctx context.Context) {
+	if metrics == nil {
+		return
+	}
+	kind, resourceType := metricsLabels(ctx)
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	for _, st := range metrics.stats(kind, resourceType) {
+		st.retries += 1
+	}
+}
+
+// Report is the JSON summary of the API calls so far.
+func (metrics *ApiMetrics) Report() ([]byte, error) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	byKind := map[string]interface{}{}
+	for kind, st := range metrics.byKind {
+		byKind[kind] = st.report()
+	}
+	byResourceType := map[string]interface{}{}
+	for typ, st := range metrics.byResourceType {
+		byResourceType[typ] = st.report()
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"pid":             os.Getpid(),
+		"started_at":      metrics.started.Format(time.RFC3339),
+		"finished_at":     time.Now().Format(time.RFC3339),
+		"total":           metrics.total.report(),
+		"statement_kinds": byKind,
+		"resource_types":  byResourceType,
+	}, "", "  ")
+}
+
+// WriteReport adds the JSON summary to the metrics file's "runs", replacing this process' earlier summary (if any).
+// The other processes reporting to the file (e.g. the plan's, for the apply's provider) are kept.
+func (metrics *ApiMetrics) WriteReport() error {
+	if err := metrics.mergeReport(); err != nil {
+		return fmt.Errorf("Failed to write the API metrics to '%s': %w", metrics.path, err)
+	}
+	return nil
+}
+
+func (metrics *ApiMetrics) mergeReport() error {
+	data, err := metrics.Report()
+	if err != nil {
+		return err
+	}
+	report := map[string]interface{}{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(metrics.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	runs := []interface{}{}
+	if existing, err := os.ReadFile(metrics.path); err == nil {
+		js := map[string]interface{}{}
+		if err := json.Unmarshal(existing, &js); err != nil {
+			return fmt.Errorf("Couldn't parse the existing report: %s", err.Error())
+		}
+		runs, _ = js["runs"].([]interface{})
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	merged := []interface{}{}
+	for _, run := range runs {
+		if GetNestedValueOrDefault(run, ToKeyPath("pid"), nil) == report["pid"] && GetNestedValueOrDefault(run, ToKeyPath("started_at"), nil) == report["started_at"] {
+			continue
+		}
+		merged = append(merged, run)
+	}
+	out, err := json.MarshalIndent(map[string]interface{}{"runs": append(merged, report)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(metrics.path, append(out, '\n'), 0600)
+}
+
+// WriteMetricsReports writes the API call summaries (see the provider's 'metrics_file'),
+// and is called when the provider shuts down.
+func WriteMetricsReports() error {
+	apiMetricsMu.Lock()
+	defer apiMetricsMu.Unlock()
+	var errs []error
+	for _, metrics := range apiMetrics {
+		if err := metrics.WriteReport(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
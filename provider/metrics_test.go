@@ -0,0 +1,137 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider/mockbackend"
+)
+
+func TestStatementKind(t *testing.T) {
+	tests := map[string]string{
+		`get_action_class( action_name = "a1" )`:         "get_class",
+		`list actions | name = "a1"`:                     "list",
+		`update_configuration(configuration="s", x = 1)`: "update_configuration",
+		`enable a1`:               "enable",
+		`disable a1`:              "enable",
+		`delete a1`:               "delete",
+		`backend_version`:         "backend_version",
+		`a1.command = "hostname"`: "set_field",
+		`f1.file_data`:            "get_field",
+		"action a1 = `hostname`":  "define",
+		`host | limit=1`:          "other",
+	}
+	for stmt, want := range tests {
+		if got := statementKind(stmt); got != want {
+			t.Errorf("statementKind(%q) = %s, want %s", stmt, got, want)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if p := percentile(sorted, 50); p != 5 {
+		t.Errorf("Expected p50 of 5, got %v", p)
+	}
+	if p := percentile(sorted, 95); p != 10 {
+		t.Errorf("Expected p95 of 10, got %v", p)
+	}
+	if p := percentile(nil, 95); p != 0 {
+		t.Errorf("Expected 0 without values, got %v", p)
+	}
+}
+
+func TestMockMetricsReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	p, meta := testMockProviderWithConfig(t, map[string]interface{}{"metrics_file": path, "retries": 1})
+	testFastRetries(t)
+	client := meta.(*apiClient)
+	if client.metrics == nil || metricsForFile(path) != client.metrics {
+		t.Fatalf("Expected a shared metrics collector for the file")
+	}
+
+	mockServer.InjectFault(mockbackend.Fault{Path: "/v1/execute", Status: 503, Body: "unavailable"})
+	testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":    RandomAlphaPrefix(5) + "_action",
+		"command": "`hostname`",
+	})
+	mockServer.ResetFaults()
+
+	if err := client.metrics.WriteReport(); err != nil {
+		t.Fatalf("Failed to write the metrics report: %s", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read the metrics report: %s", err)
+	}
+	js := map[string]interface{}{}
+	if err := json.Unmarshal(content, &js); err != nil {
+		t.Fatalf("Expected a JSON report, got: %s", content)
+	}
+	runs, _ := js["runs"].([]interface{})
+	if len(runs) != 1 {
+		t.Fatalf("Expected the report of a single run, got: %s", content)
+	}
+	report := runs[0]
+
+	for _, kind := range []string{"define", "get_class", "list", "token_refresh"} {
+		if calls := GetNestedValueOrDefault(report, ToKeyPath("statement_kinds."+kind+".calls"), 0.0).(float64); calls < 1 {
+			t.Errorf("Expected '%s' calls in the report, got: %s", kind, content)
+		}
+	}
+	action := GetNestedValueOrDefault(report, ToKeyPath("resource_types.action"), nil)
+	if action == nil {
+		t.Fatalf("Expected the action calls in the report, got: %s", content)
+	}
+	if retries := GetNestedValueOrDefault(action, ToKeyPath("retries"), 0.0).(float64); retries != 1 {
+		t.Errorf("Expected 1 retry for the action, got %v", retries)
+	}
+	if errs := GetNestedValueOrDefault(report, ToKeyPath("total.errors"), 0.0).(float64); errs != 1 {
+		t.Errorf("Expected 1 failed call, got %v", errs)
+	}
+	for _, key := range []string{"bytes_sent", "bytes_received", "latency_ms.p50", "latency_ms.p95"} {
+		if _, isNum := GetNestedValueOrDefault(report, ToKeyPath("total."+key), nil).(float64); !isNum {
+			t.Errorf("Expected total.%s in the report, got: %s", key, content)
+		}
+	}
+}
+
+func TestMetricsReportMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	// e.g. the providers of a plan and an apply (separate processes)
+	plan := &ApiMetrics{path: path, started: time.Now().Add(-time.Minute), byKind: map[string]*callStats{}, byResourceType: map[string]*callStats{}}
+	apply := &ApiMetrics{path: path, started: time.Now(), byKind: map[string]*callStats{}, byResourceType: map[string]*callStats{}}
+	plan.recordCall(context.Background(), time.Millisecond, 10, 20, false)
+	apply.recordCall(context.Background(), time.Millisecond, 10, 20, true)
+
+	for _, metrics := range []*ApiMetrics{plan, apply, apply} {
+		if err := metrics.WriteReport(); err != nil {
+			t.Fatalf("Failed to write the metrics report: %s", err)
+		}
+	}
+	content, _ := os.ReadFile(path)
+	js := map[string]interface{}{}
+	if err := json.Unmarshal(content, &js); err != nil {
+		t.Fatalf("Expected a JSON report, got: %s", content)
+	}
+	runs, _ := js["runs"].([]interface{})
+	if len(runs) != 2 {
+		t.Fatalf("Expected the reports of both runs (once each), got: %s", content)
+	}
+	if GetNestedValueOrDefault(runs[0], ToKeyPath("total.errors"), nil) != 0.0 || GetNestedValueOrDefault(runs[1], ToKeyPath("total.errors"), nil) != 1.0 {
+		t.Errorf("Unexpected reports: %s", content)
+	}
+
+	os.WriteFile(path, []byte("not json"), 0600)
+	if err := plan.WriteReport(); err == nil || !strings.Contains(err.Error(), "Couldn't parse the existing report") {
+		t.Errorf("Expected an unparsable report not to be overwritten, got: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package mockbackend
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Resource is a live host, pod or container returned by resource queries.
+type Resource struct {
+	Name string
+	// HOST, POD or CONTAINER
+	Type string
+	Tags map[string]string
+	// metric name -> time series, returned by metric queries
+	Metrics map[string][]MetricPoint
+}
+
+// MetricPoint is a metric value at a time (in epoch milliseconds).
+type MetricPoint struct {
+	Time  int64
+	Value float64
+}
+
+var (
+	resourceQueryRe = regexp.MustCompile(`(?s)^(host|pod|container)s?\b\s*(.*)$`)
+	tagFilterRe     = regexp.MustCompile(`^(\w+)\s*(=~|!=|=)\s*(?:"((?:[^"\\]|\\.)*)"|'([^']*)'|(\S+))$`)
+	limitRe         = regexp.MustCompile(`^limit\s*=\s*(\d+)$`)
+	metricNamesRe   = regexp.MustCompile(`^metric_query\(\s*metric_names\s*=\s*"((?:[^"\\]|\\.)*)"\s*\)$`)
+	windowRe        = regexp.MustCompile(`^window\(\s*(\d+)([smhd]?)\s*\)$`)
+)
+
+// PutResource adds (or replaces) a resource returned by resource queries.
+func (s *Server) PutResource(res Resource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[res.Name] = res
+}
+
+// ResetResources removes all the resources.
+func (s *Server) ResetResources() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources = map[string]Resource{}
+}
+
+// splitStages splits a pipeline on '|', ignoring pipes in quotes.
+func splitStages(stmt string) []string {
+	stages := []string{}
+	quote := byte(0)
+	start := 0
+	for i := 0; i < len(stmt); i++ {
+		switch c := stmt[i]; {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '|':
+			stages = append(stages, strings.TrimSpace(stmt[start:i]))
+			start = i + 1
+		}
+	}
+	return append(stages, strings.TrimSpace(stmt[start:]))
+}
+
+// resourceQuery evaluates `<type> | tag=value | tag=~regex | limit=N` statements.
+func (s *Server) resourceQuery(typ string, rest string) map[string]interface{} {
+	names := []string{}
+	for name, res := range s.resources {
+		if strings.EqualFold(res.Type, typ) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	limit := -1
+	stages := []string{}
+	if rest = strings.TrimSpace(rest); rest != "" {
+		if !strings.HasPrefix(rest, "|") {
+			return statementError(fmt.Sprintf("unsupported resource query: %s", rest))
+		}
+		stages = splitStages(rest[1:])
+	}
+	for _, stage := range stages {
+		if m := limitRe.FindStringSubmatch(stage); m != nil {
+			limit, _ = strconv.Atoi(m[1])
+			continue
+		}
+		var err map[string]interface{}
+		if names, err = s.filterResources(names, stage); err != nil {
+			return err
+		}
+	}
+	if limit >= 0 && limit < len(names) {
+		names = names[:limit]
+	}
+
+	resources := []interface{}{}
+	for _, name := range names {
+		tags := map[string]interface{}{}
+		for k, v := range s.resources[name].Tags {
+			tags[k] = v
+		}
+		resources = append(resources, map[string]interface{}{
+			"name": name,
+			"type": strings.ToUpper(typ),
+			"tags": tags,
+		})
+	}
+	return map[string]interface{}{"list_type": map[string]interface{}{"resources": resources}}
+}
+
+// filterResources filters resources on a tag filter stage, e.g. `app="a"`, `app=~"a.*"` or `app!="a"`.
+func (s *Server) filterResources(names []string, stage string) ([]string, map[string]interface{}) {
+	m := tagFilterRe.FindStringSubmatch(stage)
+	if m == nil {
+		return nil, statementError(fmt.Sprintf("unsupported resource query stage: %s", stage))
+	}
+	val := m[4] + m[5]
+	if m[3] != "" {
+		val, _ = strconv.Unquote(`"` + m[3] + `"`)
+	}
+	var re *regexp.Regexp
+	if m[2] == "=~" {
+		var err error
+		if re, err = regexp.Compile(val); err != nil {
+			return nil, statementError(fmt.Sprintf("invalid regex: %s", val))
+		}
+	}
+	filtered := []string{}
+	for _, name := range names {
+		tag, hasTag := s.resources[name].Tags[m[1]]
+		if m[1] == "name" {
+			tag, hasTag = name, true
+		}
+		switch {
+		case re != nil && hasTag && re.MatchString(tag),
+			m[2] == "=" && hasTag && tag == val,
+			m[2] == "!=" && tag != val:
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered, nil
+}
+
+// metricQueryName returns the metric of a metric query, i.e. `metric_query(metric_names="m") | ...`
+// or `m | ...` (for a metric of some resource), optionally after a resource query (e.g. `hosts | app="a" | m`),
+// and the index of its stage, or "" for other statements.
+func (s *Server) metricQueryName(stmt string) (string, int) {
+	stages := splitStages(stmt)
+	at := 0
+	if m := resourceQueryRe.FindStringSubmatch(stages[0]); m != nil && m[2] == "" {
+		// skip the resource query's filters
+		for at = 1; at < len(stages) && tagFilterRe.MatchString(stages[at]); at++ {
+		}
+		if at == len(stages) {
+			return "", 0
+		}
+	}
+	head := stages[at]
+	if m := metricNamesRe.FindStringSubmatch(head); m != nil {
+		name, _ := strconv.Unquote(`"` + m[1] + `"`)
+		return name, at
+	}
+	for _, res := range s.resources {
+		if _, found := res.Metrics[head]; found {
+			return head, at
+		}
+	}
+	return "", 0
+}
+
+// metricQuery evaluates `[<type> | tag=value |] <metric> | tag=value | window(1h)` statements, returning the
+// points of each (matching) resource with the metric (within the window, if any). 'at' is the metric's stage.
+func (s *Server) metricQuery(stmt string, metric string, at int, now int64) map[string]interface{} {
+	stages := splitStages(stmt)
+	names := []string{}
+	for name, res := range s.resources {
+		if _, found := res.Metrics[metric]; found && (at == 0 || strings.EqualFold(res.Type, resourceQueryRe.FindStringSubmatch(stages[0])[1])) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	from := int64(0)
+	for i, stage := range stages[1:] {
+		if i+1 == at {
+			continue
+		}
+		if m := windowRe.FindStringSubmatch(stage); m != nil {
+			n, _ := strconv.ParseInt(m[1], 10, 64)
+			unit := map[string]int64{"": 1, "s": 1, "m": 60, "h": 3600, "d": 86400}[m[2]]
+			from = now - n*unit*1000
+			continue
+		}
+		var err map[string]interface{}
+		if names, err = s.filterResources(names, stage); err != nil {
+			return err
+		}
+	}
+
+	series := []interface{}{}
+	for _, name := range names {
+		timestamps := []interface{}{}
+		values := []interface{}{}
+		for _, pt := range s.resources[name].Metrics[metric] {
+			if pt.Time >= from {
+				timestamps = append(timestamps, float64(pt.Time))
+				values = append(values, pt.Value)
+			}
+		}
+		series = append(series, map[string]interface{}{
+			"name":       name,
+			"type":       strings.ToUpper(s.resources[name].Type),
+			"timestamps": timestamps,
+			"values":     values,
+		})
+	}
+	return map[string]interface{}{"metric_query": series}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package mockbackend
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ActionResult is the outcome of running an action on a resource.
+type ActionResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+var (
+	// `<resource query> | <action>(param = "value", ...)`
+	actionRunRe = regexp.MustCompile(`(?s)^(.*?)\s*\|\s*(\w+)\((.*)\)$`)
+)
+
+// PutActionResult sets the outcome of the following runs of an action on a resource.
+// Resources without an outcome succeed, without any output.
+func (s *Server) PutActionResult(action string, resource string, result ActionResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.actionResults == nil {
+		s.actionResults = map[string]map[string]ActionResult{}
+	}
+	if s.actionResults[action] == nil {
+		s.actionResults[action] = map[string]ActionResult{}
+	}
+	s.actionResults[action][resource] = result
+}
+
+// ActionRuns returns the number of times actions were run.
+func (s *Server) ActionRuns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.actionRuns
+}
+
+// ResetActionRuns drops the action runs, and their outcomes.
+func (s *Server) ResetActionRuns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actionResults = nil
+	s.actionRuns = 0
+}
+
+// namedArgs parses `a = "x", b = {...}` arguments.
+func namedArgs(args string) (map[string]interface{}, error) {
+	vals := map[string]interface{}{}
+	for _, arg := range splitArgs(args) {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid argument '%s'", arg)
+		}
+		vals[strings.TrimSpace(kv[0])] = parseLiteral(kv[1])
+	}
+	return vals, nil
+}
+
+// isActionRun is true for statements that run an (existing) action on the resources of a resource query.
+func (s *Server) isActionRun(stmt string) bool {
+	m := actionRunRe.FindStringSubmatch(stmt)
+	if m == nil {
+		return false
+	}
+	obj, found := s.objects[m[2]]
+	return found && obj.Type == "action"
+}
+
+func (s *Server) actionRun(stmt string) map[string]interface{} {
+	m := actionRunRe.FindStringSubmatch(stmt)
+	action := s.objects[m[2]]
+	if enabled, isBool := action.Attributes["enabled"].(bool); isBool && !enabled {
+		return statementError(fmt.Sprintf("action '%s' is disabled", action.Name))
+	}
+	if _, err := namedArgs(m[3]); err != nil && strings.TrimSpace(m[3]) != "" {
+		return statementError(err.Error())
+	}
+	q := resourceQueryRe.FindStringSubmatch(m[1])
+	if q == nil {
+		return statementError(fmt.Sprintf("unsupported resource query: %s", m[1]))
+	}
+	matched := s.resourceQuery(q[1], q[2])
+	resources, isArray := getPath(matched, "list_type.resources").([]interface{})
+	if !isArray {
+		return matched
+	}
+
+	s.actionRuns++
+	results := []interface{}{}
+	for _, res := range resources {
+		name := getPath(res, "name").(string)
+		outcome := s.actionResults[action.Name][name]
+		results = append(results, map[string]interface{}{
+			"name":      name,
+			"type":      getPath(res, "type"),
+			"exit_code": float64(outcome.ExitCode),
+			"stdout":    outcome.Stdout,
+			"stderr":    outcome.Stderr,
+		})
+	}
+	return map[string]interface{}{action.Name: results}
+}
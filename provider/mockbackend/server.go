@@ -0,0 +1,703 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package mockbackend is an in-process stand-in for the Shoreline API server.
+//
+// It implements the token refresh and execute endpoints that the provider uses,
+// interprets the op statements the provider emits against an in-memory object
+// store, and replies with the same JSON shapes as the real backend. It is meant
+// for running the provider (and modules built on it) in sandboxes without a live
+// cluster or a real SHORELINE_TOKEN.
+package mockbackend
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	executeEndpoint = "/v1/execute"
+	refreshEndpoint = "/v1/token/refresh"
+)
+
+// DefaultVersion is the backend version tag reported by `backend_version`.
+const DefaultVersion = "release-29.0.0"
+
+// Request is a single API call received by the mock server.
+type Request struct {
+	Path           string
+	Authorization  string
+	IdempotencyKey string
+	Statement      string
+	// Batch holds the statements of a multi-statement execute request.
+	Batch []string
+}
+
+// Fault is a canned response returned instead of the normal handling, e.g. to
+// simulate transient server failures. An empty Path matches every endpoint.
+type Fault struct {
+	Path   string
+	Status int
+	Body   string
+	Header http.Header
+	// Handled handles the request (e.g. runs its statement) before returning the fault,
+	// like a response that's lost on its way back.
+	Handled bool
+}
+
+// Object is a Shoreline object held by the mock server.
+type Object struct {
+	Type       string
+	Name       string
+	Attributes map[string]interface{}
+	Class      map[string]interface{}
+	// packed sub-objects that are returned as JSON strings in the class definition
+	packed map[string]map[string]interface{}
+}
+
+// Server is an httptest server emulating the Shoreline API.
+type Server struct {
+	*httptest.Server
+
+	// Version is the tag returned by `backend_version`.
+	Version string
+	// AccessTokenTTL is the lifetime of access tokens returned by the refresh endpoint.
+	AccessTokenTTL time.Duration
+	// NoBatch rejects multi-statement execute requests, like older API servers.
+	NoBatch bool
+
+	mu        sync.Mutex
+	config    map[string]interface{}
+	signature string
+	objects   map[string]*Object
+	settings  map[string]map[string]interface{}
+	resources map[string]Resource
+	requests  []Request
+	faults    []Fault
+	failing   map[*regexp.Regexp]string
+
+	// execute responses by idempotency key, returned again for retried requests
+	responses map[string][]byte
+	// action outcomes by action and resource
+	actionResults map[string]map[string]ActionResult
+	actionRuns    int
+}
+
+var (
+	listRe       = regexp.MustCompile(`^list\s+(\w+?)s(?:\s*\|\s*name\s*=\s*"(.*)")?$`)
+	classRe      = regexp.MustCompile(`^get_(\w+)_class\(\s*\w+_name\s*=\s*"(.*)"\s*\)$`)
+	updateConfRe = regexp.MustCompile(`(?s)^update_configuration\((.*)\)$`)
+	enableRe     = regexp.MustCompile(`^(enable|disable)\s+(\w+)$`)
+	deleteRe     = regexp.MustCompile(`^delete\s+(\w+)$`)
+	setFieldRe   = regexp.MustCompile(`(?s)^(\w+)\.(\w+)\s*=\s*(.*)$`)
+	getFieldRe   = regexp.MustCompile(`^(\w+)\.(\w+)$`)
+	defineRe     = regexp.MustCompile(`(?s)^(\w+)\s+(\w+)\s*=\s*(.*)$`)
+)
+
+// New starts a mock server for the object definitions in configJsStr
+// (normally provider.ObjectConfigJsonStr). The caller must Close() it.
+func New(configJsStr string) *Server {
+	config := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(configJsStr), &config); err != nil {
+		panic(fmt.Sprintf("mockbackend: invalid object config: %s", err.Error()))
+	}
+	sig := make([]byte, 16)
+	rand.Read(sig)
+
+	s := &Server{
+		Version:        DefaultVersion,
+		AccessTokenTTL: time.Hour,
+		config:         config,
+		signature:      base64.RawURLEncoding.EncodeToString([]byte(hex.EncodeToString(sig))),
+		objects:        map[string]*Object{},
+		settings:       map[string]map[string]interface{}{},
+		resources:      map[string]Resource{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// NewToken returns a JWT-shaped token accepted by this server.
+// The audience is "access" for access tokens, or "refresh" for refresh tokens.
+func (s *Server) NewToken(aud string, customer string, user string, expiry time.Time) string {
+	header, _ := json.Marshal(map[string]interface{}{"alg": "HS256", "typ": "JWT"})
+	claim, _ := json.Marshal(map[string]interface{}{
+		"aud": aud,
+		"cst": customer,
+		"sub": user,
+		"exp": expiry.Unix(),
+	})
+	return base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(claim) + "." + s.signature
+}
+
+// RefreshToken returns a long-lived refresh token for the default test user.
+func (s *Server) RefreshToken() string {
+	return s.NewToken("refresh", "test_customer", "test_user@shoreline.io", time.Now().Add(24*time.Hour))
+}
+
+// InjectFault queues canned responses, consumed in order by matching requests.
+func (s *Server) InjectFault(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, faults...)
+}
+
+// FailStatements makes op statements matching the regex fail with the given error message
+// (in the response body, as the backend reports e.g. validation errors), until ResetFaults().
+func (s *Server) FailStatements(pattern string, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing == nil {
+		s.failing = map[*regexp.Regexp]string{}
+	}
+	s.failing[regexp.MustCompile(pattern)] = message
+}
+
+// ResetFaults drops any injected faults that weren't consumed, and failing statements.
+func (s *Server) ResetFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+	s.failing = nil
+}
+
+// Requests returns a copy of all requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+// Statements returns the op statements received by the execute endpoint.
+func (s *Server) Statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stmts := []string{}
+	for _, r := range s.requests {
+		if r.Path == executeEndpoint {
+			if r.Batch != nil {
+				stmts = append(stmts, r.Batch...)
+			} else {
+				stmts = append(stmts, r.Statement)
+			}
+		}
+	}
+	return stmts
+}
+
+// ResetRequests clears the request log.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// Object returns a copy of the attributes of a stored object.
+func (s *Server) Object(name string) (typ string, attributes map[string]interface{}, found bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, found := s.objects[name]
+	if !found {
+		return "", nil, false
+	}
+	attributes = map[string]interface{}{}
+	for k, v := range obj.Attributes {
+		attributes[k] = v
+	}
+	return obj.Type, attributes, true
+}
+
+// PutObject creates (or replaces) an object directly, bypassing the op statements,
+// e.g. to simulate objects created outside of terraform.
+func (s *Server) PutObject(typ string, name string, fields map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj := s.newObject(typ, name)
+	for k, v := range fields {
+		s.setField(obj, k, v)
+	}
+	s.objects[name] = obj
+}
+
+// DeleteObject removes an object directly, e.g. to simulate out-of-band deletion.
+func (s *Server) DeleteObject(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, found := s.objects[name]
+	delete(s.objects, name)
+	return found
+}
+
+// Setting returns a stored configuration value (e.g. a system setting).
+func (s *Server) Setting(configuration string, key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	val, found := s.settings[configuration][key]
+	return val, found
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// HTTP handling
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req := Request{Path: r.URL.Path, Authorization: r.Header.Get("authorization"), IdempotencyKey: r.Header.Get("idempotency-key")}
+	if r.URL.Path == executeEndpoint {
+		payload := map[string]interface{}{}
+		json.Unmarshal(body, &payload)
+		req.Statement, _ = payload["statement"].(string)
+		if batch, isArr := payload["statements"].([]interface{}); isArr {
+			req.Batch = []string{}
+			for _, stmt := range batch {
+				req.Batch = append(req.Batch, castString(stmt))
+			}
+		}
+	}
+	s.requests = append(s.requests, req)
+
+	for i, f := range s.faults {
+		if f.Path == "" || f.Path == r.URL.Path {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			if f.Handled {
+				s.route(httptest.NewRecorder(), r, req, body)
+			}
+			for k, vals := range f.Header {
+				for _, v := range vals {
+					w.Header().Add(k, v)
+				}
+			}
+			w.WriteHeader(f.Status)
+			w.Write([]byte(f.Body))
+			return
+		}
+	}
+	s.route(w, r, req, body)
+}
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request, req Request, body []byte) {
+	switch r.URL.Path {
+	case refreshEndpoint:
+		s.handleRefresh(w, body)
+	case executeEndpoint:
+		if !s.validToken(strings.TrimPrefix(req.Authorization, "Bearer "), "access") {
+			writeJson(w, http.StatusUnauthorized, map[string]interface{}{"error": "invalid or expired access token"})
+			return
+		}
+		if req.Batch != nil {
+			if s.NoBatch {
+				writeJson(w, http.StatusBadRequest, map[string]interface{}{"error": "missing 'statement'"})
+				return
+			}
+			// each statement is run (in order) and reports its own result
+			results := []interface{}{}
+			for _, stmt := range req.Batch {
+				results = append(results, s.execute(strings.TrimSpace(stmt)))
+			}
+			writeJson(w, http.StatusOK, map[string]interface{}{"results": results})
+			return
+		}
+		// like the API server, a retried request (with the same idempotency key) isn't run again
+		if cached, found := s.responses[req.IdempotencyKey]; found && req.IdempotencyKey != "" {
+			w.Header().Set("content-type", "application/json")
+			w.Write(cached)
+			return
+		}
+		data, _ := json.Marshal(s.execute(strings.TrimSpace(req.Statement)))
+		if req.IdempotencyKey != "" {
+			if s.responses == nil {
+				s.responses = map[string][]byte{}
+			}
+			s.responses[req.IdempotencyKey] = data
+		}
+		w.Header().Set("content-type", "application/json")
+		w.Write(data)
+	default:
+		writeJson(w, http.StatusNotFound, map[string]interface{}{"error": "not found: " + r.URL.Path})
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, js interface{}) {
+	data, _ := json.Marshal(js)
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func (s *Server) handleRefresh(w http.ResponseWriter, body []byte) {
+	payload := map[string]interface{}{}
+	json.Unmarshal(body, &payload)
+	refresh, _ := payload["refresh_token"].(string)
+	claim, ok := s.decodeToken(refresh)
+	if !ok || claim["aud"] == "access" || !s.validToken(refresh, castString(claim["aud"])) {
+		writeJson(w, http.StatusUnauthorized, map[string]interface{}{"error": "invalid refresh token"})
+		return
+	}
+	customer, _ := claim["cst"].(string)
+	user, _ := claim["sub"].(string)
+	writeJson(w, http.StatusOK, map[string]interface{}{
+		"access_token":  s.NewToken("access", customer, user, time.Now().Add(s.AccessTokenTTL)),
+		"refresh_token": s.NewToken("refresh", customer, user, time.Now().Add(24*time.Hour)),
+	})
+}
+
+func (s *Server) decodeToken(token string) (map[string]interface{}, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[2] != s.signature {
+		return nil, false
+	}
+	claimStr, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, false
+	}
+	claim := map[string]interface{}{}
+	if json.Unmarshal(claimStr, &claim) != nil {
+		return nil, false
+	}
+	return claim, true
+}
+
+func (s *Server) validToken(token string, aud string) bool {
+	claim, ok := s.decodeToken(token)
+	if !ok || claim["aud"] != aud {
+		return false
+	}
+	exp, _ := claim["exp"].(float64)
+	return int64(exp) > time.Now().Unix()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Op statement interpretation
+
+func statementError(msg string) map[string]interface{} {
+	return map[string]interface{}{
+		"execute_statement_errors": []interface{}{
+			map[string]interface{}{"errors": []interface{}{msg}},
+		},
+	}
+}
+
+func resultError(key string, msg string) map[string]interface{} {
+	return map[string]interface{}{
+		key: map[string]interface{}{"error": map[string]interface{}{"message": msg}},
+	}
+}
+
+func (s *Server) execute(stmt string) map[string]interface{} {
+	for re, msg := range s.failing {
+		if re.MatchString(stmt) {
+			return statementError(msg)
+		}
+	}
+	if stmt == "backend_version" {
+		build, _ := json.Marshal(map[string]interface{}{"tag": s.Version, "build_date": "Mon_Jan_01_00:00:00_UTC_2024"})
+		return map[string]interface{}{"get_backend_version": string(build)}
+	}
+	if m := listRe.FindStringSubmatch(stmt); m != nil {
+		return s.list(m[1], m[2])
+	}
+	if m := classRe.FindStringSubmatch(stmt); m != nil {
+		return s.class(m[1], m[2])
+	}
+	if m := updateConfRe.FindStringSubmatch(stmt); m != nil {
+		return s.updateConfiguration(m[1])
+	}
+	if m := enableRe.FindStringSubmatch(stmt); m != nil {
+		obj, found := s.objects[m[2]]
+		if !found {
+			return statementError(fmt.Sprintf("symbol '%s' is not defined", m[2]))
+		}
+		obj.Attributes["enabled"] = m[1] == "enable"
+		return map[string]interface{}{"update_" + obj.Type: map[string]interface{}{"name": obj.Name}}
+	}
+	if m := deleteRe.FindStringSubmatch(stmt); m != nil {
+		obj, found := s.objects[m[1]]
+		if !found {
+			return statementError(fmt.Sprintf("symbol '%s' is not defined", m[1]))
+		}
+		delete(s.objects, m[1])
+		return map[string]interface{}{"delete_" + obj.Type: map[string]interface{}{"name": obj.Name}}
+	}
+	if m := setFieldRe.FindStringSubmatch(stmt); m != nil {
+		obj, found := s.objects[m[1]]
+		if !found {
+			return statementError(fmt.Sprintf("symbol '%s' is not defined", m[1]))
+		}
+		s.setField(obj, m[2], s.decodeValue(obj.Type, m[2], m[3]))
+		return map[string]interface{}{"update_" + obj.Type: map[string]interface{}{"name": obj.Name}}
+	}
+	if m := getFieldRe.FindStringSubmatch(stmt); m != nil {
+		return s.getField(m[1], m[2])
+	}
+	if m := defineRe.FindStringSubmatch(stmt); m != nil {
+		return s.define(m[1], m[2], m[3])
+	}
+	if metric, at := s.metricQueryName(stmt); metric != "" {
+		return s.metricQuery(stmt, metric, at, time.Now().UnixMilli())
+	}
+	if s.isActionRun(stmt) {
+		return s.actionRun(stmt)
+	}
+	if m := resourceQueryRe.FindStringSubmatch(stmt); m != nil {
+		return s.resourceQuery(m[1], m[2])
+	}
+	return statementError(fmt.Sprintf("unsupported statement: %s", stmt))
+}
+
+func (s *Server) objectConfig(typ string) map[string]interface{} {
+	if typ == "runbook" {
+		typ = "notebook"
+	}
+	obj, _ := s.config[typ].(map[string]interface{})
+	return obj
+}
+
+func (s *Server) attrConfig(typ string) map[string]interface{} {
+	attrs, _ := s.objectConfig(typ)["attributes"].(map[string]interface{})
+	return attrs
+}
+
+func (s *Server) newObject(typ string, name string) *Object {
+	obj := &Object{
+		Type:       typ,
+		Name:       name,
+		Attributes: map[string]interface{}{"name": name},
+		Class:      map[string]interface{}{"name": name},
+		packed:     map[string]map[string]interface{}{},
+	}
+	for key, attr := range s.attrConfig(typ) {
+		attrMap, _ := attr.(map[string]interface{})
+		if computed, _ := attrMap["computed"].(bool); computed {
+			if val, hasVal := attrMap["value"]; hasVal {
+				obj.Attributes[key] = val
+			}
+		}
+	}
+	obj.Attributes["enabled"] = false
+	return obj
+}
+
+func (s *Server) define(typ string, name string, valStr string) map[string]interface{} {
+	key := "define_" + typ
+	objConf := s.objectConfig(typ)
+	if objConf == nil {
+		return statementError(fmt.Sprintf("unknown object type '%s'", typ))
+	}
+	if existing, found := s.objects[name]; found {
+		return resultError(key, fmt.Sprintf("symbol '%s' already exists (%s)", name, existing.Type))
+	}
+	obj := s.newObject(typ, name)
+	primary := ""
+	for k, attr := range s.attrConfig(typ) {
+		if isPrimary, _ := attr.(map[string]interface{})["primary"].(bool); isPrimary {
+			primary = k
+		}
+	}
+	if primary != "" {
+		s.setField(obj, primary, s.decodeValue(typ, primary, valStr))
+	}
+	s.objects[name] = obj
+	return map[string]interface{}{key: map[string]interface{}{"name": name}}
+}
+
+func (s *Server) list(typ string, name string) map[string]interface{} {
+	symbols := []interface{}{}
+	names := []string{}
+	for n, obj := range s.objects {
+		if obj.Type == typ && (name == "" || name == n) {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		symbols = append(symbols, map[string]interface{}{"attributes": deepCopy(s.objects[n].Attributes)})
+	}
+	return map[string]interface{}{"list_type": map[string]interface{}{"symbol": symbols}}
+}
+
+func (s *Server) class(typ string, name string) map[string]interface{} {
+	classes := []interface{}{}
+	if obj, found := s.objects[name]; found && obj.Type == typ {
+		class := deepCopy(obj.Class).(map[string]interface{})
+		for key, packed := range obj.packed {
+			data, _ := json.Marshal(packed)
+			class[key] = string(data)
+		}
+		classes = append(classes, class)
+	}
+	return map[string]interface{}{
+		fmt.Sprintf("get_%s_class", typ): map[string]interface{}{fmt.Sprintf("%s_classes", typ): classes},
+	}
+}
+
+func (s *Server) getField(name string, key string) map[string]interface{} {
+	if _, isConf := s.singletonType(name); isConf {
+		val, found := s.settings[name][key]
+		if !found {
+			typ, _ := s.singletonType(name)
+			attr, _ := s.attrConfig(typ)[key].(map[string]interface{})
+			val = attr["default"]
+		}
+		return map[string]interface{}{"get_configuration_attribute": val}
+	}
+	obj, found := s.objects[name]
+	if !found {
+		return statementError(fmt.Sprintf("symbol '%s' is not defined", name))
+	}
+	val, found := obj.Attributes[key]
+	if !found {
+		val = "get " + obj.Type + " attribute failed: field does not exist"
+	}
+	return map[string]interface{}{fmt.Sprintf("get_%s_attribute", obj.Type): val}
+}
+
+// singletonType returns the object type whose singleton is 'name' (e.g. system_settings).
+func (s *Server) singletonType(name string) (string, bool) {
+	for typ, conf := range s.config {
+		singleton, _ := getPath(conf, "internal.singleton").(string)
+		if singleton != "" && singleton == name {
+			return typ, true
+		}
+	}
+	return "", false
+}
+
+func (s *Server) updateConfiguration(args string) map[string]interface{} {
+	vals := map[string]interface{}{}
+	for _, arg := range splitArgs(args) {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return resultError("update_configuration", fmt.Sprintf("invalid argument '%s'", arg))
+		}
+		vals[strings.TrimSpace(kv[0])] = parseLiteral(kv[1])
+	}
+	confName, _ := vals["configuration_name"].(string)
+	if confName == "" {
+		return resultError("update_configuration", "missing configuration_name")
+	}
+	delete(vals, "configuration_name")
+	if s.settings[confName] == nil {
+		s.settings[confName] = map[string]interface{}{}
+	}
+	for k, v := range vals {
+		s.settings[confName][k] = v
+	}
+	return map[string]interface{}{"update_configuration": map[string]interface{}{"configuration_name": confName}}
+}
+
+// decodeValue converts the right hand side of an assignment to a stored value.
+func (s *Server) decodeValue(typ string, field string, valStr string) interface{} {
+	val := parseLiteral(valStr)
+	attrKey, _ := s.resolveField(typ, field)
+	attr, _ := s.attrConfig(typ)[attrKey].(map[string]interface{})
+	if attr["type"] == "b64json" {
+		if str, isStr := val.(string); isStr {
+			if decoded, err := base64.StdEncoding.DecodeString(str); err == nil {
+				var js interface{}
+				if json.Unmarshal(decoded, &js) == nil {
+					return js
+				}
+				return string(decoded)
+			}
+		}
+	}
+	return val
+}
+
+// resolveField maps an outgoing field name (which may be an "alias_out") back to
+// the attribute key, and returns the step path used to store it in the class.
+func (s *Server) resolveField(typ string, field string) (string, string) {
+	objConf := s.objectConfig(typ)
+	attrs := s.attrConfig(typ)
+	if attr, found := attrs[field].(map[string]interface{}); found {
+		step, _ := attr["step"].(string)
+		return field, step
+	}
+	for key, attr := range attrs {
+		if alias, _ := attr.(map[string]interface{})["alias_out"].(string); alias == field {
+			step, _ := attr.(map[string]interface{})["step"].(string)
+			return key, step
+		}
+	}
+	aliasMaps, _ := getPath(objConf, "internal.alias.map").(map[string]interface{})
+	for _, aliasMap := range aliasMaps {
+		for key, attr := range aliasMap.(map[string]interface{}) {
+			if alias, _ := attr.(map[string]interface{})["alias_out"].(string); alias == field {
+				step, _ := attr.(map[string]interface{})["step"].(string)
+				return key, step
+			}
+		}
+	}
+	return field, ""
+}
+
+// packed sub-objects, stored in the class as JSON encoded strings
+var packedSteps = map[string]string{
+	"params_unpack":           "params",
+	"dashboard_configuration": "configuration",
+}
+
+func (s *Server) setField(obj *Object, field string, val interface{}) {
+	key, step := s.resolveField(obj.Type, field)
+	attr, _ := s.attrConfig(obj.Type)[key].(map[string]interface{})
+
+	if compound, isStr := attr["compound_in"].(string); isStr {
+		re := regexp.MustCompile(compound)
+		if m := re.FindStringSubmatch(castString(val)); m != nil {
+			for i, sub := range re.SubexpNames() {
+				if sub != "" {
+					obj.Attributes[sub] = m[i]
+				}
+			}
+		}
+	}
+
+	switch js := val.(type) {
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(js)
+		obj.Attributes[key] = string(data)
+	default:
+		obj.Attributes[key] = val
+	}
+
+	switch {
+	case step == ".":
+		if merged, isMap := val.(map[string]interface{}); isMap {
+			for k, v := range merged {
+				obj.Class[k] = v
+				if k == "params" || k == "external_params" || k == "enabled" {
+					s.setField(obj, k, v)
+				}
+			}
+		}
+	case step != "":
+		parts := strings.SplitN(step, ".", 2)
+		if packedKey, isPacked := packedSteps[parts[0]]; isPacked && len(parts) == 2 {
+			if obj.packed[packedKey] == nil {
+				obj.packed[packedKey] = map[string]interface{}{}
+			}
+			// aliased fields are stored under their outgoing name
+			if field != key {
+				parts[1] = field
+			}
+			setPath(obj.packed[packedKey], parts[1], val)
+		} else {
+			setPath(obj.Class, step, val)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package mockbackend
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parseLiteral converts an op value literal (string, list, bool, number) to a
+// JSON-like value. Anything else (e.g. a command or resource query) is returned
+// verbatim as a string.
+func parseLiteral(lit string) interface{} {
+	lit = strings.TrimSpace(lit)
+	switch {
+	case lit == "true":
+		return true
+	case lit == "false":
+		return false
+	case len(lit) >= 2 && strings.HasPrefix(lit, "\"") && strings.HasSuffix(lit, "\""):
+		if str, err := strconv.Unquote(lit); err == nil {
+			return str
+		}
+		return lit[1 : len(lit)-1]
+	case strings.HasPrefix(lit, "[") || strings.HasPrefix(lit, "{"):
+		var js interface{}
+		if err := json.Unmarshal([]byte(lit), &js); err == nil {
+			return js
+		}
+		return lit
+	}
+	if num, err := strconv.ParseFloat(lit, 64); err == nil {
+		return num
+	}
+	return lit
+}
+
+// splitArgs splits a comma separated argument list, ignoring commas in quotes or brackets.
+func splitArgs(args string) []string {
+	out := []string{}
+	depth := 0
+	quoted := false
+	start := 0
+	for i := 0; i < len(args); i++ {
+		switch c := args[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[' || c == '{' || c == '(':
+			depth++
+		case c == ']' || c == '}' || c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			out = append(out, strings.TrimSpace(args[start:i]))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(args[start:]); rest != "" {
+		out = append(out, rest)
+	}
+	return out
+}
+
+var indexRe = regexp.MustCompile(`^\[(\d+)\]$`)
+
+// getPath returns the value at a dotted path (e.g. "a.[0].b"), or nil.
+func getPath(js interface{}, path string) interface{} {
+	cur := js
+	for _, part := range strings.Split(path, ".") {
+		if m := indexRe.FindStringSubmatch(part); m != nil {
+			arr, isArr := cur.([]interface{})
+			idx, _ := strconv.Atoi(m[1])
+			if !isArr || idx >= len(arr) {
+				return nil
+			}
+			cur = arr[idx]
+			continue
+		}
+		obj, isMap := cur.(map[string]interface{})
+		if !isMap {
+			return nil
+		}
+		cur = obj[part]
+	}
+	return cur
+}
+
+// setPath sets the value at a dotted path, creating intermediate objects and arrays.
+func setPath(root map[string]interface{}, path string, val interface{}) {
+	parts := strings.Split(path, ".")
+	var cur interface{} = root
+	for i, part := range parts {
+		last := i == len(parts)-1
+		var next interface{}
+		if !last {
+			if indexRe.MatchString(parts[i+1]) {
+				next = []interface{}{}
+			} else {
+				next = map[string]interface{}{}
+			}
+		}
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			if last {
+				node[part] = val
+				return
+			}
+			if existing, found := node[part]; found && existing != nil {
+				next = existing
+			}
+			node[part] = next
+			// arrays may be re-allocated when grown, so re-link after the recursion
+			if arr, isArr := next.([]interface{}); isArr {
+				setPathArray(node, part, arr, parts[i+1:], val)
+				return
+			}
+		}
+		cur = next
+	}
+}
+
+func setPathArray(parent map[string]interface{}, key string, arr []interface{}, parts []string, val interface{}) {
+	m := indexRe.FindStringSubmatch(parts[0])
+	idx, _ := strconv.Atoi(m[1])
+	for len(arr) <= idx {
+		arr = append(arr, map[string]interface{}{})
+	}
+	parent[key] = arr
+	if len(parts) == 1 {
+		arr[idx] = val
+		return
+	}
+	elem, isMap := arr[idx].(map[string]interface{})
+	if !isMap {
+		elem = map[string]interface{}{}
+		arr[idx] = elem
+	}
+	setPath(elem, strings.Join(parts[1:], "."), val)
+}
+
+func deepCopy(js interface{}) interface{} {
+	data, _ := json.Marshal(js)
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+func castString(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
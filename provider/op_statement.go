@@ -0,0 +1,213 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// ResourceShorelineOpStatement manages configuration with raw op statements, e.g. for backend features that
+// aren't in ObjectConfigJsonStr yet. The statements are run as is (like the other resources' statements),
+// so they're only checked for their syntax at plan time.
+func ResourceShorelineOpStatement() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Configuration managed with raw op statements, e.g. for backend features the provider doesn't support yet. The `create_statement` is run on create, the `update_statement` (if any) when the `create_statement` changes, the `destroy_statement` (if any) on destroy, and the `read_statement` (if any) on every refresh.",
+		CreateContext: resourceShorelineOpStatementCreate,
+		ReadContext:   resourceShorelineOpStatementRead,
+		UpdateContext: resourceShorelineOpStatementUpdate,
+		DeleteContext: resourceShorelineOpStatementDelete,
+		CustomizeDiff: resourceShorelineOpStatementDiff,
+		Schema: map[string]*schema.Schema{
+			"create_statement": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateOpCommand,
+				Description:  "The statement to run on create. Changing it runs the `update_statement`, or re-creates the resource (running the `destroy_statement` first) if there's none.",
+			},
+			"update_statement": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateOpCommand,
+				Description:  "The statement to run when the `create_statement` changes.",
+			},
+			"destroy_statement": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateOpCommand,
+				Description:  "The statement to run on destroy.",
+			},
+			"read_statement": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateOpCommand,
+				Description:  "The statement to run on refresh, e.g. to read back the configuration. If it fails because the configuration wasn't found, or its result is empty (no values, e.g. a `list` that matches nothing), on refresh, the resource is re-created (right after the `create_statement` or `update_statement` ran, it fails the apply instead).",
+			},
+			"result": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The (JSON) result of the last `create_statement` or `update_statement`.",
+			},
+			"read_result": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The (JSON) result of the `read_statement`.",
+			},
+		},
+	}
+}
+
+// resourceShorelineOpStatementDiff re-creates the resource when its create_statement changes without an update_statement,
+// and marks the results of the statements that will run as unknown.
+func resourceShorelineOpStatementDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+	if d.HasChange("create_statement") {
+		if d.Get("update_statement").(string) == "" {
+			if err := d.ForceNew("create_statement"); err != nil {
+				return err
+			}
+		}
+		if err := d.SetNewComputed("result"); err != nil {
+			return err
+		}
+	}
+	if d.HasChange("create_statement") || d.HasChange("read_statement") {
+		return d.SetNewComputed("read_result")
+	}
+	return nil
+}
+
+func resourceShorelineOpStatementCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient)
+	id := GetIdempotencyKey()
+	ctx = withResourceLogging(ctx, client, "op_statement", id)
+	logInfo(ctx, logCrud, "Creating op_statement")
+
+	op := d.Get("create_statement").(string)
+	result, err := runOpCommand(ctx, client, op, true)
+	if err != nil {
+		return diag.Errorf("Failed to run create_statement: %s", err.Error())
+	}
+	d.SetId(id)
+	d.Set("result", result)
+	return readOpStatement(ctx, d, meta, true)
+}
+
+func resourceShorelineOpStatementRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return readOpStatement(ctx, d, meta, false)
+}
+
+// readOpStatement runs the read_statement. On refresh, a not-found error removes the resource from the state,
+// but right after the create (or update) statement ran, it's an error, as re-creating wouldn't help.
+func readOpStatement(ctx context.Context, d *schema.ResourceData, meta interface{}, afterWrite bool) diag.Diagnostics {
+	client := meta.(*apiClient)
+	op := d.Get("read_statement").(string)
+	if op == "" {
+		d.Set("read_result", "")
+		return nil
+	}
+	ctx = withResourceLogging(ctx, client, "op_statement", d.Id())
+
+	result, err := runOpCommand(ctx, client, op, true)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) && !afterWrite {
+			// e.g. deleted outside of terraform
+			logWarn(ctx, logCrud, fmt.Sprintf("op_statement '%s' not found, removing it from the state", d.Id()), map[string]interface{}{logFieldError: err.Error()})
+			d.SetId("")
+			return nil
+		}
+		return diag.Errorf("Failed to run read_statement: %s", err.Error())
+	}
+	if isEmptyOpResult(result) {
+		// e.g. a 'list' that no longer matches anything
+		if afterWrite {
+			return diag.Errorf("Failed to run read_statement: it found nothing (result: %s)", result)
+		}
+		logWarn(ctx, logCrud, fmt.Sprintf("op_statement '%s' read found nothing, removing it from the state", d.Id()))
+		d.SetId("")
+		return nil
+	}
+	d.Set("read_result", result)
+	return nil
+}
+
+// isEmptyOpResult returns true if a statement's (JSON) result holds no values, only e.g. empty lists or nulls.
+func isEmptyOpResult(result string) bool {
+	if strings.TrimSpace(result) == "" {
+		return true
+	}
+	var js interface{}
+	if json.Unmarshal([]byte(result), &js) != nil {
+		return false
+	}
+	var isEmpty func(val interface{}) bool
+	isEmpty = func(val interface{}) bool {
+		switch v := val.(type) {
+		case nil:
+			return true
+		case []interface{}:
+			for _, e := range v {
+				if !isEmpty(e) {
+					return false
+				}
+			}
+			return true
+		case map[string]interface{}:
+			for _, e := range v {
+				if !isEmpty(e) {
+					return false
+				}
+			}
+			return true
+		}
+		// strings (even empty ones, e.g. an unset description), numbers and booleans are values
+		return false
+	}
+	return isEmpty(js)
+}
+
+func resourceShorelineOpStatementUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient)
+	ctx = withResourceLogging(ctx, client, "op_statement", d.Id())
+
+	// changes to the other statements only apply to their next run
+	if d.HasChange("create_statement") {
+		logInfo(ctx, logCrud, "Updating op_statement")
+		op := d.Get("update_statement").(string)
+		result, err := runOpCommand(ctx, client, op, true)
+		if err != nil {
+			return diag.Errorf("Failed to run update_statement: %s", err.Error())
+		}
+		d.Set("result", result)
+	}
+	return readOpStatement(ctx, d, meta, true)
+}
+
+func resourceShorelineOpStatementDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient)
+	ctx = withResourceLogging(ctx, client, "op_statement", d.Id())
+	logInfo(ctx, logCrud, "Deleting op_statement")
+
+	op := d.Get("destroy_statement").(string)
+	if op == "" {
+		return nil
+	}
+	if _, err := runOpCommand(ctx, client, op, true); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			// already deleted (e.g. outside of terraform)
+			logDebug(ctx, logCrud, fmt.Sprintf("Deleting op_statement '%s': already gone: %s", d.Id(), err.Error()))
+			return nil
+		}
+		return diag.Errorf("Failed to run destroy_statement: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// testOpStatementApply plans and applies a shoreline_op_statement config (nil to destroy) over a state, as terraform does.
+func testOpStatementApply(t *testing.T, meta interface{}, state *terraform.InstanceState, raw map[string]interface{}) (*terraform.InstanceState, *terraform.InstanceDiff, diag.Diagnostics) {
+	res := ResourceShorelineOpStatement()
+	diff := &terraform.InstanceDiff{Destroy: true}
+	if raw != nil {
+		var err error
+		if diff, err = res.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta); err != nil {
+			t.Fatalf("Failed to plan: %s", err)
+		}
+	}
+	newState, diags := res.Apply(context.Background(), state, diff, meta)
+	return newState, diff, diags
+}
+
+func TestMockOpStatement(t *testing.T) {
+	_, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_action"
+	config := map[string]interface{}{
+		"create_statement":  "action " + name + " = `hostname`",
+		"update_statement":  name + `.description = "updated"`,
+		"destroy_statement": "delete " + name,
+		"read_statement":    name + ".description",
+	}
+
+	state, _, diags := testOpStatementApply(t, meta, nil, config)
+	if diags.HasError() {
+		t.Fatalf("Failed to create: %+v", diags)
+	}
+	if _, _, found := mockServer.Object(name); !found || state.ID == "" {
+		t.Fatalf("Expected the action to be created")
+	}
+	if !strings.Contains(state.Attributes["result"], `"define_action"`) || !strings.Contains(state.Attributes["read_result"], `"get_action_attribute"`) {
+		t.Errorf("Expected the raw results, got: %+v", state.Attributes)
+	}
+
+	// a change of the create_statement runs the update_statement
+	config["create_statement"] = "action " + name + " = `uptime`"
+	state, diff, diags := testOpStatementApply(t, meta, state, config)
+	if diags.HasError() || diff.RequiresNew() {
+		t.Fatalf("Expected an in-place update, got: %+v, %+v", diags, diff)
+	}
+	if !diff.Attributes["result"].NewComputed || !diff.Attributes["read_result"].NewComputed {
+		t.Errorf("Expected the results to be unknown in the plan, got: %+v", diff.Attributes)
+	}
+	if _, attrs, _ := mockServer.Object(name); attrs["description"] != "updated" || !strings.Contains(state.Attributes["read_result"], "updated") {
+		t.Errorf("Expected the update_statement to run, got: %v, %+v", attrs, state.Attributes)
+	}
+
+	// deleted outside of terraform, so the read removes it from the state
+	mockServer.DeleteObject(name)
+	res := ResourceShorelineOpStatement()
+	refreshed, diags := res.RefreshWithoutUpgrade(context.Background(), state, meta)
+	if diags.HasError() || refreshed != nil {
+		t.Errorf("Expected the resource to be removed from the state, got: %+v, %+v", diags, refreshed)
+	}
+
+	// destroying something already gone succeeds
+	if _, _, diags = testOpStatementApply(t, meta, state, nil); diags.HasError() {
+		t.Errorf("Failed to destroy: %+v", diags)
+	}
+}
+
+func TestMockOpStatementReplace(t *testing.T) {
+	_, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_action"
+	config := map[string]interface{}{
+		"create_statement":  "action " + name + " = `hostname`",
+		"destroy_statement": "delete " + name,
+	}
+	state, _, diags := testOpStatementApply(t, meta, nil, config)
+	if diags.HasError() {
+		t.Fatalf("Failed to create: %+v", diags)
+	}
+
+	// without an update_statement, changing the create_statement re-creates the resource
+	config["create_statement"] = "action " + name + " = `uptime`"
+	diff, err := ResourceShorelineOpStatement().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), meta)
+	if err != nil || !diff.RequiresNew() {
+		t.Errorf("Expected a replacement, got: %v, %+v", err, diff)
+	}
+
+	if _, _, diags = testOpStatementApply(t, meta, state, nil); diags.HasError() {
+		t.Fatalf("Failed to destroy: %+v", diags)
+	}
+	if _, _, found := mockServer.Object(name); found {
+		t.Errorf("Expected the destroy_statement to delete the action")
+	}
+}
+
+func TestIsEmptyOpResult(t *testing.T) {
+	tests := map[string]bool{
+		"":                              true,
+		"{}":                            true,
+		`{"list_type": {"symbol": []}}`: true,
+		`{"get_action_class": {"action_classes": [null]}}`:            true,
+		`{"get_action_attribute": ""}`:                                false,
+		`{"get_action_attribute": false}`:                             false,
+		`{"list_type": {"symbol": [{"attributes": {"name": "a1"}}]}}`: false,
+		"not json": false,
+	}
+	for result, expected := range tests {
+		if isEmptyOpResult(result) != expected {
+			t.Errorf("%q: expected empty=%v", result, expected)
+		}
+	}
+}
+
+func TestMockOpStatementErrors(t *testing.T) {
+	_, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.PutObject("action", name, map[string]interface{}{"command": "`hostname`"})
+	defer mockServer.DeleteObject(name)
+
+	state, _, diags := testOpStatementApply(t, meta, nil, map[string]interface{}{"create_statement": "action " + name + " = `hostname`"})
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "Failed to run create_statement") || !strings.Contains(diags[0].Summary, "already exists") {
+		t.Errorf("Expected the create_statement to fail, got: %+v", diags)
+	}
+	if state != nil && state.ID != "" {
+		t.Errorf("Expected nothing in the state, got: %+v", state)
+	}
+
+	// the read_statement doesn't find what the create_statement made, which re-creating wouldn't fix
+	other := RandomAlphaPrefix(5) + "_action"
+	defer mockServer.DeleteObject(other)
+	_, _, diags = testOpStatementApply(t, meta, nil, map[string]interface{}{
+		"create_statement": "action " + other + " = `hostname`",
+		"read_statement":   other + "_missing.description",
+	})
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "Failed to run read_statement") {
+		t.Errorf("Expected the read_statement to fail the create, got: %+v", diags)
+	}
+
+	// a read_statement (e.g. a list) that finds nothing also fails the create ...
+	_, _, diags = testOpStatementApply(t, meta, nil, map[string]interface{}{
+		"create_statement": other + `.description = "x"`,
+		"read_statement":   `list actions | name = "` + other + `_missing"`,
+	})
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "found nothing") {
+		t.Errorf("Expected the empty read_statement result to fail the create, got: %+v", diags)
+	}
+
+	// ... and removes the resource from the state on refresh
+	state, _, diags = testOpStatementApply(t, meta, nil, map[string]interface{}{
+		"create_statement": other + `.description = "y"`,
+		"read_statement":   `list actions | name = "` + other + `"`,
+	})
+	if diags.HasError() || state == nil || !strings.Contains(state.Attributes["read_result"], other) {
+		t.Fatalf("Failed to create: %+v, %+v", diags, state)
+	}
+	mockServer.DeleteObject(other)
+	refreshed, diags := ResourceShorelineOpStatement().RefreshWithoutUpgrade(context.Background(), state, meta)
+	if diags.HasError() || refreshed != nil {
+		t.Errorf("Expected the resource to be removed from the state, got: %+v, %+v", diags, refreshed)
+	}
+
+	validate := ResourceShorelineOpStatement().Schema["create_statement"].ValidateFunc
+	if _, errs := validate(`a1.description = "x`, "create_statement"); len(errs) != 1 {
+		t.Errorf("Expected an invalid statement to fail at plan time, got: %v", errs)
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// OpSyntaxError is a syntax error in an op statement, at a (1-based, in characters) column.
+type OpSyntaxError struct {
+	Column  int
+	Message string
+}
+
+func (e *OpSyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Message)
+}
+
+func opSyntaxError(column int, format string, args ...interface{}) *OpSyntaxError {
+	return &OpSyntaxError{Column: column, Message: fmt.Sprintf(format, args...)}
+}
+
+type opTokenKind int
+
+const (
+	opTokenWord      opTokenKind = iota // identifiers, numbers, keywords, $variables
+	opTokenString                       // '...' or "..."
+	opTokenShell                        // `...`
+	opTokenOperator                     // e.g. ==, +, =
+	opTokenOpen                         // ( [ {
+	opTokenClose                        // ) ] }
+	opTokenPipe                         // |
+	opTokenComma                        // ,
+	opTokenSeparator                    // ;
+)
+
+type opToken struct {
+	kind   opTokenKind
+	text   string
+	column int
+}
+
+var opMultiCharOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "=~", "!~"}
+
+var opClosingBrackets = map[rune]rune{'(': ')', '[': ']', '{': '}'}
+
+func isOpWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '$'
+}
+
+// lexOpStatement splits an op statement into tokens.
+// Quoted strings and (backtick) shell commands are single tokens, as their content isn't op syntax.
+func lexOpStatement(stmt string) ([]opToken, error) {
+	runes := []rune(stmt)
+	tokens := []opToken{}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		column := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i += 1
+		case r == '\'' || r == '"' || r == '`':
+			end := i + 1
+			for ; end < len(runes) && runes[end] != r; end += 1 {
+				if runes[end] == '\\' {
+					end += 1
+				}
+			}
+			if end >= len(runes) {
+				if r == '`' {
+					return nil, opSyntaxError(column, "unterminated shell command, missing closing '`'")
+				}
+				return nil, opSyntaxError(column, "unterminated string, missing closing '%c'", r)
+			}
+			kind := opTokenString
+			if r == '`' {
+				kind = opTokenShell
+			}
+			tokens = append(tokens, opToken{kind, string(runes[i : end+1]), column})
+			i = end + 1
+		case isOpWordRune(r):
+			end := i
+			for end < len(runes) && isOpWordRune(runes[end]) {
+				end += 1
+			}
+			tokens = append(tokens, opToken{opTokenWord, string(runes[i:end]), column})
+			i = end
+		case r == '(' || r == '[' || r == '{':
+			tokens = append(tokens, opToken{opTokenOpen, string(r), column})
+			i += 1
+		case r == ')' || r == ']' || r == '}':
+			tokens = append(tokens, opToken{opTokenClose, string(r), column})
+			i += 1
+		case r == ',':
+			tokens = append(tokens, opToken{opTokenComma, ",", column})
+			i += 1
+		case r == ';':
+			tokens = append(tokens, opToken{opTokenSeparator, ";", column})
+			i += 1
+		default:
+			text := string(r)
+			for _, op := range opMultiCharOperators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					text = op
+					break
+				}
+			}
+			kind := opTokenOperator
+			if text == "|" {
+				kind = opTokenPipe
+			}
+			tokens = append(tokens, opToken{kind, text, column})
+			i += len([]rune(text))
+		}
+	}
+	return tokens, nil
+}
+
+const (
+	opIfCondition = iota // between 'if' and 'then'
+	opIfThen             // between 'then' and 'else'/'fi'
+	opIfElse             // between 'else' and 'fi'
+)
+
+type opIfBlock struct {
+	token  opToken
+	state  int
+	tokens int // in the current part (condition, then, or else)
+}
+
+// opScope is the top level of a statement, or the inside of a pair of brackets.
+type opScope struct {
+	open *opToken // nil at the top level
+	ifs  []*opIfBlock
+	// no tokens yet in the current pipe stage
+	stageEmpty bool
+	// the pipe before the current stage, if any
+	pipe *opToken
+}
+
+func (scope *opScope) startStage() {
+	scope.stageEmpty = true
+	scope.pipe = nil
+}
+
+// endStage checks that a pipe isn't followed by an empty stage.
+func (scope *opScope) endStage() error {
+	if scope.stageEmpty && scope.pipe != nil {
+		return opSyntaxError(scope.pipe.column, "missing expression after '|'")
+	}
+	return nil
+}
+
+func (scope *opScope) addContent() {
+	scope.stageEmpty = false
+	if len(scope.ifs) > 0 {
+		scope.ifs[len(scope.ifs)-1].tokens += 1
+	}
+}
+
+func (scope *opScope) checkIfsClosed() error {
+	if len(scope.ifs) == 0 {
+		return nil
+	}
+	block := scope.ifs[len(scope.ifs)-1]
+	if block.state == opIfCondition {
+		return opSyntaxError(block.token.column, "'if' is missing 'then'")
+	}
+	return opSyntaxError(block.token.column, "'if' is missing 'fi'")
+}
+
+// keyword handles 'if', 'then', 'else' and 'fi'. Returns false for other words.
+func (scope *opScope) keyword(tok opToken) (bool, error) {
+	var block *opIfBlock
+	if len(scope.ifs) > 0 {
+		block = scope.ifs[len(scope.ifs)-1]
+	}
+	switch tok.text {
+	case "if":
+		scope.addContent()
+		scope.ifs = append(scope.ifs, &opIfBlock{token: tok, state: opIfCondition})
+	case "then":
+		if block == nil || block.state != opIfCondition {
+			return true, opSyntaxError(tok.column, "'then' without a matching 'if'")
+		}
+		if err := scope.endStage(); err != nil {
+			return true, err
+		}
+		if block.tokens == 0 {
+			return true, opSyntaxError(tok.column, "missing condition between 'if' (at column %d) and 'then'", block.token.column)
+		}
+		block.state, block.tokens = opIfThen, 0
+	case "else":
+		if block == nil || block.state != opIfThen {
+			return true, opSyntaxError(tok.column, "'else' without a matching 'if ... then'")
+		}
+		if err := scope.endStage(); err != nil {
+			return true, err
+		}
+		if block.tokens == 0 {
+			return true, opSyntaxError(tok.column, "missing statement between 'then' and 'else'")
+		}
+		block.state, block.tokens = opIfElse, 0
+	case "fi":
+		if block == nil {
+			return true, opSyntaxError(tok.column, "'fi' without a matching 'if'")
+		}
+		if block.state == opIfCondition {
+			return true, opSyntaxError(tok.column, "'fi' before 'then' (for the 'if' at column %d)", block.token.column)
+		}
+		if err := scope.endStage(); err != nil {
+			return true, err
+		}
+		if block.tokens == 0 {
+			return true, opSyntaxError(tok.column, "missing statement before 'fi'")
+		}
+		scope.ifs = scope.ifs[:len(scope.ifs)-1]
+		// the if block as a whole is part of the enclosing stage (or block)
+		scope.addContent()
+		return true, nil
+	default:
+		return false, nil
+	}
+	scope.startStage()
+	return true, nil
+}
+
+// ValidateOpStatement checks the syntax of an op statement, for the errors that can be found
+// without the backend: unbalanced quotes and brackets, empty pipe stages, and malformed
+// 'if ... then ... [else ...] fi' blocks. Returns an *OpSyntaxError (or nil).
+func ValidateOpStatement(stmt string) error {
+	tokens, err := lexOpStatement(stmt)
+	if err != nil {
+		return err
+	}
+	scopes := []*opScope{{}}
+	scopes[0].startStage()
+	for i := range tokens {
+		tok := tokens[i]
+		scope := scopes[len(scopes)-1]
+		switch tok.kind {
+		case opTokenOpen:
+			scope.addContent()
+			inner := &opScope{open: &tokens[i]}
+			inner.startStage()
+			scopes = append(scopes, inner)
+		case opTokenClose:
+			if scope.open == nil {
+				return opSyntaxError(tok.column, "unbalanced '%s'", tok.text)
+			}
+			if want := string(opClosingBrackets[[]rune(scope.open.text)[0]]); tok.text != want {
+				return opSyntaxError(tok.column, "'%s' doesn't match the '%s' at column %d", tok.text, scope.open.text, scope.open.column)
+			}
+			if err := scope.endStage(); err != nil {
+				return err
+			}
+			if err := scope.checkIfsClosed(); err != nil {
+				return err
+			}
+			scopes = scopes[:len(scopes)-1]
+		case opTokenPipe:
+			if scope.stageEmpty {
+				return opSyntaxError(tok.column, "missing expression before '|'")
+			}
+			scope.stageEmpty = true
+			scope.pipe = &tokens[i]
+		case opTokenComma, opTokenSeparator:
+			if err := scope.endStage(); err != nil {
+				return err
+			}
+			scope.startStage()
+		case opTokenWord:
+			if isKeyword, err := scope.keyword(tok); isKeyword {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			scope.addContent()
+		default:
+			scope.addContent()
+		}
+	}
+	if len(scopes) > 1 {
+		open := scopes[len(scopes)-1].open
+		return opSyntaxError(open.column, "unbalanced '%s', missing '%c'", open.text, opClosingBrackets[[]rune(open.text)[0]])
+	}
+	if err := scopes[0].endStage(); err != nil {
+		return err
+	}
+	return scopes[0].checkIfsClosed()
+}
@@ -4,10 +4,12 @@
 package provider
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 
@@ -35,16 +37,10 @@ type CliOpts struct {
 	AuthChanged bool
 	Url         string
 	Token       string
+	// the auth file (.ops_auth.yaml) the token was loaded from, if any
+	AuthFile string
 }
 
-var AuthUrl string
-var AuthToken string
-var RetryLimit int
-var DoDebugLog = false
-var GlobalOpts = CliOpts{}
-
-var clientAuth *ClientAuth
-
 var AuthConfig = viper.New()
 
 func GetHomeDir() string {
@@ -118,13 +114,12 @@ func LoadAuthConfig(GlobalOpts *CliOpts) bool {
 }
 
 func SetAuth(GlobalOpts *CliOpts, Url string, Token string) {
-	AuthUrl = Url
-	AuthToken = Token
 	// set default
 	GlobalOpts.Url = Url
 	GlobalOpts.Token = Token
 	GlobalOpts.HasAuth = true
 	GlobalOpts.AuthChanged = true
+	GlobalOpts.AuthFile = ""
 
 	//AddAuthEntry(GlobalOpts, Url, Token, false)
 }
@@ -158,6 +153,8 @@ func selectAuth(GlobalOpts *CliOpts, toUrl string) bool {
 		}
 		if url == toUrl {
 			SetAuth(GlobalOpts, toUrl, token)
+			// so that a rotated refresh token can be saved back
+			GlobalOpts.AuthFile = AuthConfig.ConfigFileUsed()
 			return true
 		}
 	}
@@ -172,7 +169,8 @@ func GetManualAuthMessage(GlobalOpts *CliOpts) string {
 }
 
 func ValidateApiUrl(url string) bool {
-	urlRegex := regexp.MustCompile(`^https?://[\.\:a-z0-9-]+$`)
+	// optional path, e.g. when behind a reverse proxy
+	urlRegex := regexp.MustCompile(`^https?://[\.\:a-z0-9-]+(/[A-Za-z0-9._~%-]+)*/?$`)
 	if !urlRegex.MatchString(url) {
 		WriteMsg("ERROR: Invalid URL to auth! (%s)\n", url)
 		return false
@@ -215,37 +213,130 @@ func GetInnerErrorStr(errStr string) string {
 	return GetInnerErrorStr(innerStr)
 }
 
+// innerError has the message extracted by GetInnerError(), while still wrapping the original error.
+type innerError struct {
+	msg string
+	err error
+}
+
+func (e *innerError) Error() string {
+	return e.msg
+}
+
+func (e *innerError) Unwrap() error {
+	return e.err
+}
+
 func GetInnerError(err error) string {
 	outer := error.Error(err)
 	innerStr := GetInnerErrorStr(outer)
 	return innerStr
 }
 
-func ExecuteOpCommand(GlobalOpts *CliOpts, expr string) (string, error) {
-	if !GlobalOpts.HasAuth {
-		return "", fmt.Errorf("No valid auth credentials.")
-	} else {
-		if clientAuth == nil || clientAuth.BaseURL != GlobalOpts.Url || GlobalOpts.AuthChanged {
-			// Auth data is persisted, so that we don't have to re-authorize for every command
-			clientAuth = NewClientAuth(GlobalOpts.Url, GlobalOpts.Token, GetIdempotencyKey())
-			GlobalOpts.AuthChanged = false
-		} else {
-			// Fresh Idempotency key for every command.
-			clientAuth.ApiKey = GetIdempotencyKey()
+func ExecuteOpCommand(ctx context.Context, client *apiClient, expr string) (string, error) {
+	new_client, err := newOpClient(client)
+	if err != nil {
+		return "", err
+	}
+	if key, hasKey := ctx.Value(idempotencyKeyKey{}).(string); hasKey {
+		new_client.authData.ApiKey = key
+	}
+	fullExpr := expr
+	//fix this to be resolved input
+	ret, error := new_client.Execute(ctx, fullExpr, false)
+	if error != nil {
+		return "", wrapExecuteError(error, expr)
+	}
+	retStr := string(ret)
+	return retStr, nil
+}
+
+// ExecuteOpBatch runs all of the op statements in a single request, returning the per-statement results.
+func ExecuteOpBatch(ctx context.Context, client *apiClient, exprs []string) ([]string, error) {
+	new_client, err := newOpClient(client)
+	if err != nil {
+		return nil, err
+	}
+	rets, error := new_client.ExecuteBatch(ctx, exprs, false)
+	if error != nil {
+		if errors.Is(error, ErrBatchUnsupported) {
+			return nil, error
 		}
-		fullExpr := expr
-		new_client := NewClient(clientAuth)
-		//fix this to be resolved input
-		ret, error := new_client.Execute(fullExpr, false)
-		if error != nil {
-			inner := GetInnerError(error)
-			return "", fmt.Errorf(inner)
-
-		} else {
-			retStr := string(ret)
-			return retStr, nil
+		return nil, wrapExecuteError(error, "")
+	}
+	results := make([]string, len(rets))
+	for i, ret := range rets {
+		results[i] = string(ret)
+	}
+	return results, nil
+}
+
+type idempotencyKeyKey struct{}
+
+// withIdempotencyKey sends the statements run with the returned context with the same idempotency key,
+// rather than a fresh one per request, so that the API server runs a side-effecting statement (e.g. an
+// action) only once, even if it's retried.
+func withIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+func newOpClient(client *apiClient) (*Client, error) {
+	client.authMu.Lock()
+	defer client.authMu.Unlock()
+	opts := &client.opts
+	if !opts.HasAuth {
+		return nil, fmt.Errorf("No valid auth credentials.")
+	}
+	options := []clientOption{setRateLimiterOption(client.limiter), setMetricsOption(client.metrics)}
+	if client.httpClient != nil {
+		options = append(options, setHTTPClientOption(client.httpClient))
+	}
+	baseUrl := JoinApiBasePath(opts.Url, client.apiBasePath)
+	if client.auth == nil || client.auth.BaseURL != baseUrl || opts.AuthChanged {
+		// Auth data (and the access token) is persisted, so that we don't have to re-authorize for every command
+		client.auth = NewClientAuthWithRefresh(baseUrl, opts.Token, "", client.tokenRefreshFraction, options...)
+		opts.AuthChanged = false
+		if opts.AuthFile != "" {
+			authFile, authUrl := opts.AuthFile, opts.Url
+			client.auth.Tokens.OnRotate(func(ctx context.Context, oldToken string, newToken string) {
+				saveRotatedToken(ctx, authFile, authUrl, oldToken, newToken)
+			})
 		}
 	}
+	// Fresh Idempotency key for every command (on a copy, as resources are operated on in parallel).
+	auth := *client.auth
+	auth.ApiKey = GetIdempotencyKey()
+	return NewClient(&auth, options...), nil
+}
+
+// saveRotatedToken writes a rotated refresh token back to the auth file, as the old one
+// may stop working (e.g. for the next run on a long-lived CI runner).
+func saveRotatedToken(ctx context.Context, authFile string, url string, oldToken string, newToken string) {
+	changed, err := UpdateAuthFileToken(authFile, url, oldToken, newToken)
+	if err != nil {
+		WriteMsg("WARNING Failed to save the rotated refresh token to '%s': %s\n", authFile, err.Error())
+		logWarn(ctx, logAuth, fmt.Sprintf("Failed to save the rotated refresh token to '%s'", authFile), map[string]interface{}{logFieldError: err.Error()})
+		return
+	}
+	if changed {
+		logInfo(ctx, logAuth, fmt.Sprintf("Saved the rotated refresh token for '%s' to '%s'", url, authFile))
+	}
+}
+
+func orDefaultHttpClient(httpClient *http.Client) *http.Client {
+	if httpClient == nil {
+		return http.DefaultClient
+	}
+	return httpClient
+}
+
+func wrapExecuteError(err error, statement string) error {
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) {
+		// keeps the status (via Unwrap), so that callers can tell transient failures apart
+		return newHttpBackendError(statusErr, statement)
+	}
+	return &innerError{msg: GetInnerError(err), err: err}
 }
 
 // Returns compressed base64 data, file size, md5 checksum.
@@ -293,14 +384,15 @@ func FileMd5AndSize(filename string) (error, string, int64) {
 
 // //////////////////////////////////////////////////////////
 // //////////////////////////////////////////////////////////
-func DownloadFileHttps(src string, dst string, token string) error {
+// The presigned url functions take the provider http client (nil for the default one).
+func DownloadFileHttps(httpClient *http.Client, src string, dst string, token string) error {
 	out, err := os.Create(dst)
 	if err != nil {
 		return fmt.Errorf("Couldn't open local download file '%s'\n", dst)
 	}
 	defer out.Close()
 
-	resp, err := http.Get(src)
+	resp, err := orDefaultHttpClient(httpClient).Get(src)
 	if err != nil {
 		return fmt.Errorf("Couldn't open download url '%s'\n", src)
 	}
@@ -316,7 +408,7 @@ func DownloadFileHttps(src string, dst string, token string) error {
 	return nil
 }
 
-func UploadFileHttps(src string, dst string, token string) error {
+func UploadFileHttps(httpClient *http.Client, src string, dst string, token string) error {
 	file, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("couldn't open local upload file '%s'", src)
@@ -337,7 +429,7 @@ func UploadFileHttps(src string, dst string, token string) error {
 	reqOb.Header.Set("x-ms-blob-type", "BlockBlob") // only used by Azure, ignored by S3
 	reqOb.ContentLength = fileSize
 
-	response, err := http.DefaultClient.Do(reqOb)
+	response, err := orDefaultHttpClient(httpClient).Do(reqOb)
 	if err != nil {
 		fmt.Printf("couldn't upload file: %s", err.Error())
 		return fmt.Errorf("couldn't upload file: %s", err.Error())
@@ -353,7 +445,7 @@ func UploadFileHttps(src string, dst string, token string) error {
 	return nil
 }
 
-func UploadFileHttpsFromString(data string, dst string, token string) error {
+func UploadFileHttpsFromString(httpClient *http.Client, data string, dst string, token string) error {
 	f, err := os.CreateTemp("", "tmpfile-") // in Go version older than 1.17 you can use ioutil.TempFile
 	if err != nil {
 		return err
@@ -365,12 +457,12 @@ func UploadFileHttpsFromString(data string, dst string, token string) error {
 	if _, err := f.Write([]byte(data)); err != nil {
 		return err
 	}
-	err = UploadFileHttps(f.Name(), dst, token)
+	err = UploadFileHttps(httpClient, f.Name(), dst, token)
 	return err
 }
 
-func DeleteFileHttps(dst string, token string) error {
-	resp, err := http.Get(dst)
+func DeleteFileHttps(httpClient *http.Client, dst string, token string) error {
+	resp, err := orDefaultHttpClient(httpClient).Get(dst)
 	if err != nil {
 		fmt.Printf("Couldn't open delete url '%s'\n", dst)
 		return fmt.Errorf("Couldn't open delete url '%s'\n", dst)
@@ -380,7 +472,7 @@ func DeleteFileHttps(dst string, token string) error {
 	return nil
 }
 
-func DownloadFileHttpsToTemp(src string, token string) (string, error) {
+func DownloadFileHttpsToTemp(httpClient *http.Client, src string, token string) (string, error) {
 	f, err := os.CreateTemp("", "tmp_shor_opcp-") // in Go version older than 1.17 you can use ioutil.TempFile
 	if err != nil {
 		return "", err
@@ -389,7 +481,7 @@ func DownloadFileHttpsToTemp(src string, token string) (string, error) {
 	// The caller is responsible for cleaning up the file.
 	// XXX we could delete it and pass the handle to the caller...
 	//defer os.Remove(f.Name())
-	err = DownloadFileHttps(src, f.Name(), token)
+	err = DownloadFileHttps(httpClient, src, f.Name(), token)
 	return f.Name(), err
 }
 
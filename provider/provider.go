@@ -7,18 +7,23 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // XXX when we move to go 1.20.X, convert the config to a json file...
@@ -59,7 +64,7 @@ func Base64ToJson(data string) (map[string]interface{}, error) {
 }
 
 func OmitJsonObjectFields(val map[string]interface{}, omitList []interface{}) map[string]interface{} {
-	appendActionLog(fmt.Sprintf("Omitting (obj) keys: %+v\n", omitList))
+	logDebug(context.Background(), logSchema, fmt.Sprintf("Omitting (obj) keys: %+v", omitList))
 	for _, o := range omitList {
 		oStr, isStr := o.(string)
 		if isStr {
@@ -137,57 +142,69 @@ func timeSuffixToIntSec(tv string) int {
 	return i * mult
 }
 
-func appendActionLogInner(msg string) {
-	filename := "/tmp/tf-shoreline.log"
-	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	if err != nil {
-		//panic(err)
-		return
+func runOpCommand(ctx context.Context, client *apiClient, command string, checkResult bool) (string, error) {
+	if client == nil {
+		return "", fmt.Errorf("No valid auth credentials.")
 	}
-	defer f.Close()
-	id := fmt.Sprintf("gid:%d - ", curGoroutineID())
-	if _, err = f.WriteString(id + msg); err != nil {
-		//panic(err)
-		return
-	}
-}
-
-func appendActionLog(msg string) {
-	if !DoDebugLog {
-		return
-	}
-	appendActionLogInner(msg)
-}
-
-func runOpCommand(command string, checkResult bool) (string, error) {
-	//var GlobalOpts = CliOpts{}
-	//if !LoadAuthConfig(&GlobalOpts) {
-	//	return "", fmt.Errorf("Failed to load auth credentials")
-	//}
 	result := ""
 	err := error(nil)
-	for r := 0; r <= RetryLimit; r += 1 {
-		appendActionLog(fmt.Sprintf("Running OpLang command (retries %d/%d)   ---   command:(( %s ))\n", r, RetryLimit, command))
-		result, err = ExecuteOpCommand(&GlobalOpts, command)
+	start := time.Now()
+	ctx = withStatementKind(ctx, statementKind(command))
+	for r := 0; ; r += 1 {
+		retryFields := map[string]interface{}{logFieldStatement: command, logFieldRetry: r}
+		logDebug(ctx, logHttp, fmt.Sprintf("Running OpLang command (retries %d/%d)", r, client.retryLimit), retryFields)
+		result, err = ExecuteOpCommand(ctx, client, command)
 		if err == nil {
 			if !checkResult {
 				return result, err
 			}
-			err = CheckUpdateResult(result)
+			err = annotateBackendError(CheckUpdateResult(result), command, "", "")
 			if err == nil {
 				return result, err
 			} else {
-				appendActionLog(fmt.Sprintf("Failed OpLang update (retries %d/%d)   ---   error:(( %s ))\n", r, RetryLimit, err.Error()))
+				logWarn(ctx, logHttp, fmt.Sprintf("Failed OpLang update (retries %d/%d)", r, client.retryLimit), retryFields, map[string]interface{}{logFieldError: err.Error()})
 			}
 		} else {
-			appendActionLog(fmt.Sprintf("Failed OpLang command (retries %d/%d)   ---   error:(( %s ))\n", r, RetryLimit, err.Error()))
+			logWarn(ctx, logHttp, fmt.Sprintf("Failed OpLang command (retries %d/%d)", r, client.retryLimit), retryFields, map[string]interface{}{logFieldError: err.Error()})
+		}
+		if !client.waitToRetry(ctx, r, start, err) {
+			return result, err
 		}
 	}
-	return result, err
 }
 
-func runOpCommandToJson(command string) (map[string]interface{}, error) {
-	result, err := runOpCommand(command, false)
+// waitToRetry backs off before retry number 'attempt' of a failed request.
+// Returns false (right away) if the error isn't transient, or the retries are used up.
+// Rate limited (429) requests are retried regardless of 'retries', until the retry deadline (if any) or cancellation.
+func (client *apiClient) waitToRetry(ctx context.Context, attempt int, start time.Time, err error) bool {
+	var statusErr *HttpStatusError
+	rateLimited := errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests
+	if (attempt >= client.retryLimit && !rateLimited) || ctx.Err() != nil || !isRetryableError(err) {
+		return false
+	}
+	delay := retryBackoff(attempt, client.retryMaxBackoff)
+	if statusErr != nil && statusErr.RetryAfter > delay {
+		// e.g. rate limited, wait as long as the server asks
+		delay = statusErr.RetryAfter
+	}
+	// a zero deadline is no limit, past the retries only cancellation stops rate limited retries then
+	deadline := client.retryDeadline
+	if deadline > 0 && time.Since(start)+delay > deadline {
+		logWarn(ctx, logHttp, fmt.Sprintf("Giving up on OpLang command, retry deadline (%s) exceeded", deadline), map[string]interface{}{logFieldRetry: attempt})
+		return false
+	}
+	select {
+	case <-ctx.Done():
+		logDebug(ctx, logHttp, "Cancelled OpLang command retries", map[string]interface{}{logFieldRetry: attempt, logFieldError: ctx.Err().Error()})
+		return false
+	case <-time.After(delay):
+		client.metrics.recordRetry(ctx)
+		return true
+	}
+}
+
+func runOpCommandToJson(ctx context.Context, client *apiClient, command string) (map[string]interface{}, error) {
+	result, err := runOpCommand(ctx, client, command, false)
 	if err != nil {
 		errOut := fmt.Errorf("Failed to execute op '%s': %s", command, err.Error())
 		return nil, errOut
@@ -217,83 +234,6 @@ func getNamedObjectFromClassDef(name string, typ string, classJs map[string]inte
 	return map[string]interface{}{}
 }
 
-func CheckUpdateResult(result string) error {
-	js := map[string]interface{}{}
-	err := json.Unmarshal([]byte(result), &js)
-	if err != nil {
-		return fmt.Errorf("Failed parse json result from resource update %s", err.Error())
-	}
-
-	actions := []string{"define", "delete", "update"}
-	types := []string{"resource", "metric", "alarm", "action", "bot", "file", "integration", "notebook", "configuration", "time_trigger", "circuit_breaker", "principal", "report_template", "dashboard"}
-	for _, act := range actions {
-		for _, typ := range types {
-			key := act + "_" + typ
-			def := GetNestedValueOrDefault(js, ToKeyPath(key), nil)
-			if def != nil {
-				errKey := key + ".error.message"
-				err := GetNestedValueOrDefault(js, ToKeyPath(errKey), nil)
-				if (typ == "notebook" || typ == "runbook" || typ == "configuration") && (err == nil || err == "") {
-					// have to special-case for notebooks
-					err = ""
-					errArray := []string{}
-					ve, isArray := GetNestedValueOrDefault(js, ToKeyPath(key+".error.validation_errors"), nil).([]interface{})
-					if isArray {
-						for i, _ := range ve {
-							errn, isStr := GetNestedValueOrDefault(js, ToKeyPath(fmt.Sprintf(key+".error.validation_errors.[%d].message", i)), nil).(string)
-							if isStr && errn != "" {
-								errArray = append(errArray, errn)
-							}
-							err = strings.Join(errArray, "\n")
-						}
-					}
-				}
-				if err == nil || err == "" {
-					// success ...
-					return nil
-				} else {
-					errStr := GetInnerErrorStr(CastToString(err))
-					// error ...
-					return fmt.Errorf("ERROR: %s.\n", errStr)
-				}
-			}
-		}
-	}
-
-	// Added error message location...
-	execStmtErrors := GetNestedValueOrDefault(js, ToKeyPath("execute_statement_errors"), nil)
-	if execStmtErrors != nil {
-		errorStrArray := []string{}
-		hdArray, isHdArray := execStmtErrors.([]interface{})
-		if isHdArray {
-			for _, entry := range hdArray {
-				entryMap, isMap := entry.(map[string]interface{})
-				if !isMap {
-					continue
-				}
-
-				if errors, ok := entryMap["errors"]; ok {
-					errorsArr, isArr := errors.([]interface{})
-					if !isArr {
-						continue
-					}
-					for _, msg := range errorsArr {
-						msgStr, isStr := msg.(string)
-						if isStr {
-							errorStrArray = append(errorStrArray, msgStr)
-						}
-					}
-				}
-			}
-		}
-		if len(errorStrArray) > 0 {
-			return fmt.Errorf("ERROR: %s.\n", strings.Join(errorStrArray, "\n"))
-		}
-	}
-
-	return nil
-}
-
 // Takes a regex like: "if (?P<if_expr>.*?) then (?P<then_expr>.*?) fi"
 // and parses out the named captures (e.g. 'if_expr', 'then_expr')
 // into the returned map, with the name as a key, and the match as the value.
@@ -399,14 +339,14 @@ func ExtractVersionData(verStr string) (major int64, minor int64, patch int64, e
 	return
 }
 
-func GetBackendVersionInfo() (build string, version string, major int64, minor int64, patch int64, err *error) {
+func GetBackendVersionInfo(ctx context.Context, client *apiClient) (build string, version string, major int64, minor int64, patch int64, err *error) {
 	err = nil
 	build = "unknown"
 	version = "unknown"
 	major, minor, patch = 0, 0, 0
 	// op> backend_version
 	// ... "get_backend_version": "{ \"tag\": \"release-1.2.3-stuff\", \"build_date\": \"Wed_May_18_00:07:11_UTC_2022\" }", ...
-	js, opErr := runOpCommandToJson("backend_version")
+	js, opErr := runOpCommandToJson(ctx, client, "backend_version")
 	if opErr != nil {
 		return
 	}
@@ -427,9 +367,9 @@ func GetBackendVersionInfo() (build string, version string, major int64, minor i
 	return
 }
 
-func GetBackendVersionInfoStruct() VersionRecord {
+func GetBackendVersionInfoStruct(ctx context.Context, client *apiClient) VersionRecord {
 	var ver VersionRecord
-	ver.Build, ver.Version, ver.Major, ver.Minor, ver.Patch, ver.Error = GetBackendVersionInfo()
+	ver.Build, ver.Version, ver.Major, ver.Minor, ver.Patch, ver.Error = GetBackendVersionInfo(ctx, client)
 	ver.Valid = (ver.Error == nil)
 	return ver
 }
@@ -447,7 +387,7 @@ func dataSourceVersionRead(ctx context.Context, d *schema.ResourceData, m interf
 
 	// Warning or errors can be collected in a slice type
 	var diags diag.Diagnostics
-	build, version, major, minor, patch, err := GetBackendVersionInfo()
+	build, version, major, minor, patch, err := GetBackendVersionInfo(ctx, m.(*apiClient))
 	if err != nil {
 		diags = diag.Errorf("Failed to read backend_version: %s", (*err).Error())
 		return diags
@@ -486,8 +426,54 @@ func New(version string) func() *schema.Provider {
 				"shoreline_system_settings": ResourceShorelineObject(ObjectConfigJsonStr, "system_settings"),
 				"shoreline_report_template": ResourceShorelineObject(ObjectConfigJsonStr, "report_template"),
 				"shoreline_dashboard":       ResourceShorelineObject(ObjectConfigJsonStr, "dashboard"),
+
+				// runs a runbook or action during apply (rather than defining an object)
+				"shoreline_runbook_run": ResourceShorelineRunbookRun(),
+				"shoreline_action_run":  ResourceShorelineActionRun(),
+
+				// raw op statements, for what the objects above don't support (yet)
+				"shoreline_op_statement": ResourceShorelineOpStatement(),
 			},
 			DataSourcesMap: map[string]*schema.Resource{
+				"shoreline_action":          DataSourceShorelineObject(ObjectConfigJsonStr, "action"),
+				"shoreline_alarm":           DataSourceShorelineObject(ObjectConfigJsonStr, "alarm"),
+				"shoreline_time_trigger":    DataSourceShorelineObject(ObjectConfigJsonStr, "time_trigger"),
+				"shoreline_bot":             DataSourceShorelineObject(ObjectConfigJsonStr, "bot"),
+				"shoreline_circuit_breaker": DataSourceShorelineObject(ObjectConfigJsonStr, "circuit_breaker"),
+				"shoreline_file":            DataSourceShorelineObject(ObjectConfigJsonStr, "file"),
+				"shoreline_integration":     DataSourceShorelineObject(ObjectConfigJsonStr, "integration"),
+				"shoreline_metric":          DataSourceShorelineObject(ObjectConfigJsonStr, "metric"),
+				"shoreline_notebook":        DataSourceShorelineObject(ObjectConfigJsonStr, "notebook"),
+				"shoreline_runbook":         DataSourceShorelineObject(ObjectConfigJsonStr, "notebook"), // alias shoreline_runbook to shoreline_notebook
+				"shoreline_principal":       DataSourceShorelineObject(ObjectConfigJsonStr, "principal"),
+				"shoreline_resource":        DataSourceShorelineObject(ObjectConfigJsonStr, "resource"),
+				"shoreline_system_settings": DataSourceShorelineObject(ObjectConfigJsonStr, "system_settings"),
+				"shoreline_report_template": DataSourceShorelineObject(ObjectConfigJsonStr, "report_template"),
+				"shoreline_dashboard":       DataSourceShorelineObject(ObjectConfigJsonStr, "dashboard"),
+
+				// lists, with filters
+				"shoreline_actions":          DataSourceShorelineObjectList(ObjectConfigJsonStr, "action"),
+				"shoreline_alarms":           DataSourceShorelineObjectList(ObjectConfigJsonStr, "alarm"),
+				"shoreline_time_triggers":    DataSourceShorelineObjectList(ObjectConfigJsonStr, "time_trigger"),
+				"shoreline_bots":             DataSourceShorelineObjectList(ObjectConfigJsonStr, "bot"),
+				"shoreline_circuit_breakers": DataSourceShorelineObjectList(ObjectConfigJsonStr, "circuit_breaker"),
+				"shoreline_files":            DataSourceShorelineObjectList(ObjectConfigJsonStr, "file"),
+				"shoreline_integrations":     DataSourceShorelineObjectList(ObjectConfigJsonStr, "integration"),
+				"shoreline_metrics":          DataSourceShorelineObjectList(ObjectConfigJsonStr, "metric"),
+				"shoreline_notebooks":        DataSourceShorelineObjectList(ObjectConfigJsonStr, "notebook"),
+				"shoreline_runbooks":         DataSourceShorelineObjectList(ObjectConfigJsonStr, "notebook"), // alias shoreline_runbooks to shoreline_notebooks
+				"shoreline_principals":       DataSourceShorelineObjectList(ObjectConfigJsonStr, "principal"),
+				"shoreline_resource_objects": DataSourceShorelineObjectList(ObjectConfigJsonStr, "resource"), // 'shoreline_resources' runs resource queries
+				"shoreline_report_templates": DataSourceShorelineObjectList(ObjectConfigJsonStr, "report_template"),
+				"shoreline_dashboards":       DataSourceShorelineObjectList(ObjectConfigJsonStr, "dashboard"),
+
+				// the live hosts, pods and containers matching a resource query (see 'shoreline_resource_objects' for the 'shoreline_resource' objects)
+				"shoreline_resources": DataSourceShorelineResources(),
+				// the values of a metric for those resources, over a time range
+				"shoreline_metric_query": DataSourceShorelineMetricQuery(),
+				// who the provider's credentials belong to
+				"shoreline_caller_identity": DataSourceShorelineCallerIdentity(),
+
 				"shoreline_version": &schema.Resource{
 					ReadContext: dataSourceVersionRead,
 					Schema: map[string]*schema.Schema{
@@ -534,17 +520,160 @@ func New(version string) func() *schema.Provider {
 					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_TOKEN", nil),
 					Description: "Customer/user-specific authorization token for the Shoreline API server. May be provided via `SHORELINE_TOKEN` env variable.",
 				},
+				"max_requests_per_second": {
+					Type:        schema.TypeFloat,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_MAX_REQUESTS_PER_SECOND", 0),
+					ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+						if val.(float64) < 0 {
+							errs = append(errs, fmt.Errorf("%q must not be negative, but got: %v", key, val))
+						}
+						return
+					},
+					Description: "Maximum rate of requests to the API server, shared by all resources (e.g. with terraform's `-parallelism`). Zero means no limit. May be provided via `SHORELINE_MAX_REQUESTS_PER_SECOND` env variable.",
+				},
+				"max_concurrent_requests": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_MAX_CONCURRENT_REQUESTS", 0),
+					ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+						if val.(int) < 0 {
+							errs = append(errs, fmt.Errorf("%q must not be negative, but got: %v", key, val))
+						}
+						return
+					},
+					Description: "Maximum number of requests in flight to the API server, shared by all resources. Zero means no limit. May be provided via `SHORELINE_MAX_CONCURRENT_REQUESTS` env variable.",
+				},
+				"token_refresh_fraction": {
+					Type:        schema.TypeFloat,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_TOKEN_REFRESH_FRACTION", defaultTokenRefreshFraction),
+					ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+						if frac := val.(float64); frac <= 0 || frac > 1 {
+							errs = append(errs, fmt.Errorf("%q must be greater than 0 and at most 1, but got: %v", key, frac))
+						}
+						return
+					},
+					Description: "Fraction of an access token's lifetime (from its expiry claim) after which it is refreshed, ahead of expiring. May be provided via `SHORELINE_TOKEN_REFRESH_FRACTION` env variable.",
+				},
 				"retries": {
 					Type:        schema.TypeInt,
 					Optional:    true,
 					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_RETRIES", nil),
-					Description: "Number of retries for API calls, in case of e.g. transient network failures.",
+					Description: "Number of retries for API calls, in case of e.g. transient network failures. Rate limited (HTTP 429) calls are retried as the server asks (`Retry-After`) regardless, until `retry_deadline`.",
+				},
+				"retry_max_backoff": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("SHORELINE_RETRY_MAX_BACKOFF", defaultRetryMaxBackoffSec),
+					ValidateFunc: validation.IntAtLeast(0),
+					Description:  "Maximum delay (in seconds) between retries of an API call. The delay grows exponentially (with jitter) up to this value. May be provided via `SHORELINE_RETRY_MAX_BACKOFF` env variable.",
+				},
+				"retry_deadline": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("SHORELINE_RETRY_DEADLINE", defaultRetryDeadlineSec),
+					ValidateFunc: validation.IntAtLeast(0),
+					Description:  "Total time (in seconds) allowed for retrying an API call, after which the last error is returned. Zero means no limit: rate limited (HTTP 429) calls are then retried until the operation is cancelled or times out. May be provided via `SHORELINE_RETRY_DEADLINE` env variable.",
+				},
+				"api_base_path": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_API_BASE_PATH", nil),
+					Description: "Path prefix for the Shoreline API endpoints, e.g. when the API server is behind a reverse proxy. May be provided via `SHORELINE_API_BASE_PATH` env variable.",
+				},
+				"ca_cert_file": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_CA_CERT_FILE", nil),
+					Description: "PEM file with additional CA certificates to trust, e.g. for a private CA. May be provided via `SHORELINE_CA_CERT_FILE` env variable.",
+				},
+				"client_cert_file": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("SHORELINE_CLIENT_CERT_FILE", nil),
+					RequiredWith: []string{"client_key_file"},
+					Description:  "PEM file with a client certificate, for mutual TLS. Requires `client_key_file`. May be provided via `SHORELINE_CLIENT_CERT_FILE` env variable.",
+				},
+				"client_key_file": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("SHORELINE_CLIENT_KEY_FILE", nil),
+					RequiredWith: []string{"client_cert_file"},
+					Description:  "PEM file with the private key for `client_cert_file`. May be provided via `SHORELINE_CLIENT_KEY_FILE` env variable.",
+				},
+				"proxy_url": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_PROXY_URL", nil),
+					Description: "HTTP(S) proxy for all requests (otherwise the standard `HTTPS_PROXY`/`NO_PROXY` env variables apply). May be provided via `SHORELINE_PROXY_URL` env variable.",
+				},
+				"request_timeout": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("SHORELINE_REQUEST_TIMEOUT", requestTimeoutSec),
+					ValidateFunc: validation.IntAtLeast(1),
+					Description:  "Timeout (in seconds) for a single request (at least 1), including file uploads and downloads. May be provided via `SHORELINE_REQUEST_TIMEOUT` env variable.",
+				},
+				"adopt_existing": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_ADOPT_EXISTING", false),
+					Description: "Take over existing objects (of the same type and name) on create, instead of failing, e.g. to migrate objects built by hand. Can also be set per resource. May be provided via `SHORELINE_ADOPT_EXISTING` env variable.",
 				},
 				"debug": {
 					Type:        schema.TypeBool,
 					Optional:    true,
 					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_DEBUG", nil),
-					Description: "Debug logging to `/tmp/tf-shoreline.log`.",
+					Description: "Debug level logging to `log_file` (by default `tf-shoreline.log` in the temp directory).",
+				},
+				"log_file": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_LOG_FILE", nil),
+					Description: "File to append JSON log entries to (in addition to the terraform log, see `TF_LOG_PROVIDER_SHORELINE`). May be provided via `SHORELINE_LOG_FILE` env variable.",
+				},
+				"log_level": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_LOG_LEVEL", nil),
+					ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+						if _, valid := parseLogLevel(val.(string)); !valid {
+							errs = append(errs, fmt.Errorf("%q must be one of %s, but got: %s", key, strings.Join(logLevelNames, ", "), val))
+						}
+						return
+					},
+					Description: "Minimum level (`trace`, `debug`, `info`, `warn` or `error`) of the entries written to `log_file`. Defaults to `debug` if `debug` is set, and `info` otherwise. May be provided via `SHORELINE_LOG_LEVEL` env variable.",
+				},
+				"log_max_size_mb": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_LOG_MAX_SIZE_MB", defaultLogMaxSizeMb),
+					ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+						if size := val.(int); size < 0 {
+							errs = append(errs, fmt.Errorf("%q must not be negative, but got: %d", key, size))
+						}
+						return
+					},
+					Description: "Size (in megabytes) at which `log_file` is rotated. Zero means no rotation. May be provided via `SHORELINE_LOG_MAX_SIZE_MB` env variable.",
+				},
+				"log_max_backups": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_LOG_MAX_BACKUPS", defaultLogMaxBackups),
+					ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+						if count := val.(int); count < 0 {
+							errs = append(errs, fmt.Errorf("%q must not be negative, but got: %d", key, count))
+						}
+						return
+					},
+					Description: "Number of rotated log files to keep (as `<log_file>.1`, `<log_file>.2`, ...). May be provided via `SHORELINE_LOG_MAX_BACKUPS` env variable.",
+				},
+				"metrics_file": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("SHORELINE_METRICS_FILE", nil),
+					Description: "File to write a JSON summary of the API calls to (counts, latencies, retries and bytes, per statement kind and resource type) when the provider shuts down. Each provider process (e.g. of `terraform plan` and `terraform apply`) adds its summary to the file's `runs`. May be provided via `SHORELINE_METRICS_FILE` env variable.",
 				},
 				"min_version": {
 					Type:        schema.TypeString,
@@ -560,47 +689,113 @@ func New(version string) func() *schema.Provider {
 	}
 }
 
+// apiClient is the per-provider-instance state, handed to the resource functions as 'meta'.
+// Keeping it out of package globals lets aliased provider blocks (e.g. staging and prod
+// in one root module) target different clusters.
 type apiClient struct {
-	// Add whatever fields, client or connection info, etc. here
-	// you would need to setup to communicate with the upstream
-	// API.
+	opts            CliOpts
+	auth            *ClientAuth
+	retryLimit      int
+	retryMaxBackoff time.Duration
+	retryDeadline   time.Duration
+	httpClient      *http.Client
+	apiBasePath     string
+	// take over existing objects on create, see resourceShorelineObjectAdopt()
+	adoptExisting bool
+	// shared by all API requests, see 'max_requests_per_second' and 'max_concurrent_requests'
+	limiter *RateLimiter
+	// fraction of the access token lifetime after which it's refreshed
+	tokenRefreshFraction float64
+	// guards 'auth' and 'opts.AuthChanged', as resources are operated on in parallel
+	authMu sync.Mutex
+	// API call metrics, written out at shutdown (see 'metrics_file')
+	metrics *ApiMetrics
+	// JSON log file, if any (shared by the instances logging to the same path)
+	logSink *logFileSink
+	// set once the API server rejects multi-statement requests (see runOpCommands)
+	noBatch atomic.Bool
 }
 
+// The auth config file is read through a shared viper instance.
+var authConfigMu sync.Mutex
+
 func configure(version string, p *schema.Provider) func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	return func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
-		AuthUrl = d.Get("url").(string)
+		authUrl := d.Get("url").(string)
 		token, hasToken := d.GetOk("token")
 
 		var diags diag.Diagnostics = nil
+		client := &apiClient{}
 
 		if hasToken {
-			SetAuth(&GlobalOpts, AuthUrl, token.(string))
+			SetAuth(&client.opts, authUrl, token.(string))
 		} else {
-			GlobalOpts.Url = AuthUrl
-			if !LoadAuthConfig(&GlobalOpts) {
-				return nil, diag.Errorf("Failed to load auth credentials file.\n" + GetManualAuthMessage(&GlobalOpts))
+			authConfigMu.Lock()
+			client.opts.Url = authUrl
+			loaded := LoadAuthConfig(&client.opts)
+			selected := loaded && selectAuth(&client.opts, authUrl)
+			authConfigMu.Unlock()
+			if !loaded {
+				return nil, diag.Errorf("Failed to load auth credentials file.\n" + GetManualAuthMessage(&client.opts))
 			}
-			if !selectAuth(&GlobalOpts, AuthUrl) {
-				return nil, diag.Errorf("Failed to load auth credentials for %s\n"+GetManualAuthMessage(&GlobalOpts), AuthUrl)
+			if !selected {
+				return nil, diag.Errorf("Failed to load auth credentials for %s\n"+GetManualAuthMessage(&client.opts), authUrl)
 			}
 		}
 
+		// scrubbed from the logs and diagnostics (like the access tokens exchanged for it)
+		registerSecret(client.opts.Token)
+
+		httpClient, err := NewHttpClient(HttpClientConfig{
+			CaCertFile:     d.Get("ca_cert_file").(string),
+			ClientCertFile: d.Get("client_cert_file").(string),
+			ClientKeyFile:  d.Get("client_key_file").(string),
+			ProxyUrl:       d.Get("proxy_url").(string),
+			RequestTimeout: time.Duration(d.Get("request_timeout").(int)) * time.Second,
+		})
+		if err != nil {
+			return nil, diag.FromErr(err)
+		}
+		client.httpClient = httpClient
+		client.apiBasePath = d.Get("api_base_path").(string)
+		client.tokenRefreshFraction = d.Get("token_refresh_fraction").(float64)
+		client.limiter = NewRateLimiter(d.Get("max_requests_per_second").(float64), d.Get("max_concurrent_requests").(int))
+
 		retries, hasRetry := d.GetOk("retries")
 		if hasRetry {
-			RetryLimit = retries.(int)
+			client.retryLimit = retries.(int)
 		} else {
-			RetryLimit = 0
+			client.retryLimit = 0
+		}
+		client.retryMaxBackoff = time.Duration(d.Get("retry_max_backoff").(int)) * time.Second
+		client.retryDeadline = time.Duration(d.Get("retry_deadline").(int)) * time.Second
+
+		client.adoptExisting = d.Get("adopt_existing").(bool)
+
+		logFile := d.Get("log_file").(string)
+		logLevelName := d.Get("log_level").(string)
+		if debug, _ := d.Get("debug").(bool); debug {
+			if logFile == "" {
+				logFile = defaultLogFile()
+			}
+			if logLevelName == "" {
+				logLevelName = logLevelDebug.String()
+			}
 		}
+		if logFile != "" {
+			level, _ := parseLogLevel(logLevelName)
+			client.logSink = openLogFileSink(logFile, level, d.Get("log_max_size_mb").(int), d.Get("log_max_backups").(int))
+		}
+		ctx = withLogging(ctx, client, nil)
 
-		debugLog, hasDebugLog := d.GetOk("debug")
-		if hasDebugLog {
-			DoDebugLog = debugLog.(bool)
+		if metricsFile := d.Get("metrics_file").(string); metricsFile != "" {
+			client.metrics = metricsForFile(metricsFile)
 		}
 
 		minVer, hasMinVer := d.GetOk("min_version")
 		if hasMinVer {
 			var diags diag.Diagnostics
-			_, version, major, minor, patch, err := GetBackendVersionInfo()
+			_, version, major, minor, patch, err := GetBackendVersionInfo(ctx, client)
 			if err != nil {
 				diags = diag.Errorf("Failed to read backend_version: %s", (*err).Error())
 				return nil, diags
@@ -628,34 +823,45 @@ func configure(version string, p *schema.Provider) func(ctx context.Context, d *
 			}
 		}
 
-		return &apiClient{}, diags
+		return client, diags
 	}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 ////////////////////////////////////////////////////////////////////////////////
 
-func ResourceShorelineObject(configJsStr string, key string) *schema.Resource {
-	params := map[string]*schema.Schema{}
-
-	objects := map[string]interface{}{}
+// parseObjectConfig returns the (parsed) config of an object type, and its (public) attributes.
+func parseObjectConfig(configJsStr string, key string) (objects map[string]interface{}, object interface{}, attributes map[string]interface{}) {
+	objects = map[string]interface{}{}
 	// Parsing/Unmarshalling JSON encoding/json
 	err := json.Unmarshal([]byte(configJsStr), &objects)
 	if err != nil {
 		WriteMsg("WARNING: Failed to parse JSON config from resourceShorelineObject().\n")
-		return nil
+		return nil, nil, nil
 	}
-	object := GetNestedValueOrDefault(objects, ToKeyPath(key), nil)
+	object = GetNestedValueOrDefault(objects, ToKeyPath(key), nil)
 	if object == nil {
 		WriteMsg("WARNING: Failed to parse JSON config from resourceShorelineObject(%s).\n", key)
-		return nil
+		return nil, nil, nil
 	}
-	attributes := GetNestedValueOrDefault(object, ToKeyPath("attributes"), map[string]interface{}{}).(map[string]interface{})
+	attributes = GetNestedValueOrDefault(object, ToKeyPath("attributes"), map[string]interface{}{}).(map[string]interface{})
+	// scrubbed from the logs and diagnostics, see redactSecrets()
+	registerSensitiveAttributes(object)
 	for k, _ := range attributes {
 		if strings.HasPrefix(k, "#") {
 			delete(attributes, k)
 		}
 	}
+	return objects, object, attributes
+}
+
+func ResourceShorelineObject(configJsStr string, key string) *schema.Resource {
+	params := map[string]*schema.Schema{}
+
+	objects, object, attributes := parseObjectConfig(configJsStr, key)
+	if object == nil {
+		return nil
+	}
 	primary := "name"
 	for k, attrs := range attributes {
 		// internal objects, i.e. components of compound fields
@@ -675,6 +881,7 @@ func ResourceShorelineObject(configJsStr string, key string) *schema.Resource {
 		switch typ {
 		case "command":
 			sch.Type = schema.TypeString
+			sch.ValidateFunc = validateOpCommand
 			sch.DiffSuppressFunc = func(k, old, nu string, d *schema.ResourceData) bool {
 				// ignore whitespace changes in command strings
 				if strings.ReplaceAll(old, " ", "") == strings.ReplaceAll(nu, " ", "") {
@@ -688,7 +895,7 @@ func ResourceShorelineObject(configJsStr string, key string) *schema.Resource {
 			sch.DiffSuppressFunc = func(k, old, nu string, d *schema.ResourceData) bool {
 				oldT := timeSuffixToIntSec(old)
 				nuT := timeSuffixToIntSec(nu)
-				appendActionLog(fmt.Sprintf("time_s DiffSuppressFunc: diffing (%s)=(%d) and (%s)=(%d)", old, oldT, nu, nuT))
+				logDebug(context.Background(), logSchema, fmt.Sprintf("time_s DiffSuppressFunc: diffing (%s)=(%d) and (%s)=(%d)", old, oldT, nu, nuT))
 				if oldT == nuT {
 					return true
 				}
@@ -711,8 +918,11 @@ func ResourceShorelineObject(configJsStr string, key string) *schema.Resource {
 					if oldErr != nil || nuErr != nil {
 						return false
 					}
-					NormalizeNotebookCells(&nuArr)
-					NormalizeNotebookCells(&oldArr)
+					// DiffSuppressFunc doesn't get the provider meta (to check the backend version),
+					// but the old cells were normalized on read, so they only carry secret_aware if it's supported
+					secretAware := notebookCellsHaveField(oldArr, "secret_aware")
+					normalizeNotebookCells(&nuArr, secretAware)
+					normalizeNotebookCells(&oldArr, secretAware)
 					if reflect.DeepEqual(oldArr, nuArr) {
 						return true
 					}
@@ -801,7 +1011,7 @@ func ResourceShorelineObject(configJsStr string, key string) *schema.Resource {
 					return false
 				}
 				if strings.HasSuffix(k, ".#") {
-					appendActionLog(fmt.Sprintf("string_set DiffSuppressFunc (checking size),   oldData: '%+v'   newData: '%+v'\n", old, nu))
+					logDebug(context.Background(), logSchema, fmt.Sprintf("string_set DiffSuppressFunc (checking size),   oldData: '%+v'   newData: '%+v'", old, nu))
 					if old != nu {
 						return false
 					}
@@ -855,6 +1065,7 @@ func ResourceShorelineObject(configJsStr string, key string) *schema.Resource {
 		sch.Required = GetNestedValueOrDefault(attrMap, ToKeyPath("required"), false).(bool)
 		sch.Computed = GetNestedValueOrDefault(attrMap, ToKeyPath("computed"), false).(bool)
 		sch.ForceNew = GetNestedValueOrDefault(attrMap, ToKeyPath("forcenew"), false).(bool)
+		sch.Sensitive = GetNestedValueOrDefault(attrMap, ToKeyPath("sensitive"), false).(bool)
 		deprecated := GetNestedValueOrDefault(attrMap, ToKeyPath("deprecated"), false).(bool)
 		deprField := GetNestedValueOrDefault(attrMap, ToKeyPath("deprecated_for"), "").(string)
 		replField := GetNestedValueOrDefault(attrMap, ToKeyPath("replaces"), "").(string)
@@ -891,7 +1102,7 @@ func ResourceShorelineObject(configJsStr string, key string) *schema.Resource {
 		suppressNullDiffRegex, isStr := GetNestedValueOrDefault(attrMap, ToKeyPath("suppress_null_regex"), nil).(string)
 		if isStr {
 			sch.DiffSuppressFunc = func(k, old, nu string, d *schema.ResourceData) bool {
-				appendActionLog(fmt.Sprintf("suppressNullDiff check: '%s': '%s' -- vs -- '%s'\n", suppressNullDiffRegex, old, nu))
+				logDebug(context.Background(), logSchema, fmt.Sprintf("suppressNullDiff check: '%s': '%s' -- vs -- '%s'", suppressNullDiffRegex, old, nu))
 				if old == nu {
 					return true
 				}
@@ -1025,16 +1236,23 @@ func ResourceShorelineObject(configJsStr string, key string) *schema.Resource {
 
 	}
 
+	// not an object attribute, so it's never sent to (or read from) the backend
+	params["adopt_existing"] = &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Description: "Take over an existing " + key + " with the same name on create (applying the configured fields), instead of failing. Also enabled for all resources by the provider's `adopt_existing`.",
+	}
+
 	objDescription := CastToString(GetNestedValueOrDefault(objects, ToKeyPath("docs.objects."+key), ""))
 	objectDef, _ := object.(map[string]interface{})
 
 	return &schema.Resource{
 		Description: "Shoreline " + key + ". " + objDescription,
 
-		CreateContext: resourceShorelineObjectCreate(key, primary, attributes, objectDef),
-		ReadContext:   resourceShorelineObjectRead(key, attributes, objectDef),
-		UpdateContext: resourceShorelineObjectUpdate(key, attributes, objectDef),
-		DeleteContext: resourceShorelineObjectDelete(key, objectDef),
+		CreateContext: withRedaction(params, resourceShorelineObjectCreate(key, primary, attributes, objectDef)),
+		ReadContext:   withRedaction(params, resourceShorelineObjectRead(key, attributes, objectDef)),
+		UpdateContext: withRedaction(params, resourceShorelineObjectUpdate(key, attributes, objectDef)),
+		DeleteContext: withRedaction(params, resourceShorelineObjectDelete(key, objectDef)),
 		Importer:      &schema.ResourceImporter{State: schema.ImportStatePassthrough},
 
 		Schema: params,
@@ -1103,7 +1321,7 @@ func NormalizeNotebookJson(object map[string]interface{}, attributes map[string]
 	for k, v := range object {
 		arr, isArray := v.([]interface{})
 		if toRemove[CastToString(k)] {
-			appendActionLog(fmt.Sprintf("NormalizeNotebookJson() toRemove: '%+v'\n", k))
+			logDebug(context.Background(), logSchema, fmt.Sprintf("NormalizeNotebookJson() toRemove: '%+v'", k))
 			delete(object, k)
 		} else if isArray {
 			if k == "params" {
@@ -1116,7 +1334,7 @@ func NormalizeNotebookJson(object map[string]interface{}, attributes map[string]
 
 			// remove empty lists (e.g. external_params)
 			if len(arr) == 0 {
-				appendActionLog(fmt.Sprintf("NormalizeNotebookJson() removing empty array: '%+v'\n", k))
+				logDebug(context.Background(), logSchema, fmt.Sprintf("NormalizeNotebookJson() removing empty array: '%+v'", k))
 				delete(object, k)
 			} else {
 				// NOTE: In future, may need to sort nested non-ordinal lists (ala top-level allowed_entities).
@@ -1158,7 +1376,24 @@ func NormalizeNotebookJson(object map[string]interface{}, attributes map[string]
 
 }
 
-func NormalizeNotebookCells(cells *[]interface{}) {
+func NormalizeNotebookCells(ctx context.Context, client *apiClient, cells *[]interface{}) {
+	backendVersion := GetBackendVersionInfoStruct(ctx, client)
+	// set secret_aware only if backend version >= 28.1 && backend_version != 28.3
+	normalizeNotebookCells(cells, IsSecretAwareSupported(backendVersion))
+}
+
+func notebookCellsHaveField(cells []interface{}, field string) bool {
+	for _, v := range cells {
+		if vmap, isMap := v.(map[string]interface{}); isMap {
+			if _, found := vmap[field]; found {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func normalizeNotebookCells(cells *[]interface{}, secretAware bool) {
 	omitList := []interface{}{"id", "dynamic_cell_fields", "dynamic_fields"}
 	OmitJsonArrayFields(cells, omitList)
 
@@ -1185,10 +1420,8 @@ func NormalizeNotebookCells(cells *[]interface{}) {
 			vmap["name"] = "unnamed"
 		}
 
-		backendVersion := GetBackendVersionInfoStruct()
-		if IsSecretAwareSupported(backendVersion) {
+		if secretAware {
 			secret_aware := GetNestedValueOrDefault(vmap, ToKeyPath("secret_aware"), nil)
-			// set secret_aware only if backend version >= 28.1 && backend_version != 28.3
 			if secret_aware == nil {
 				vmap["secret_aware"] = false
 			}
@@ -1223,14 +1456,7 @@ func SortListByStrVal(val []interface{}) []interface{} {
 	sortedCopy := make([]interface{}, len(val), len(val))
 	copy(sortedCopy, val)
 	sort.Slice(sortedCopy, func(i, j int) bool {
-		s1, s2 := "\"\"", "\"\""
-		if sortedCopy[i] != nil {
-			s1 = fmt.Sprintf("\"%s\"", EscapeString(sortedCopy[i]))
-		}
-		if sortedCopy[j] != nil {
-			s2 = fmt.Sprintf("\"%s\"", EscapeString(sortedCopy[j]))
-		}
-		return s1 < s2
+		return opString(sortedCopy[i]) < opString(sortedCopy[j])
 	})
 	return sortedCopy
 }
@@ -1293,141 +1519,115 @@ func attrValueString(typ string, key string, val interface{}, attrs map[string]i
 	optional := GetNestedValueOrDefault(attrs, ToKeyPath(key+".optional"), false).(bool)
 	switch attrTyp {
 	case "command":
+		// op expressions (validated at plan time, see validateOpCommand())
 		if optional && val == "" {
-			strVal = "\"\""
+			strVal = opString("")
 		} else {
 			strVal = fmt.Sprintf("%s", val)
 		}
 	case "time_s":
-		strVal = fmt.Sprintf("%s", val)
+		strVal = opDuration(val)
 	case "b64json":
-		jsStr, isStr := val.(string)
-		if !isStr {
-			jsStr = ""
-		}
-		strVal = fmt.Sprintf("\"%s\"", base64.StdEncoding.EncodeToString([]byte(jsStr)))
+		strVal = opBase64(val)
 	case "string":
-		strVal = fmt.Sprintf("\"%s\"", EscapeString(val))
-	case "string[]":
-		valArr, isArr := val.([]interface{})
-		listStr := ""
-		sep := ""
-		if isArr {
-			for _, v := range valArr {
-				if v == nil {
-					listStr = listStr + fmt.Sprintf("%s\"\"", sep)
-				} else {
-					listStr = listStr + fmt.Sprintf("%s\"%s\"", sep, EscapeString(v))
-				}
-				sep = ", "
-			}
-		}
-		return "[ " + listStr + " ]"
-	case "string_set":
-		valArr, isArr := val.([]interface{})
-		listStr := ""
-		sep := ""
-		if isArr {
-			for _, v := range valArr {
-				if v == nil {
-					listStr = listStr + fmt.Sprintf("%s\"\"", sep)
-				} else {
-					listStr = listStr + fmt.Sprintf("%s\"%s\"", sep, EscapeString(v))
-				}
-				sep = ", "
-			}
-		}
-		return "[ " + listStr + " ]"
+		strVal = opString(val)
+	case "string[]", "string_set":
+		strVal = opStringList(val)
 	case "bool":
-		if ForceToBool(val) {
-			strVal = fmt.Sprintf("true")
-		} else {
-			strVal = fmt.Sprintf("false")
-		}
+		strVal = opBool(val)
 	case "intbool": // special handling to/from backend ("1"/"0")
-		strVal = fmt.Sprintf("%d", ConvertBoolInt(val))
+		strVal = opInt(ConvertBoolInt(val))
 	case "float":
-		strVal = fmt.Sprintf("%f", val)
-	case "int":
-		strVal = fmt.Sprintf("%d", val)
-	case "unsigned":
-		strVal = fmt.Sprintf("%d", val)
-	case "label":
-		strVal = fmt.Sprintf("\"%s\"", val)
-	case "resource":
-		strVal = fmt.Sprintf("\"%s\"", val)
+		strVal = opFloat(val)
+	case "int", "unsigned":
+		strVal = opInt(val)
+	case "label", "resource":
+		strVal = opString(val)
 	}
 	return strVal
 }
 
-func setFieldViaOp(typ string, attrs map[string]interface{}, name string, key string, val interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
-
+// setFieldStatement returns the op statement that sets an object field (or "" if the field isn't set via op).
+func setFieldStatement(ctx context.Context, typ string, attrs map[string]interface{}, name string, key string, val interface{}) string {
 	valStr := attrValueString(typ, key, val, attrs)
-	op := fmt.Sprintf("%s.%s = %s", name, key, valStr)
+	op := opSetFieldStatement(name, key, valStr)
 
 	if typ == "dashboard" {
 		isPrimary := GetNestedValueOrDefault(attrs, ToKeyPath(key+".primary"), false).(bool)
 		if isPrimary {
-			appendActionLog(fmt.Sprintf("Skipping setting %s field %s...\n", typ, key))
-			return nil
+			logDebug(ctx, logCrud, fmt.Sprintf("Skipping setting %s field %s...", typ, key))
+			return ""
 		} else {
 			if key == "groups" || key == "values" {
-				op = fmt.Sprintf("%s.%s = %s", name, key, val)
+				// JSON lists, set as-is (rather than base64 encoded)
+				op = opSetFieldStatement(name, key, opJson(val))
 			}
 		}
 	}
 
-	appendActionLog(fmt.Sprintf("Setting %s field: '%s'.'%s' :: %+v\n", typ, name, key, val))
-
 	// TODO Let alias to be a list of fallbacks for versioning,
 	//   or have alternate ObjectConfigJsonStr based on backend version,
 	//   or let backend return ObjectConfigJsonStr to use.
 	alias, isStr := GetNestedValueOrDefault(attrs, ToKeyPath(key+".alias_out"), nil).(string)
 	if isStr {
 		//appendActionLog(fmt.Sprintf("Setting %s aliased field: '%s'->'%s'.'%s' :: %+v\n", typ, name, alias, key, val))
-		op = fmt.Sprintf("%s.%s = %s", name, alias, valStr)
+		op = opSetFieldStatement(name, alias, valStr)
 	}
+	return op
+}
 
-	appendActionLog(fmt.Sprintf("Setting with op statement... '%s'\n", op))
-	result, err := runOpCommand(op, true)
-	if err != nil {
-		diags = diag.Errorf("Failed to set %s %s.%s: %s", typ, name, key, err.Error())
-		appendActionLog(fmt.Sprintf("Failed to set %s %s.%s: %s\nval: (( %+v ))\nop-statement: %s\n", typ, name, key, val, err.Error(), op))
-		return diags
+// setFieldViaOp queues the op statement for a field on the object's batch (see opBatch.flush()).
+// 'oldVal' is the field's previous value, to roll it back to if the batch fails (nil if unknown, e.g. on create).
+func setFieldViaOp(ctx context.Context, batch *opBatch, typ string, attrs map[string]interface{}, name string, attr string, key string, val interface{}, fallbackKey string, oldVal interface{}) {
+	if GetNestedValueOrDefault(attrs, ToKeyPath(key+".sensitive"), false).(bool) {
+		registerSecret(CastToString(val))
+		if oldVal != nil {
+			registerSecret(CastToString(oldVal))
+		}
 	}
-	err = CheckUpdateResult(result)
-	if err != nil {
-		diags = diag.Errorf("Failed to update %s %s.%s: %s", typ, name, key, err.Error())
-		return diags
+	logDebug(ctx, logCrud, fmt.Sprintf("Setting %s field: '%s'.'%s' :: %+v", typ, name, key, logAttrValue(attrs, key, val)))
+	op := setFieldStatement(ctx, typ, attrs, name, key, val)
+	if op == "" {
+		return
 	}
-	return nil
+	fallback, undo, fallbackUndo := "", "", ""
+	if fallbackKey != "" {
+		fallback = setFieldStatement(ctx, typ, attrs, name, fallbackKey, val)
+	}
+	if oldVal != nil {
+		undo = setFieldStatement(ctx, typ, attrs, name, key, oldVal)
+		if fallbackKey != "" {
+			fallbackUndo = setFieldStatement(ctx, typ, attrs, name, fallbackKey, oldVal)
+		}
+	}
+	logDebug(ctx, logCrud, fmt.Sprintf("Setting with op statement... '%s'", op))
+	batch.add(batchOp{statement: op, attr: attr, field: key, fallback: fallback, undo: undo, fallbackUndo: fallbackUndo})
 }
 
-func getRemoteFileAttr(name string, key string) string {
-	pathAttrCmd := fmt.Sprintf("%s.%s", name, key)
-	pathJson, err := runOpCommandToJson(pathAttrCmd)
+func getRemoteFileAttr(ctx context.Context, client *apiClient, name string, key string) string {
+	pathAttrCmd := opGetFieldStatement(name, key)
+	pathJson, err := runOpCommandToJson(ctx, client, pathAttrCmd)
 	if err != nil {
 		return ""
 	}
 	uri, isStr := GetNestedValueOrDefault(pathJson, ToKeyPath("get_file_attribute"), nil).(string)
-	// "get file attribute failed: field does not exist"
-	if !isStr || strings.Contains(uri, "failed:") || strings.Contains(uri, "field does not exist") {
+	if !isStr || attributeValueError(name, key, uri) != nil {
 		return ""
 	}
 	return uri
 }
 
-func setFieldInner(key string, val interface{}, name string, typ string, attrs map[string]interface{}, ctx context.Context, d *schema.ResourceData, meta interface{}, doDiff bool, isCreate bool, forcedChangeKeys map[string]bool, forcedChangeVals map[string]interface{}) (bool, diag.Diagnostics) {
+func setFieldInner(key string, val interface{}, name string, typ string, attrs map[string]interface{}, ctx context.Context, d *schema.ResourceData, meta interface{}, batch *opBatch, doDiff bool, isCreate bool, forcedChangeKeys map[string]bool, forcedChangeVals map[string]interface{}) (bool, diag.Diagnostics) {
 	compoundRegex, isStr := GetNestedValueOrDefault(attrs, ToKeyPath(key+".compound_in"), nil).(string)
 	if isStr {
 		curMap := ExtractRegexToMap(CastToString(val), compoundRegex)
-		appendActionLog(fmt.Sprintf("CompoundSet: %s: '%s'.'%s' map(%v) from (( %v ))\n", typ, name, key, curMap, val))
+		logDebug(ctx, logCrud, fmt.Sprintf("CompoundSet: %s: '%s'.'%s' map(%v) from (( %v ))", typ, name, key, logAttrValue(attrs, key, curMap), logAttrValue(attrs, key, val)))
 
 		unchanged := map[string]bool{}
+		oldMap := map[string]interface{}{}
 		if doDiff {
 			old, _ := d.GetChange(key)
-			oldMap := ExtractRegexToMap(CastToString(old), compoundRegex)
+			oldMap = ExtractRegexToMap(CastToString(old), compoundRegex)
 			for k, v := range oldMap {
 				nu, exists := curMap[k]
 				if exists && v == nu {
@@ -1441,29 +1641,21 @@ func setFieldInner(key string, val interface{}, name string, typ string, attrs m
 			if skip {
 				continue
 			}
-			result := setFieldViaOp(typ, attrs, name, k, v)
-			if result != nil {
-				return false, result
-			}
+			setFieldViaOp(ctx, batch, typ, attrs, name, key, k, v, "", oldMap[k])
 		}
 		return true, nil
 	}
 
-	result := diag.Diagnostics(nil)
 	if forcedChangeKeys[key] {
-		result = setFieldViaOp(typ, attrs, name, key, forcedChangeVals[key])
+		setFieldViaOp(ctx, batch, typ, attrs, name, key, key, forcedChangeVals[key], "", nil)
 	} else {
-		result = setFieldViaOp(typ, attrs, name, key, val)
-
 		// on failure, if field is deprecated and renamed, try the new name
 		deprecatedFor := GetNestedValueOrDefault(attrs, ToKeyPath(key+".deprecated_for"), "").(string)
-		if deprecatedFor != "" && result != nil {
-			appendActionLog(fmt.Sprintf("Set deprecated/renamed field : %s: '%s'.'%s'->'%s'  val:'%v'\n", typ, name, key, deprecatedFor, val))
-			result = setFieldViaOp(typ, attrs, name, deprecatedFor, val)
+		var oldVal interface{}
+		if doDiff && !isCreate {
+			oldVal, _ = d.GetChange(key)
 		}
-	}
-	if result != nil {
-		return false, result
+		setFieldViaOp(ctx, batch, typ, attrs, name, key, key, val, deprecatedFor, oldVal)
 	}
 	return true, nil
 }
@@ -1479,18 +1671,18 @@ func maybeArrayLen(val interface{}) int {
 func shouldSkipSetField(key string, val interface{}, name string, typ string, attrs map[string]interface{}, ctx context.Context, d *schema.ResourceData, meta interface{}, doDiff bool, isCreate bool, forcedChangeKeys map[string]bool, forcedChangeVals map[string]interface{}, backendVersion VersionRecord) (bool, diag.Diagnostics) {
 	skip := GetNestedValueOrDefault(attrs, ToKeyPath(key+".skip"), false).(bool)
 	if skip {
-		appendActionLog(fmt.Sprintf("Set (skipping explicit): %s: '%s'.'%s'\n", typ, name, key))
+		logDebug(ctx, logCrud, fmt.Sprintf("Set (skipping explicit): %s: '%s'.'%s'", typ, name, key))
 		return true, nil
 	}
 
 	internal := GetNestedValueOrDefault(attrs, ToKeyPath(key+".internal"), false).(bool)
 	if internal {
-		appendActionLog(fmt.Sprintf("Set (skipping internal): %s: '%s'.'%s'\n", typ, name, key))
+		logDebug(ctx, logCrud, fmt.Sprintf("Set (skipping internal): %s: '%s'.'%s'", typ, name, key))
 		return true, nil
 	}
 	proxy := GetNestedValueOrDefault(attrs, ToKeyPath(key+".proxy"), "").(string)
 	if proxy != "" {
-		appendActionLog(fmt.Sprintf("Set (skipping proxy): %s: '%s'.'%s'\n", typ, name, key))
+		logDebug(ctx, logCrud, fmt.Sprintf("Set (skipping proxy): %s: '%s'.'%s'", typ, name, key))
 		return true, nil
 	}
 
@@ -1507,7 +1699,7 @@ func shouldSkipSetField(key string, val interface{}, name string, typ string, at
 			if defowlt == nil {
 				defowlt = AttrValueDefault(attrTyp)
 			}
-			appendActionLog(fmt.Sprintf("Set (checking min_ver): %s: '%s'.'%s' exists(%v) val(%v : %T) default(%v : %T) ver(%v) backend_ver(%v)\n", typ, name, key, exists, val, val, defowlt, defowlt, min_ver, backendVersion.Version))
+			logDebug(ctx, logCrud, fmt.Sprintf("Set (checking min_ver): %s: '%s'.'%s' exists(%v) val(%v : %T) default(%v : %T) ver(%v) backend_ver(%v)", typ, name, key, exists, logAttrValue(attrs, key, val), val, defowlt, defowlt, min_ver, backendVersion.Version))
 			// NOTE: because of the bug in GetOk(), we can't know for sure if the value is set in the TF HCL
 			//   e.g. value=<unset>, default=true -> exists==true
 			//        value=false,   default=true -> exists==false
@@ -1519,14 +1711,14 @@ func shouldSkipSetField(key string, val interface{}, name string, typ string, at
 			if (attrTyp == "string_set" || attrTyp == "string[]") && maybeArrayLen(val) == 0 && maybeArrayLen(defowlt) == 0 {
 				isEmptyArray = true
 			}
-			appendActionLog(fmt.Sprintf("Set (checking min_ver, isEmptyArray: %v): %s: '%s'.'%s' exists(%v) val(%+v -- %T) default(%+v -- %T) ver(%v) backend_ver(%v)\n", isEmptyArray, typ, name, key, exists, val, val, defowlt, defowlt, min_ver, backendVersion.Version))
+			logDebug(ctx, logCrud, fmt.Sprintf("Set (checking min_ver, isEmptyArray: %v): %s: '%s'.'%s' exists(%v) val(%+v -- %T) default(%+v -- %T) ver(%v) backend_ver(%v)", isEmptyArray, typ, name, key, exists, logAttrValue(attrs, key, val), val, defowlt, defowlt, min_ver, backendVersion.Version))
 			if val != nil && val != defowlt && !isEmptyArray {
 				// XXX error or warning? (Hashi plugin SDK v2 doesn't seem to support warnings)
 				//diags.AddWarning("Below minimum version.", fmt.Sprintf("Field %s.%s requires minimum version %s, skipping...", name, key, min_ver))
 				diags := diag.Errorf("Field '%s.%s' requires minimum version '%s', but backend is '%s'", name, key, min_ver, backendVersion.Version)
 				return false, diags
 			}
-			appendActionLog(fmt.Sprintf("Set (skipping): %s: '%s'.'%s' exists(%v) val(%v) default(%v) ver(%v) backend_ver(%v)\n", typ, name, key, exists, val, defowlt, min_ver, backendVersion.Version))
+			logDebug(ctx, logCrud, fmt.Sprintf("Set (skipping): %s: '%s'.'%s' exists(%v) val(%v) default(%v) ver(%v) backend_ver(%v)", typ, name, key, exists, logAttrValue(attrs, key, val), defowlt, min_ver, backendVersion.Version))
 			return true, nil
 		}
 	}
@@ -1537,7 +1729,7 @@ func shouldSkipSetField(key string, val interface{}, name string, typ string, at
 		gtlteq, valid := CompareVersionRecords(backendVersion, maxVersion)
 
 		if valid && gtlteq >= 0 {
-			appendActionLog(fmt.Sprintf("Set (skipping): %s: '%s'.'%s' ver(%v) backend_ver(%v)\n", typ, name, key, max_ver, backendVersion.Version))
+			logDebug(ctx, logCrud, fmt.Sprintf("Set (skipping): %s: '%s'.'%s' ver(%v) backend_ver(%v)", typ, name, key, max_ver, backendVersion.Version))
 			return true, nil
 		}
 	}
@@ -1545,42 +1737,6 @@ func shouldSkipSetField(key string, val interface{}, name string, typ string, at
 	return false, nil
 }
 
-func createUpdateSystemSettingsCommand(systemSettings map[string]interface{}) string {
-	var builder strings.Builder
-	builder.WriteString("update_configuration(")
-
-	first := true
-	for key, value := range systemSettings {
-		if !first {
-			builder.WriteString(", ")
-		}
-		first = false
-
-		builder.WriteString(key)
-		builder.WriteString("=")
-
-		switch v := value.(type) {
-		case string:
-			builder.WriteString(fmt.Sprintf("\"%s\"", v))
-		case int:
-			builder.WriteString(strconv.Itoa(v))
-		case bool:
-			builder.WriteString(strconv.FormatBool(v))
-		case []interface{}:
-			encodedList, _ := json.Marshal(v)
-
-			builder.WriteString(string(encodedList))
-
-		default:
-			builder.WriteString(fmt.Sprintf("\"%v\"", v))
-		}
-	}
-
-	builder.WriteString(")")
-
-	return builder.String()
-}
-
 func updateSystemSettings(attrs map[string]interface{}, objectDef map[string]interface{}, ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	settingsToUpdate := make(map[string]interface{})
 
@@ -1664,12 +1820,12 @@ func updateSystemSettings(attrs map[string]interface{}, objectDef map[string]int
 		}
 	}
 
-	op := createUpdateSystemSettingsCommand(settingsToUpdate)
+	op := opUpdateConfigurationStatement(settingsToUpdate)
 
-	appendActionLog(fmt.Sprintf("Updating system settings statement... '%s'\n", op))
-	result, err := runOpCommand(op, true)
+	logDebug(ctx, logCrud, fmt.Sprintf("Updating system settings statement... '%s'", op))
+	result, err := runOpCommand(ctx, meta.(*apiClient), op, true)
 	if err != nil {
-		appendActionLog(fmt.Sprintf("Failed to update system settings: %s\n", err.Error()))
+		logDebug(ctx, logCrud, fmt.Sprintf("Failed to update system settings: %s", err.Error()))
 
 		return diag.Errorf("Failed to update system settings: %s\n", err.Error())
 	}
@@ -1685,12 +1841,12 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 	var diags diag.Diagnostics
 	name := d.Get("name").(string)
 	// valid-variable-name check (and non-null)
-	appendActionLog(fmt.Sprintf("RESOURCE TYPE IS: %s (resourceShorelineObjectSetFields)\n", typ))
+	logDebug(ctx, logCrud, fmt.Sprintf("RESOURCE TYPE IS: %s (resourceShorelineObjectSetFields)", typ))
 
 	specialSkipFields := map[string]bool{}
 	if typ == "notebook" || typ == "runbook" {
 		if notebookIsInline(typ, attrs, objectDef, ctx, d, meta) {
-			appendActionLog(fmt.Sprintf("Setting %s:%v :: IS_INLINE\n", typ, name))
+			logDebug(ctx, logCrud, fmt.Sprintf("Setting %s:%v :: IS_INLINE", typ, name))
 			// TODO move this to the json-config
 			//specialSkipFields["cells"] = true
 			//specialSkipFields["params"] = true
@@ -1699,7 +1855,7 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 
 			specialSkipFields["data"] = true
 		} else {
-			appendActionLog(fmt.Sprintf("Setting %s:%v :: NOT_INLINE\n", typ, name))
+			logDebug(ctx, logCrud, fmt.Sprintf("Setting %s:%v :: NOT_INLINE", typ, name))
 			//specialSkipFields["data"] = true
 
 			specialSkipFields["cells"] = true
@@ -1709,6 +1865,14 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 		}
 	}
 
+	// all the field updates go out in a single request, after the loop below
+	batch := newOpBatch(typ, name)
+	batch.isUpdate = doDiff && !isCreate
+	if _, hasEnabled := attrs["enabled"]; hasEnabled && batch.isUpdate {
+		wasEnabled, _ := d.GetChange("enabled")
+		batch.reenable, _ = CastToBoolMaybe(wasEnabled)
+	}
+
 	needVersion := false
 	writeEnable := false
 	enableVal := false
@@ -1741,7 +1905,7 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 	var backendVersion VersionRecord
 	backendVersion.Valid = false
 	if needVersion {
-		backendVersion = GetBackendVersionInfoStruct()
+		backendVersion = GetBackendVersionInfoStruct(ctx, meta.(*apiClient))
 	}
 
 	if typ == "file" {
@@ -1759,7 +1923,7 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 
 		// Chek if the source is remote
 		if strings.HasPrefix(infile, "http:") || strings.HasPrefix(infile, "https://") {
-			tmpFileName, err := DownloadFileHttpsToTemp(infile, "")
+			tmpFileName, err := DownloadFileHttpsToTemp(meta.(*apiClient).httpClient, infile, "")
 			if err != nil {
 				diags = diag.Errorf("Failed to read remote file object %s: %s", infile, err)
 				return diags
@@ -1769,7 +1933,7 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 		}
 
 		// Check if the file destination is inline or to a remote store (e.g. S3 / GCS / etc.)
-		uri := getRemoteFileAttr(name, "uri")
+		uri := getRemoteFileAttr(ctx, meta.(*apiClient), name, "uri")
 		fileIsRemote := true
 		if uri == "" {
 			fileIsRemote = false
@@ -1797,7 +1961,7 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 			base64Data = CompressedBase64(content)
 		}
 
-		appendActionLog(fmt.Sprintf("file_length is %d (%v)\n", int(fileSize), fileSize))
+		logDebug(ctx, logCrud, fmt.Sprintf("file_length is %d (%v)", int(fileSize), fileSize))
 		if forcedChangeKeys["file_data"] {
 			forcedChangeVals["file_length"] = int(fileSize)
 			forcedChangeVals["checksum"] = md5sum
@@ -1807,19 +1971,19 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 		d.Set("checksum", md5sum)
 		d.Set("file_data", base64Data)
 		if fileIsRemote {
-			presignedUrl := getRemoteFileAttr(name, "presigned_put")
+			presignedUrl := getRemoteFileAttr(ctx, meta.(*apiClient), name, "presigned_put")
 			if presignedUrl == "" {
 				diags = diag.Errorf("Failed to get presigned url for file object %s", name)
 				return diags
 			}
 			var err error
 			if contentParamExists {
-				err = UploadFileHttpsFromString(string(content), presignedUrl, "")
+				err = UploadFileHttpsFromString(meta.(*apiClient).httpClient, string(content), presignedUrl, "")
 				if err == nil {
 					d.Set("inline_data", string(content))
 				}
 			} else {
-				err = UploadFileHttps(infileLocal, presignedUrl, "")
+				err = UploadFileHttps(meta.(*apiClient).httpClient, infileLocal, presignedUrl, "")
 			}
 			if err != nil {
 				diags = diag.Errorf("Failed to upload to presigned url for file object %s -- %s", name, err.Error())
@@ -1840,9 +2004,8 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 		//if exists || d.HasChange(key) {
 		if notebookIsInline(typ, attrs, objectDef, ctx, d, meta) {
 			if exists {
-				runbookData, err = buildRunbookDataObject(d, CastToObject(cells))
-				appendActionLog(fmt.Sprintf("buildRunbookDataObject input: [[[ %v ]]]\n", runbookData))
-				appendActionLog(fmt.Sprintf("buildRunbookDataObject output: [[[ %v ]]]\n", runbookData))
+				runbookData, err = buildRunbookDataObject(ctx, meta.(*apiClient), d, CastToObject(cells))
+				logDebug(ctx, logCrud, fmt.Sprintf("buildRunbookDataObject: %d cells", len(CastToArray(CastToObject(cells)))))
 				if err != nil {
 					diags = diag.Errorf("Failed to build runbook data object: %s", err)
 					return diags
@@ -1857,7 +2020,7 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 			}
 		}
 
-		changed, diags := setFieldInner("data", runbookData, name, typ, attrs, ctx, d, meta, doDiff, isCreate, forcedChangeKeys, forcedChangeVals)
+		changed, diags := setFieldInner("data", runbookData, name, typ, attrs, ctx, d, meta, batch, doDiff, isCreate, forcedChangeKeys, forcedChangeVals)
 		if diags != nil {
 			return diags
 		}
@@ -1907,7 +2070,7 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 		if skipKeys[key] != true {
 			orderedAttrs = append(orderedAttrs, key)
 		} else {
-			appendActionLog(fmt.Sprintf("Notebook skipping key: %s\n", key))
+			logDebug(ctx, logCrud, fmt.Sprintf("Notebook skipping key: %s", key))
 		}
 	}
 
@@ -1927,7 +2090,7 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 	for _, key := range orderedAttrs {
 		if specialSkipFields[key] {
 			val := "nil"
-			appendActionLog(fmt.Sprintf("Skipping set (special-skip) %s field: '%s'.'%s' :: %+v\n", typ, name, key, val))
+			logDebug(ctx, logCrud, fmt.Sprintf("Skipping set (special-skip) %s field: '%s'.'%s' :: %+v", typ, name, key, logAttrValue(attrs, key, val)))
 			continue
 		}
 		// NOTE: GetOk() has bugs: it checks vs 0/false/"" instead of presence of an explicit value, or even equality to the default
@@ -1951,11 +2114,11 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 		isPrimary := GetNestedValueOrDefault(attrs, ToKeyPath(key+".primary"), false).(bool)
 		if isCreate && isPrimary && typ == "bot" {
 			if botEnvDefined {
-				appendActionLog(fmt.Sprintf("Bot skipping post-ctor set: %s: '%s'.'%s' HasChange(%v)\n", typ, name, key, d.HasChange(key)))
+				logDebug(ctx, logCrud, fmt.Sprintf("Bot skipping post-ctor set: %s: '%s'.'%s' HasChange(%v)", typ, name, key, d.HasChange(key)))
 				// primary value is set on creation, and redundant set currently triggers an issue with bots
 				continue
 			} else {
-				appendActionLog(fmt.Sprintf("Bot running post-ctor set: %s: '%s'.'%s'  HasChange(%v)\n", typ, name, key, d.HasChange(key)))
+				logDebug(ctx, logCrud, fmt.Sprintf("Bot running post-ctor set: %s: '%s'.'%s'  HasChange(%v)", typ, name, key, d.HasChange(key)))
 				forceSet = true
 			}
 		}
@@ -1968,7 +2131,7 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 		defowlt := GetNestedValueOrDefault(attrs, ToKeyPath(key+".default"), nil)
 
 		if !exists && !d.HasChange(key) && !forceSet && !forcedChangeKeys[key] && !forcedUpdate[key] {
-			appendActionLog(fmt.Sprintf("FieldDoesNotExist: %s: '%s'.'%s' val(%v) HasChange(%v), forceSet(%v) isCreate(%v) default(%v)\n", typ, name, key, val, d.HasChange(key), forceSet, isCreate, defowlt))
+			logDebug(ctx, logCrud, fmt.Sprintf("FieldDoesNotExist: %s: '%s'.'%s' val(%v) HasChange(%v), forceSet(%v) isCreate(%v) default(%v)", typ, name, key, logAttrValue(attrs, key, val), d.HasChange(key), forceSet, isCreate, defowlt))
 			// Handle GetOk() bug...
 			if isCreate {
 				if defowlt == nil || val == defowlt {
@@ -1986,7 +2149,7 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 			if d.HasChange(key) || !doDiff {
 				writeEnable = true
 			}
-			appendActionLog(fmt.Sprintf("CheckEnableState: %s: '%s' write(%v) val(%v) change(%v) hasChange:(%v) doDiff(%v)\n", typ, name, writeEnable, enableVal, anyChange, d.HasChange(key), doDiff))
+			logDebug(ctx, logCrud, fmt.Sprintf("CheckEnableState: %s: '%s' write(%v) val(%v) change(%v) hasChange:(%v) doDiff(%v)", typ, name, writeEnable, enableVal, anyChange, d.HasChange(key), doDiff))
 			continue
 		}
 		if doDiff && !d.HasChange(key) && !forcedChangeKeys[key] && !forcedUpdate[key] {
@@ -2003,7 +2166,7 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 			attrsOrAlias = aliasMap
 		}
 
-		changed, diags := setFieldInner(key, val, name, typ, attrsOrAlias, ctx, d, meta, doDiff, isCreate, forcedChangeKeys, forcedChangeVals)
+		changed, diags := setFieldInner(key, val, name, typ, attrsOrAlias, ctx, d, meta, batch, doDiff, isCreate, forcedChangeKeys, forcedChangeVals)
 		if diags != nil {
 			return diags
 		}
@@ -2012,37 +2175,28 @@ func resourceShorelineObjectSetFields(typ string, attrs map[string]interface{},
 		}
 	}
 
-	appendActionLog(fmt.Sprintf("EnableState: %s: '%s' write(%v) val(%v) anyChange(%v)\n", typ, name, writeEnable, enableVal, anyChange))
+	logDebug(ctx, logCrud, fmt.Sprintf("EnableState: %s: '%s' write(%v) val(%v) anyChange(%v)", typ, name, writeEnable, enableVal, anyChange))
 	// Enabled is automatically toggled to "false" by oplang on any other attribute change.
-	// So, it requires special handling.
+	// So, it requires special handling (and has to come after the field updates).
 	if writeEnable || (enableVal && anyChange) {
 		act := "enable"
 		if !enableVal {
 			act = "disable"
 		}
-		op := fmt.Sprintf("%s %s", act, name)
-		appendActionLog(fmt.Sprintf("EnableState: %s: '%s' Op:'%s'\n", typ, name, op))
-		result, err := runOpCommand(op, true)
-		if err != nil {
-			diags = diag.Errorf("Failed to %s (1) %s: %s", act, typ, err.Error())
-			return diags
-		}
-		err = CheckUpdateResult(result)
-		if err != nil {
-			diags = diag.Errorf("Failed to %s (2) %s: %s", act, typ, err.Error())
-			return diags
-		}
+		op := opEnableStatement(enableVal, name)
+		logDebug(ctx, logCrud, fmt.Sprintf("EnableState: %s: '%s' Op:'%s'", typ, name, op))
+		batch.add(batchOp{statement: op, attr: "enabled", field: "enabled", action: act})
 	}
-	return nil
+	return batch.flush(ctx, meta.(*apiClient))
 }
 
 func notebookIsInline(typ string, attrs map[string]interface{}, objectDef map[string]interface{}, ctx context.Context, d *schema.ResourceData, meta interface{}) bool {
 	key := "cells"
 	cells, cellsExists := d.GetOk(key)
-	appendActionLog(fmt.Sprintf("Runbook 'cells' value... exists:%v, hasChange():%v, value(%T): %v\n", cellsExists, d.HasChange(key), cells, cells))
+	logDebug(ctx, logCrud, fmt.Sprintf("Runbook 'cells' value... exists:%v, hasChange():%v, value(%T): %d bytes", cellsExists, d.HasChange(key), cells, len(CastToString(cells))))
 	key = "data"
 	data, dataExists := d.GetOk(key)
-	appendActionLog(fmt.Sprintf("Runbook 'data' value... exists:%v, hasChange():%v, value(%T): %v\n", dataExists, d.HasChange(key), data, data))
+	logDebug(ctx, logCrud, fmt.Sprintf("Runbook 'data' value... exists:%v, hasChange():%v, value(%T): %d bytes", dataExists, d.HasChange(key), data, len(CastToString(data))))
 
 	// NOTE: Terraform reports !exists when a value is explicitly supplied, but matches the 'default'
 	// HasChange() has some similar deficiencies (especially after initial apply)...
@@ -2054,23 +2208,24 @@ func notebookIsInline(typ string, attrs map[string]interface{}, objectDef map[st
 		//appendActionLog(fmt.Sprintf("InlineCheck: dataExists(%v) isNill(%v) isEmptyStr(%v) :: value= %+v\n", dataExists, (data == nil), (data == ""), data))
 		return false
 	}
-	appendActionLog(fmt.Sprintf("InlineCheck: DEFAULT\n"))
+	logDebug(ctx, logCrud, "InlineCheck: DEFAULT")
 	return false
 }
 
 func resourceShorelineObjectCreate(typ string, primary string, attrs map[string]interface{}, objectDef map[string]interface{}) func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 		// use the meta value to retrieve your client from the provider configure method
-		// client := meta.(*apiClient)
+		client := meta.(*apiClient)
 
 		var diags diag.Diagnostics
 		name := d.Get("name").(string)
 		primaryVal := d.Get(primary)
 		idFromAPI := name
-		appendActionLog(fmt.Sprintf("Creating %s: '%s' (%v) :: %+v\n", typ, idFromAPI, name, d))
+		ctx = withResourceLogging(ctx, client, typ, name)
+		logInfo(ctx, logCrud, fmt.Sprintf("Creating %s: '%s'", typ, idFromAPI))
 
 		singletonName, _ := GetNestedValueOrDefault(objectDef, ToKeyPath("internal.singleton"), "").(string)
-		appendActionLog(fmt.Sprintf("Creating %s: '%s' (%v) :: %+v -- singletonName: '%v'\n", typ, idFromAPI, name, d, singletonName))
+		logDebug(ctx, logCrud, fmt.Sprintf("Creating %s: '%s' -- singletonName: '%v'", typ, idFromAPI, singletonName))
 		if singletonName != "" {
 			if name != singletonName {
 				diags = diag.Errorf("Invalid name for %s singleton object '%s' vs required name '%s'", typ, name, singletonName)
@@ -2097,16 +2252,23 @@ func resourceShorelineObjectCreate(typ string, primary string, attrs map[string]
 		}
 		//appendActionLog(fmt.Sprintf("primaryValStr is ((( %+v )))\n", primaryValStr))
 		//op := fmt.Sprintf("%s %s = \"%s\"", typ, name, primaryVal)
-		op := fmt.Sprintf("%s %s = %s", typ, name, primaryValStr)
+		op := opDefineStatement(typ, name, primaryValStr)
 		//if typ == "bot" {
 		//	// special handling for BOT creation statement "bot <name>=
 		//	action := d.Get("action_statement").(string)
 		//	alarm := d.Get("alarm_statement").(string)
 		//	op = fmt.Sprintf("%s %s = if %s then %s fi", typ, name, alarm, action)
 		//}
-		result, err := runOpCommand(op, true)
+		result, err := runOpCommand(ctx, client, op, true)
 		if err != nil {
-			// TODO check if already exists
+			annotateBackendError(err, op, typ, name)
+			if errors.Is(err, ErrAlreadyExists) {
+				if client.adoptExisting || d.Get("adopt_existing").(bool) {
+					return resourceShorelineObjectAdopt(typ, attrs, objectDef, name, err)(ctx, d, meta)
+				}
+				diags = diag.Errorf("Failed to create %s '%s', it already exists (it can be imported with 'terraform import', or adopted with 'adopt_existing'): %s", typ, name, err.Error())
+				return diags
+			}
 			diags = diag.Errorf("Failed to create (1) %s: %s", typ, err.Error())
 			return diags
 		}
@@ -2130,8 +2292,43 @@ func resourceShorelineObjectCreate(typ string, primary string, attrs map[string]
 	}
 }
 
+// resourceShorelineObjectAdopt takes over an existing object on create, by applying the configured fields to it.
+// 'existsErr' is the (already exists) error from creating it.
+func resourceShorelineObjectAdopt(typ string, attrs map[string]interface{}, objectDef map[string]interface{}, name string, existsErr error) func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		client := meta.(*apiClient)
+
+		// names are shared by all object types, so make sure it's the same type
+		op := opListStatement(typ, name)
+		js, err := runOpCommandToJson(ctx, client, op)
+		if err != nil {
+			return diag.Errorf("Failed to adopt existing %s '%s': %s", typ, name, err.Error())
+		}
+		found := false
+		symbols, _ := GetNestedValueOrDefault(js, ToKeyPath("list_type.symbol"), []interface{}{}).([]interface{})
+		for _, s := range symbols {
+			if sName, _ := GetNestedValueOrDefault(s, ToKeyPath("attributes.name"), "").(string); sName == name {
+				found = true
+			}
+		}
+		if !found {
+			return diag.Errorf("Failed to adopt existing %s '%s': an object with that name exists, but it isn't of type '%s': %s", typ, name, typ, existsErr.Error())
+		}
+
+		logInfo(ctx, logCrud, fmt.Sprintf("Adopting existing %s: '%s'", typ, name))
+		diags := resourceShorelineObjectSetFields(typ, attrs, objectDef, ctx, d, meta, false, true)
+		if diags != nil {
+			// NOTE: unlike a failed create, the (pre-existing) object isn't deleted
+			return diags
+		}
+
+		d.SetId(name)
+		return resourceShorelineObjectRead(typ, attrs, objectDef)(ctx, d, meta)
+	}
+}
+
 // returns skip, value, diagnostics
-func resourceShorelineObjectReadSingleAttr(name string, typ string, key string, attrs map[string]interface{}, record map[string]interface{}, stepsJs map[string]interface{}, d *schema.ResourceData, alias string, aliasMap map[string]interface{}) (bool, interface{}, diag.Diagnostics) {
+func resourceShorelineObjectReadSingleAttr(name string, typ string, key string, attrs map[string]interface{}, record map[string]interface{}, stepsJs map[string]interface{}, ctx context.Context, d *schema.ResourceData, alias string, aliasMap map[string]interface{}) (bool, interface{}, diag.Diagnostics) {
 	var val interface{}
 	attr := GetNestedValueOrDefault(attrs, ToKeyPath(key), map[string]interface{}{})
 	if alias != "" {
@@ -2211,7 +2408,7 @@ func resourceShorelineObjectReadSingleAttr(name string, typ string, key string,
 				}
 				for _, omitPath := range omitPaths {
 					omitTag := omitMap[omitPath]
-					appendActionLog(fmt.Sprintf("Omit path:'%+v' tag: '%+v'\n", omitPath, omitTag))
+					logDebug(ctx, logCrud, fmt.Sprintf("Omit path:'%+v' tag: '%+v'", omitPath, omitTag))
 					var cur interface{}
 					if omitPath == "." {
 						cur = val
@@ -2289,7 +2486,10 @@ func resourceShorelineObjectReadSingleAttr(name string, typ string, key string,
 }
 
 func SetSingleAttrFromRead(typ string, name string, key string, val interface{}, attrs map[string]interface{}, ctx context.Context, d *schema.ResourceData, meta interface{}) {
-	appendActionLog(fmt.Sprintf("Reading (updating local state) %s field: '%s'.'%s' ::(%T) %+v\n", typ, name, key, val, val))
+	if GetNestedValueOrDefault(attrs, ToKeyPath(key+".sensitive"), false).(bool) {
+		registerSecret(CastToString(val))
+	}
+	logDebug(ctx, logCrud, fmt.Sprintf("Reading (updating local state) %s field: '%s'.'%s' ::(%T) %+v", typ, name, key, val, logAttrValue(attrs, key, val)))
 	attrTyp := GetNestedValueOrDefault(attrs, ToKeyPath(key+".type"), "string").(string)
 	switch attrTyp {
 	case "float":
@@ -2322,7 +2522,7 @@ func SetSingleAttrFromRead(typ string, name string, key string, val interface{},
 func resourceShorelineObjectRead(typ string, attrs map[string]interface{}, objectDef map[string]interface{}) func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 		// use the meta value to retrieve your client from the provider configure method
-		// client := meta.(*apiClient)
+		client := meta.(*apiClient)
 
 		var diags diag.Diagnostics
 		name := d.Get("name").(string)
@@ -2332,12 +2532,13 @@ func resourceShorelineObjectRead(typ string, attrs map[string]interface{}, objec
 		}
 		// valid-variable-name check
 		idFromAPI := name
-		appendActionLog(fmt.Sprintf("Reading %s: '%s' (%v) :: %+v\n", typ, idFromAPI, name, d))
+		ctx = withResourceLogging(ctx, client, typ, name)
+		logDebug(ctx, logCrud, fmt.Sprintf("Reading %s: '%s' (id '%s')", typ, idFromAPI, d.Id()))
 
 		specialSkipFields := map[string]bool{}
 		if typ == "notebook" || typ == "runbook" {
 			if notebookIsInline(typ, attrs, objectDef, ctx, d, meta) {
-				appendActionLog(fmt.Sprintf("Reading %s:%v :: IS_INLINE\n", typ, name))
+				logDebug(ctx, logCrud, fmt.Sprintf("Reading %s:%v :: IS_INLINE", typ, name))
 				// TODO move this to the json-config
 				//specialSkipFields["cells"] = true
 				//specialSkipFields["params"] = true
@@ -2346,7 +2547,7 @@ func resourceShorelineObjectRead(typ string, attrs map[string]interface{}, objec
 
 				specialSkipFields["data"] = true
 			} else {
-				appendActionLog(fmt.Sprintf("Reading %s:%v :: NOT_INLINE\n", typ, name))
+				logDebug(ctx, logCrud, fmt.Sprintf("Reading %s:%v :: NOT_INLINE", typ, name))
 				//specialSkipFields["data"] = true
 
 				specialSkipFields["cells"] = true
@@ -2363,8 +2564,8 @@ func resourceShorelineObjectRead(typ string, attrs map[string]interface{}, objec
 				if key == "type" || key == "name" {
 					continue
 				}
-				op := fmt.Sprintf("%s.%s", name, key)
-				js, err := runOpCommandToJson(op)
+				op := opGetFieldStatement(name, key)
+				js, err := runOpCommandToJson(ctx, client, op)
 				if err != nil {
 					diags = diag.Errorf("Failed to read %s - %s.%s: %s", typ, name, key, err.Error())
 					return diags
@@ -2378,19 +2579,45 @@ func resourceShorelineObjectRead(typ string, attrs map[string]interface{}, objec
 			return diags
 		}
 
-		op := fmt.Sprintf("list %ss | name = \"%s\"", typ, name)
-		js, err := runOpCommandToJson(op)
+		op := opListStatement(typ, name)
+		js, err := runOpCommandToJson(ctx, client, op)
 		if err != nil {
 			diags = diag.Errorf("Failed to read %s - %s: %s", typ, name, err.Error())
 			return diags
 		}
 
+		found := false
+		record := map[string]interface{}{}
+		symbols, isArray := GetNestedValueOrDefault(js, ToKeyPath("list_type.symbol"), []interface{}{}).([]interface{})
+		if isArray {
+			for _, s := range symbols {
+				sName, isStr := GetNestedValueOrDefault(s, ToKeyPath("attributes.name"), "").(string)
+				if isStr && name == sName {
+					record = s.(map[string]interface{})
+					found = true
+				}
+			}
+		}
+
+		if !found {
+			// only a well-formed (but empty) list result confirms that the object is gone
+			_, isList := js["list_type"].(map[string]interface{})
+			if isList && isArray && !d.IsNewResource() {
+				// deleted outside of terraform (e.g. in the UI), so let terraform plan to re-create it
+				logWarn(ctx, logCrud, fmt.Sprintf("Reading %s: '%s' not found, removing from state", typ, name))
+				d.SetId("")
+				return diags
+			}
+			diags = diag.Errorf("Failed to find %s '%s'", typ, name)
+			return diags
+		}
+
 		stepsJs := map[string]interface{}{}
 
 		if typ == "alarm" || typ == "action" || typ == "bot" || typ == "integration" || typ == "notebook" || typ == "runbook" || typ == "time_trigger" || typ == "circuit_breaker" || typ == "report_template" || typ == "dashboard" {
 			// extract fields from step objects
-			op := fmt.Sprintf("get_%s_class( %s_name = \"%s\" )", typ, typ, name)
-			extraJs, err := runOpCommandToJson(op)
+			op := opGetClassStatement(typ, name)
+			extraJs, err := runOpCommandToJson(ctx, client, op)
 			if err != nil {
 				diags = diag.Errorf("Failed to read %s - %s: %s", typ, name, err.Error())
 				return diags
@@ -2422,29 +2649,11 @@ func resourceShorelineObjectRead(typ string, attrs map[string]interface{}, objec
 			}
 		}
 
-		found := false
-		record := map[string]interface{}{}
-		symbols, isArray := GetNestedValueOrDefault(js, ToKeyPath("list_type.symbol"), []interface{}{}).([]interface{})
-		if isArray {
-			for _, s := range symbols {
-				sName, isStr := GetNestedValueOrDefault(s, ToKeyPath("attributes.name"), "").(string)
-				if isStr && name == sName {
-					record = s.(map[string]interface{})
-					found = true
-				}
-			}
-		}
-
-		if !found {
-			diags = diag.Errorf("Failed to find %s '%s'", typ, name)
-			return diags
-		}
-
 		aliasKey, _ := GetNestedValueOrDefault(objectDef, ToKeyPath("internal.alias.key"), "").(string)
 		aliasKeyVal := ""
 		aliasMap := map[string]interface{}{}
 		if aliasKey != "" {
-			_, aliasKeyValIfc, diags := resourceShorelineObjectReadSingleAttr(name, typ, aliasKey, attrs, record, stepsJs, d, "", nil)
+			_, aliasKeyValIfc, diags := resourceShorelineObjectReadSingleAttr(name, typ, aliasKey, attrs, record, stepsJs, ctx, d, "", nil)
 			if diags != nil {
 				return diags
 			}
@@ -2476,18 +2685,18 @@ func resourceShorelineObjectRead(typ string, attrs map[string]interface{}, objec
 		for _, key := range attrList {
 			if specialSkipFields[key] {
 				val := "nil"
-				appendActionLog(fmt.Sprintf("Skipping read (special-skip) %s field: '%s'.'%s' :: %+v\n", typ, name, key, val))
+				logDebug(ctx, logCrud, fmt.Sprintf("Skipping read (special-skip) %s field: '%s'.'%s' :: %+v", typ, name, key, logAttrValue(attrs, key, val)))
 				continue
 			}
 
 			curAlias, _ := GetNestedValueOrDefault(aliasMap, ToKeyPath(key+".alias_out"), "").(string)
-			skip, val, diags := resourceShorelineObjectReadSingleAttr(name, typ, key, attrs, record, stepsJs, d, curAlias, aliasMap)
+			skip, val, diags := resourceShorelineObjectReadSingleAttr(name, typ, key, attrs, record, stepsJs, ctx, d, curAlias, aliasMap)
 
 			if diags != nil {
 				return diags
 			}
 			if skip {
-				appendActionLog(fmt.Sprintf("Reading (skip) %s field: '%s'.'%s' :: %+v\n", typ, name, key, val))
+				logDebug(ctx, logCrud, fmt.Sprintf("Reading (skip) %s field: '%s'.'%s' :: %+v", typ, name, key, logAttrValue(attrs, key, val)))
 				continue
 			}
 
@@ -2496,7 +2705,7 @@ func resourceShorelineObjectRead(typ string, attrs map[string]interface{}, objec
 			if replaces != "" {
 				_, replacesSet := d.GetOk(replaces)
 				if replacesSet {
-					appendActionLog(fmt.Sprintf("Reading deprecated/renamed skipping new (for obsolete) field : %s: '%s'.'%s'->'%s'  '%v'\n", typ, name, key, replaces, val))
+					logDebug(ctx, logCrud, fmt.Sprintf("Reading deprecated/renamed skipping new (for obsolete) field : %s: '%s'.'%s'->'%s'  '%v'", typ, name, key, replaces, logAttrValue(attrs, key, val)))
 					continue
 				}
 			}
@@ -2504,10 +2713,10 @@ func resourceShorelineObjectRead(typ string, attrs map[string]interface{}, objec
 			// on failure, if field is deprecated and renamed and set in HCL, try the new name
 			deprecatedFor := GetNestedValueOrDefault(attrs, ToKeyPath(key+".deprecated_for"), "").(string)
 			if deprecatedFor != "" && val == nil {
-				appendActionLog(fmt.Sprintf("Reading deprecated/renamed field : %s: '%s'.'%s'->'%s'  '%v'\n", typ, name, key, deprecatedFor, val))
+				logDebug(ctx, logCrud, fmt.Sprintf("Reading deprecated/renamed field : %s: '%s'.'%s'->'%s'  '%v'", typ, name, key, deprecatedFor, logAttrValue(attrs, key, val)))
 				_, isSet := d.GetOk(key)
 				if isSet {
-					_, val, diags = resourceShorelineObjectReadSingleAttr(name, typ, key, attrs, record, stepsJs, d, curAlias, aliasMap)
+					_, val, diags = resourceShorelineObjectReadSingleAttr(name, typ, key, attrs, record, stepsJs, ctx, d, curAlias, aliasMap)
 				}
 			}
 			if val == nil {
@@ -2523,11 +2732,11 @@ func resourceShorelineObjectRead(typ string, attrs map[string]interface{}, objec
 				defowlt := GetNestedValueOrDefault(attrs, ToKeyPath(key+".default"), nil)
 				if defowlt != nil {
 					val = defowlt
-					appendActionLog(fmt.Sprintf("Reading (default) %s field: '%s'.'%s' :: %+v\n", typ, name, key, val))
+					logDebug(ctx, logCrud, fmt.Sprintf("Reading (default) %s field: '%s'.'%s' :: %+v", typ, name, key, logAttrValue(attrs, key, val)))
 					//appendActionLog(fmt.Sprintf("Reading (default) %s field: '%s'.'%s' steps js::     %+v\n", typ, name, key, stepsJs))
 				} else {
 					// XXX error?
-					appendActionLog(fmt.Sprintf("Reading (failed/empty) %s field: '%s'.'%s' :: %+v\n", typ, name, key, val))
+					logDebug(ctx, logCrud, fmt.Sprintf("Reading (failed/empty) %s field: '%s'.'%s' :: %+v", typ, name, key, logAttrValue(attrs, key, val)))
 					if typ == "file" {
 						if key == "input_file" || key == "md5" || key == "inline_data" {
 							continue
@@ -2544,8 +2753,8 @@ func resourceShorelineObjectRead(typ string, attrs map[string]interface{}, objec
 				// Later cleanup (omit) of fields in 'data' may affect this, so copy...
 				val = DeepCopy(val)
 				valArr := val.([]interface{})
-				NormalizeNotebookCells(&valArr)
-				appendActionLog(fmt.Sprintf("Reading (special notebook.cells) %s field: '%s'.'%s' :: %+v\n", typ, name, key, valArr))
+				NormalizeNotebookCells(ctx, meta.(*apiClient), &valArr)
+				logDebug(ctx, logCrud, fmt.Sprintf("Reading (special notebook.cells) %s field: '%s'.'%s' :: %d cells", typ, name, key, len(valArr)))
 				d.Set(key, CastToString(valArr))
 				continue
 			}
@@ -2563,7 +2772,9 @@ func resourceShorelineObjectUpdate(typ string, attrs map[string]interface{}, obj
 
 		var diags diag.Diagnostics
 		name := d.Get("name").(string)
-		appendActionLog(fmt.Sprintf("Updated object '%s': '%s' :: %+v\n", typ, name, d))
+		ctx = withResourceLogging(ctx, meta.(*apiClient), typ, name)
+		logInfo(ctx, logCrud, fmt.Sprintf("Updating %s: '%s'", typ, name))
+		logDebug(ctx, logCrud, fmt.Sprintf("Updated object '%s': '%s' (id '%s')", typ, name, d.Id()))
 
 		if typ == "system_settings" {
 			diags = updateSystemSettings(attrs, objectDef, ctx, d, meta)
@@ -2571,7 +2782,8 @@ func resourceShorelineObjectUpdate(typ string, attrs map[string]interface{}, obj
 			diags = resourceShorelineObjectSetFields(typ, attrs, objectDef, ctx, d, meta, true, false)
 		}
 		if diags != nil {
-			// TODO delete incomplete object?
+			// the applied updates were rolled back (see opBatch.flush()), so keep the previous state
+			d.Partial(true)
 			return diags
 		}
 
@@ -2583,11 +2795,13 @@ func resourceShorelineObjectUpdate(typ string, attrs map[string]interface{}, obj
 func resourceShorelineObjectDelete(typ string, objectDef map[string]interface{}) func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 		// use the meta value to retrieve your client from the provider configure method
-		// client := meta.(*apiClient)
+		client := meta.(*apiClient)
 
 		var diags diag.Diagnostics
 		name := d.Get("name").(string)
-		appendActionLog(fmt.Sprintf("deleting %s: '%s' :: %+v\n", typ, name, d))
+		ctx = withResourceLogging(ctx, client, typ, name)
+		logInfo(ctx, logCrud, fmt.Sprintf("Deleting %s: '%s'", typ, name))
+		logDebug(ctx, logCrud, fmt.Sprintf("deleting %s: '%s' (id '%s')", typ, name, d.Id()))
 
 		// return early if "no_delete"
 		isNoCreate, _ := GetNestedValueOrDefault(objectDef, ToKeyPath("internal.no_delete"), false).(bool)
@@ -2595,11 +2809,17 @@ func resourceShorelineObjectDelete(typ string, objectDef map[string]interface{})
 			return diags
 		}
 
-		op := fmt.Sprintf("delete %s", name)
-		result, err := runOpCommand(op, true)
+		op := opDeleteStatement(name)
+		result, err := runOpCommand(ctx, client, op, true)
 		if err != nil {
-			// TODO check already exists
+			annotateBackendError(err, op, typ, name)
+			if errors.Is(err, ErrNotFound) {
+				// already deleted (e.g. outside of terraform)
+				logDebug(ctx, logCrud, fmt.Sprintf("Deleting %s: '%s' already gone: %s", typ, name, err.Error()))
+				return diags
+			}
 			diags = diag.Errorf("Failed to delete %s: %s", typ, err.Error())
+			return diags
 		}
 		err = CheckUpdateResult(result)
 		if err != nil {
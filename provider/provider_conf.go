@@ -143,19 +143,19 @@ var ObjectConfigJsonStr = `
 				"key": "service_name",
 				"map": {
 					"newrelic": {
-						"api_key": { "type": "string",   "optional": true, "step": "params_unpack.incident_management_api_key", "alias_out": "incident_management_api_key" },
+						"api_key": { "type": "string",   "optional": true, "sensitive": true, "step": "params_unpack.incident_management_api_key", "alias_out": "incident_management_api_key" },
 						"api_url": { "type": "string",   "optional": true, "step": "params_unpack.incident_management_url",     "alias_out": "incident_management_url" }
 					},
 					"elastic": {
-						"api_key": { "type": "string",   "optional": true, "step": "params_unpack.api_token", "alias_out": "api_token" },
+						"api_key": { "type": "string",   "optional": true, "sensitive": true, "step": "params_unpack.api_token", "alias_out": "api_token" },
 						"api_url": { "type": "string",   "optional": true, "step": "params_unpack.url",       "alias_out": "url" }
 					},
 					"fluentbit_elastic": {
-						"api_key": { "type": "string",   "optional": true, "step": "params_unpack.api_token", "alias_out": "api_token" },
+						"api_key": { "type": "string",   "optional": true, "sensitive": true, "step": "params_unpack.api_token", "alias_out": "api_token" },
 						"api_url": { "type": "string",   "optional": true, "step": "params_unpack.url",       "alias_out": "url" }
 					},
 					"okta": {
-						"api_key": { "type": "string",   "optional": true, "step": "params_unpack.api_token", "alias_out": "api_token" },
+						"api_key": { "type": "string",   "optional": true, "sensitive": true, "step": "params_unpack.api_token", "alias_out": "api_token" },
 						"api_url": { "type": "string",   "optional": true, "step": "params_unpack.url",       "alias_out": "url" }
 					}
 				}
@@ -169,17 +169,17 @@ var ObjectConfigJsonStr = `
 			"permissions_user":            { "type": "string",   "optional": true, "match_null": "Shoreline" },
 			"api_url":                     { "type": "string",   "optional": true, "step": "params_unpack.api_url" },
 			"site_url":                    { "type": "string",   "optional": true, "step": "params_unpack.site_url", "min_ver": "19.0.0"},
-			"api_key":                     { "type": "string",   "optional": true, "step": "params_unpack.api_key" },
-			"app_key":                     { "type": "string",   "optional": true, "step": "params_unpack.app_key" },
+			"api_key":                     { "type": "string",   "optional": true, "sensitive": true, "step": "params_unpack.api_key" },
+			"app_key":                     { "type": "string",   "optional": true, "sensitive": true, "step": "params_unpack.app_key" },
 			"dashboard_name":              { "type": "string",   "optional": true, "step": "params_unpack.dashboard_name", "max_ver": "25.99.999", "deprecated": true },
 			"webhook_name":                { "type": "string",   "optional": true, "step": "params_unpack.webhook_name" },
 			"##description":               { "type": "string",   "optional": true },
 
 			"account_id":                  { "type": "string",   "optional": true, "step": "params_unpack.account_id" },
 			"insights_collector_url":      { "type": "string",   "optional": true, "step": "params_unpack.insights_collector_url" },
-			"insights_collector_api_key":  { "type": "string",   "optional": true, "step": "params_unpack.insights_collector_api_key" },
+			"insights_collector_api_key":  { "type": "string",   "optional": true, "sensitive": true, "step": "params_unpack.insights_collector_api_key" },
 			"#incident_management_url":     { "type": "string",   "optional": true, "step": "params_unpack.incident_management_url" },
-			"#incident_management_api_key": { "type": "string",   "optional": true, "step": "params_unpack.incident_management_api_key" },
+			"#incident_management_api_key": { "type": "string",   "optional": true, "sensitive": true, "step": "params_unpack.incident_management_api_key" },
 
 			"cache_ttl":                   { "type": "int", "optional": true, "step": "params_unpack.cache_ttl" },
 			"api_rate_limit":              { "type": "int", "optional": true, "step": "params_unpack.api_rate_limit" },
@@ -189,15 +189,15 @@ var ObjectConfigJsonStr = `
 
 			"cache_ttl_ms":                { "type": "int",    "optional": true, "min_ver": "18.0.0", "step": "params_unpack.cache_ttl_ms" },
 			"subject":                     { "type": "string", "optional": true, "min_ver": "18.0.0", "step": "params_unpack.subject" },
-			"credentials":                 { "type": "string", "optional": true, "min_ver": "18.0.0", "step": "params_unpack.credentials" },
+			"credentials":                 { "type": "string", "optional": true, "sensitive": true, "min_ver": "18.0.0", "step": "params_unpack.credentials" },
 
 			"tenant_id":                   { "type": "string", "optional": true, "min_ver": "18.0.0", "step": "params_unpack.tenant_id" },
 			"client_id":                   { "type": "string", "optional": true, "min_ver": "18.0.0", "step": "params_unpack.client_id" },
-			"client_secret":               { "type": "string", "optional": true, "min_ver": "18.0.0", "step": "params_unpack.client_secret" },
+			"client_secret":               { "type": "string", "optional": true, "sensitive": true, "min_ver": "18.0.0", "step": "params_unpack.client_secret" },
 
 			"idp_name":                    { "type": "string", "optional": true, "min_ver": "22.0.0", "step": "params_unpack.idp_name" },
 
-			"api_certificate":             { "type": "string", "optional": true, "min_ver": "28.1.0", "step": "params_unpack.api_certificate" }
+			"api_certificate":             { "type": "string", "optional": true, "sensitive": true, "min_ver": "28.1.0", "step": "params_unpack.api_certificate" }
 		}
 	},
 
@@ -309,7 +309,7 @@ var ObjectConfigJsonStr = `
 			"external_audit_storage_enabled":                   { "type": "bool",     "optional": true, "default": false },
 			"external_audit_storage_type":                      { "type": "command",  "optional": true, "default": "ELASTIC" },
 			"#external_audit_storage_url":                      { "type": "string",   "optional": true },
-			"#external_audit_storage_api_token":                { "type": "string",   "optional": true },
+			"#external_audit_storage_api_token":                { "type": "string",   "optional": true, "sensitive": true },
 			"external_audit_storage_batch_period_sec":          { "type": "int",      "optional": true, "default": 5 },
 			"environment_name":                                 { "type": "string",   "optional": true, "default": "" },
 			"environment_name_background":                      { "type": "string",   "optional": true, "min_ver": "18.0.0", "default": "#EF5350" },
@@ -15,17 +15,29 @@ import (
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider/mockbackend"
 )
 
+// mockServer is an in-process backend, used when SHORELINE_URL isn't set.
+var mockServer *mockbackend.Server
+
 func getProviderConfigString() string {
 	url := "https://opsstage.us.api.shoreline-stage.io"
 	envUrl, urlDefined := os.LookupEnv("SHORELINE_URL")
 	if urlDefined {
 		url = envUrl
 	}
+	token := ""
+	if mockServer != nil {
+		url = mockServer.URL
+		token = `token = "` + mockServer.RefreshToken() + `"`
+	}
 	return `
 	provider "shoreline" {
 		url = "` + url + `"
+		` + token + `
 		retries = 2
 		debug = true
 	}
@@ -47,11 +59,33 @@ func TestProvider(t *testing.T) {
 	}
 }
 
+func TestProviderRequestTimeoutValidation(t *testing.T) {
+	p := New("dev")()
+	for timeout, valid := range map[int]bool{-1: false, 0: false, 1: true, 60: true} {
+		raw := terraform.NewResourceConfigRaw(map[string]interface{}{"url": "https://test.us.api.shoreline-test.io", "request_timeout": timeout})
+		if diags := p.Validate(raw); diags.HasError() == valid {
+			t.Errorf("request_timeout %d: expected valid=%v, got %+v", timeout, valid, diags)
+		}
+	}
+}
+
 //func TestMain(m *testing.M) {
 //  acctest.UseBinaryDriver("shoreline", New("dev"))
 //  resource.TestMain(m)
 //}
 
+func TestMain(m *testing.M) {
+	// Run against the mock backend, unless a live one is configured.
+	if os.Getenv("SHORELINE_URL") == "" {
+		mockServer = mockbackend.New(ObjectConfigJsonStr)
+	}
+	code := m.Run()
+	if mockServer != nil {
+		mockServer.Close()
+	}
+	os.Exit(code)
+}
+
 func testAccPreCheck(t *testing.T) {
 	// You can add code here to run prior to any test case execution, for example assertions
 	// about the appropriate environment variables being set are common to see in a pre-check
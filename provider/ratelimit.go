@@ -0,0 +1,132 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter is shared by all the API requests of a provider instance (including the parallel
+// resource operations), to cap both the request rate and the number of requests in flight.
+// A nil RateLimiter doesn't limit anything.
+type RateLimiter struct {
+	perSecond float64
+	burst     float64
+	slots     chan struct{} // nil for unlimited concurrency
+
+	mu          sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// NewRateLimiter returns a limiter for 'perSecond' requests (token bucket) with at most
+// 'maxConcurrent' in flight. Zero means unlimited, and nil is returned if neither is limited.
+func NewRateLimiter(perSecond float64, maxConcurrent int) *RateLimiter {
+	if perSecond <= 0 && maxConcurrent <= 0 {
+		return nil
+	}
+	lim := &RateLimiter{perSecond: perSecond}
+	if perSecond > 0 {
+		// allow up to a second's worth of requests in a burst
+		lim.burst = math.Max(1, math.Ceil(perSecond))
+		lim.tokens = lim.burst
+		lim.last = time.Now()
+	}
+	if maxConcurrent > 0 {
+		lim.slots = make(chan struct{}, maxConcurrent)
+	}
+	return lim
+}
+
+// Acquire waits for a request slot, and returns the function that releases it.
+func (lim *RateLimiter) Acquire(ctx context.Context) (func(), error) {
+	if lim == nil {
+		return func() {}, nil
+	}
+	if err := lim.waitForRate(ctx); err != nil {
+		return nil, err
+	}
+	if lim.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case lim.slots <- struct{}{}:
+		return func() { <-lim.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// PauseFor holds back all requests for the given delay, e.g. from a 429 Retry-After header.
+func (lim *RateLimiter) PauseFor(delay time.Duration) {
+	if lim == nil || delay <= 0 {
+		return
+	}
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	if until := time.Now().Add(delay); until.After(lim.pausedUntil) {
+		lim.pausedUntil = until
+	}
+}
+
+func (lim *RateLimiter) waitForRate(ctx context.Context) error {
+	lim.mu.Lock()
+	now := time.Now()
+	wait := lim.pausedUntil.Sub(now)
+	if lim.perSecond > 0 {
+		// refill the bucket, then reserve a token (which may be in the future)
+		lim.tokens = math.Min(lim.burst, lim.tokens+now.Sub(lim.last).Seconds()*lim.perSecond)
+		lim.last = now
+		lim.tokens -= 1
+		if lim.tokens < 0 {
+			if rateWait := time.Duration(-lim.tokens / lim.perSecond * float64(time.Second)); rateWait > wait {
+				wait = rateWait
+			}
+		}
+	}
+	lim.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		if lim.perSecond > 0 {
+			// hand back the reserved token
+			lim.mu.Lock()
+			lim.tokens += 1
+			lim.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a delay in seconds or an HTTP date.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
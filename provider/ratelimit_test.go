@@ -0,0 +1,197 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider/mockbackend"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{" 10 ", 10 * time.Second},
+		{"-1", 0},
+		{now.Add(7 * time.Second).Format(http.TimeFormat), 7 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		header.Set("Retry-After", tt.value)
+		if got := parseRetryAfter(header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	if lim := NewRateLimiter(0, 0); lim != nil {
+		t.Fatalf("Expected no limiter without limits")
+	}
+	var lim *RateLimiter
+	release, err := lim.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Expected a nil limiter to allow requests, got: %s", err)
+	}
+	release()
+	lim.PauseFor(time.Hour)
+}
+
+func TestRateLimiterRate(t *testing.T) {
+	lim := NewRateLimiter(20, 0)
+	start := time.Now()
+	// a burst of 20, then 10 more at 20/s
+	for i := 0; i < 30; i++ {
+		release, err := lim.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to acquire: %s", err)
+		}
+		release()
+	}
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Expected the requests beyond the burst to be spread over ~0.5s, took %s", elapsed)
+	}
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	lim := NewRateLimiter(0, 3)
+	var inFlight, maxInFlight int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := lim.Acquire(context.Background())
+			if err != nil {
+				t.Errorf("Failed to acquire: %s", err)
+				return
+			}
+			defer release()
+			cur := atomic.AddInt32(&inFlight, 1)
+			for {
+				prev := atomic.LoadInt32(&maxInFlight)
+				if cur <= prev || atomic.CompareAndSwapInt32(&maxInFlight, prev, cur) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		}()
+	}
+	wg.Wait()
+	if maxInFlight != 3 {
+		t.Errorf("Expected at most 3 requests in flight, got %d", maxInFlight)
+	}
+}
+
+func TestRateLimiterCancelled(t *testing.T) {
+	lim := NewRateLimiter(0, 1)
+	release, _ := lim.Acquire(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := lim.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait for a slot to be cancelled, got: %v", err)
+	}
+	release()
+
+	lim = NewRateLimiter(1, 0)
+	lim.PauseFor(time.Hour)
+	if _, err := lim.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait for a paused limiter to be cancelled, got: %v", err)
+	}
+}
+
+func TestMockRetryAfter(t *testing.T) {
+	_, meta := testMockProviderWithConfig(t, map[string]interface{}{"retries": 2})
+	client := meta.(*apiClient)
+	testFastRetries(t)
+	if _, err := runOpCommand(context.Background(), client, "backend_version", false); err != nil {
+		t.Fatalf("Failed to run command: %s", err)
+	}
+	mockServer.ResetRequests()
+	mockServer.InjectFault(mockbackend.Fault{
+		Path:   "/v1/execute",
+		Status: 429,
+		Body:   "slow down",
+		Header: http.Header{"Retry-After": []string{"1"}},
+	})
+	defer mockServer.ResetFaults()
+
+	start := time.Now()
+	if _, err := runOpCommand(context.Background(), client, "backend_version", false); err != nil {
+		t.Fatalf("Expected the command to succeed after the rate limit, got: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected the retry to wait for the Retry-After delay, took %s", elapsed)
+	}
+	if count := testMockExecuteCount(); count != 2 {
+		t.Errorf("Expected 2 execute calls, got %d", count)
+	}
+}
+
+func TestMockRetryAfterWithoutRetries(t *testing.T) {
+	// 'retries' is unset (0), but rate limited requests are still retried
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	testFastRetries(t)
+	mockServer.ResetRequests()
+	mockServer.InjectFault(
+		mockbackend.Fault{Path: "/v1/execute", Status: 429, Body: "slow down", Header: http.Header{"Retry-After": []string{"0"}}},
+		mockbackend.Fault{Path: "/v1/execute", Status: 429, Body: "slow down"},
+	)
+	defer mockServer.ResetFaults()
+
+	if _, err := runOpCommand(context.Background(), client, "backend_version", false); err != nil {
+		t.Fatalf("Expected the command to succeed after the rate limit, got: %s", err)
+	}
+	if count := testMockExecuteCount(); count != 3 {
+		t.Errorf("Expected 3 execute calls, got %d", count)
+	}
+
+	// other transient errors still need 'retries'
+	mockServer.ResetRequests()
+	mockServer.InjectFault(mockbackend.Fault{Path: "/v1/execute", Status: 503, Body: "service unavailable"})
+	if _, err := runOpCommand(context.Background(), client, "backend_version", false); err == nil {
+		t.Fatalf("Expected the unavailable error without retries")
+	}
+	if count := testMockExecuteCount(); count != 1 {
+		t.Errorf("Expected a single execute call, got %d", count)
+	}
+}
+
+func TestMockRateLimitedProvider(t *testing.T) {
+	_, meta := testMockProviderWithConfig(t, map[string]interface{}{
+		"max_requests_per_second": 50.0,
+		"max_concurrent_requests": 2,
+	})
+	client := meta.(*apiClient)
+	if client.limiter == nil || cap(client.limiter.slots) != 2 || client.limiter.perSecond != 50 {
+		t.Fatalf("Expected the provider's rate limits to be configured, got: %+v", client.limiter)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := runOpCommand(context.Background(), client, "backend_version", false); err != nil {
+				t.Errorf("Failed to run command: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,183 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const redactedValue = "<redacted>"
+
+// values shorter than this aren't registered, as scrubbing them would mangle unrelated text
+const minSecretLength = 4
+
+var (
+	// e.g. access and refresh tokens, or secrets that happen to be JWTs
+	jwtRegex    = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerRegex = regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+)
+
+// secretRegistry is the (process wide) set of secret values and attribute names to scrub
+// from log entries and diagnostics.
+type secretRegistry struct {
+	mu      sync.RWMutex
+	values  map[string]bool
+	ordered []string // longest first, so overlapping secrets are fully scrubbed
+	keys    map[string]bool
+	// matches sensitive attribute assignments in op statements, e.g. `x.api_key = "..."`
+	keyRegex *regexp.Regexp
+}
+
+var secrets = &secretRegistry{values: map[string]bool{}, keys: map[string]bool{}}
+
+// registerSecret adds a value to scrub, e.g. the value of a sensitive attribute.
+func registerSecret(val string) {
+	val = strings.TrimSpace(val)
+	if len(val) < minSecretLength {
+		return
+	}
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	if secrets.values[val] {
+		return
+	}
+	secrets.values[val] = true
+	secrets.ordered = append(secrets.ordered, val)
+	sort.SliceStable(secrets.ordered, func(i, j int) bool { return len(secrets.ordered[i]) > len(secrets.ordered[j]) })
+}
+
+// registerSensitiveKey adds an attribute (or backend field) name whose assigned values are scrubbed from op statements.
+func registerSensitiveKey(key string) {
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	if key == "" || secrets.keys[key] {
+		return
+	}
+	secrets.keys[key] = true
+	keys := make([]string, 0, len(secrets.keys))
+	for k := range secrets.keys {
+		keys = append(keys, regexp.QuoteMeta(k))
+	}
+	sort.Strings(keys)
+	// a quoted (possibly escaped) string, or a bare value
+	secrets.keyRegex = regexp.MustCompile(`(\.(?:` + strings.Join(keys, "|") + `)\s*=\s*)("(?:[^"\\]|\\.)*"|[^\s;]+)`)
+}
+
+// registerSensitiveAttributes registers the attributes marked "sensitive" in an object's config (including aliases).
+func registerSensitiveAttributes(object interface{}) {
+	objMap, isMap := object.(map[string]interface{})
+	if !isMap {
+		return
+	}
+	for k, v := range objMap {
+		attrMap, isMap := v.(map[string]interface{})
+		if !isMap {
+			continue
+		}
+		if sensitive, _ := attrMap["sensitive"].(bool); sensitive {
+			registerSensitiveKey(strings.TrimLeft(k, "#"))
+			if alias, isStr := attrMap["alias_out"].(string); isStr {
+				registerSensitiveKey(alias)
+			}
+			continue
+		}
+		registerSensitiveAttributes(attrMap)
+	}
+}
+
+// redactSecrets scrubs the registered secrets, sensitive statement values, JWTs and bearer tokens from a string.
+func redactSecrets(str string) string {
+	if str == "" {
+		return str
+	}
+	secrets.mu.RLock()
+	keyRegex := secrets.keyRegex
+	for _, val := range secrets.ordered {
+		str = strings.ReplaceAll(str, val, redactedValue)
+	}
+	secrets.mu.RUnlock()
+	if keyRegex != nil {
+		str = keyRegex.ReplaceAllString(str, "${1}"+redactedValue)
+	}
+	str = bearerRegex.ReplaceAllString(str, "${1}"+redactedValue)
+	return jwtRegex.ReplaceAllString(str, redactedValue)
+}
+
+// redactValue scrubs the strings in a (log field) value.
+func redactValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case string:
+		return redactSecrets(v)
+	case error:
+		return redactSecrets(v.Error())
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = redactValue(e)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = redactValue(e)
+		}
+		return out
+	}
+	return val
+}
+
+// logAttrValue returns an attribute value to log, or a placeholder if the attribute is sensitive.
+func logAttrValue(attrs map[string]interface{}, key string, val interface{}) interface{} {
+	if GetNestedValueOrDefault(attrs, ToKeyPath(key+".sensitive"), false).(bool) {
+		return redactedValue
+	}
+	// alias maps don't carry the attribute flags, so also check the registered sensitive keys
+	secrets.mu.RLock()
+	sensitive := secrets.keys[strings.TrimLeft(key, "#")]
+	secrets.mu.RUnlock()
+	if sensitive {
+		return redactedValue
+	}
+	return val
+}
+
+func redactDiagnostics(diags diag.Diagnostics) diag.Diagnostics {
+	for i := range diags {
+		diags[i].Summary = redactSecrets(diags[i].Summary)
+		diags[i].Detail = redactSecrets(diags[i].Detail)
+	}
+	return diags
+}
+
+// registerResourceSecrets registers the (configured and stored) values of the sensitive attributes of a resource.
+func registerResourceSecrets(sch map[string]*schema.Schema, d *schema.ResourceData) {
+	for key, s := range sch {
+		if !s.Sensitive {
+			continue
+		}
+		old, nu := d.GetChange(key)
+		if str, isStr := old.(string); isStr {
+			registerSecret(str)
+		}
+		if str, isStr := nu.(string); isStr {
+			registerSecret(str)
+		}
+	}
+}
+
+// withRedaction wraps a CRUD function, to register the resource's secrets before anything is logged,
+// and to scrub them from the returned diagnostics.
+func withRedaction(sch map[string]*schema.Schema, fn func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics) func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		registerResourceSecrets(sch, d)
+		return redactDiagnostics(fn(ctx, d, meta))
+	}
+}
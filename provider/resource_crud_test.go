@@ -0,0 +1,309 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider/mockbackend"
+)
+
+// These tests drive the resource CRUD functions directly against the mock backend,
+// so they don't need the terraform CLI.
+
+func testMockProvider(t *testing.T) (*schema.Provider, interface{}) {
+	return testMockProviderWithConfig(t, nil)
+}
+
+// testMockProviderWithConfig configures a provider against the mock, with extra provider attributes.
+func testMockProviderWithConfig(t *testing.T, extra map[string]interface{}) (*schema.Provider, interface{}) {
+	if mockServer == nil {
+		t.Skip("SHORELINE_URL is set, skipping mock backend tests")
+	}
+	p := New("dev")()
+	config := map[string]interface{}{
+		"url":   mockServer.URL,
+		"token": mockServer.RefreshToken(),
+	}
+	for k, v := range extra {
+		config[k] = v
+	}
+	raw := terraform.NewResourceConfigRaw(config)
+	if diags := p.Configure(context.Background(), raw); diags.HasError() {
+		t.Fatalf("Failed to configure provider: %+v", diags)
+	}
+	return p, p.Meta()
+}
+
+func testMockCreate(t *testing.T, p *schema.Provider, meta interface{}, resType string, raw map[string]interface{}) *schema.ResourceData {
+	res := p.ResourcesMap[resType]
+	d := schema.TestResourceDataRaw(t, res.Schema, raw)
+	if diags := res.CreateContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("Failed to create %s: %+v", resType, diags)
+	}
+	return d
+}
+
+// testMockImport reads an object into empty state, as 'terraform import' does.
+func testMockImport(t *testing.T, p *schema.Provider, meta interface{}, resType string, id string) *schema.ResourceData {
+	res := p.ResourcesMap[resType]
+	d := res.TestResourceData()
+	d.SetId(id)
+	if diags := res.ReadContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("Failed to read %s: %+v", resType, diags)
+	}
+	return d
+}
+
+func TestMockResourceActionLifecycle(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_action"
+
+	d := testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":                 name,
+		"command":              "`ls ${dir}; export FOO='bar'`",
+		"description":          "List some \"quoted\" files",
+		"params":               []interface{}{"dir"},
+		"enabled":              true,
+		"timeout":              20,
+		"start_title_template": "my_action started",
+		"allowed_entities":     []interface{}{"user1", "user2"},
+	})
+	if d.Id() != name {
+		t.Fatalf("Expected id '%s', got '%s'", name, d.Id())
+	}
+	typ, attrs, found := mockServer.Object(name)
+	if !found || typ != "action" {
+		t.Fatalf("Expected action '%s' on the backend, got (%v) '%s'", name, found, typ)
+	}
+	if attrs["description"] != "List some \"quoted\" files" {
+		t.Errorf("Unexpected backend description: %v", attrs["description"])
+	}
+
+	imported := testMockImport(t, p, meta, "shoreline_action", name)
+	checks := map[string]interface{}{
+		"command":              "`ls ${dir}; export FOO='bar'`",
+		"description":          "List some \"quoted\" files",
+		"enabled":              true,
+		"timeout":              20,
+		"start_title_template": "my_action started",
+		"type":                 "ACTION",
+	}
+	for key, want := range checks {
+		if got := imported.Get(key); got != want {
+			t.Errorf("Imported %s: expected '%v', got '%v'", key, want, got)
+		}
+	}
+	if entities := imported.Get("allowed_entities").([]interface{}); len(entities) != 2 {
+		t.Errorf("Imported allowed_entities: expected 2 entries, got %v", entities)
+	}
+
+	res := p.ResourcesMap["shoreline_action"]
+	if diags := res.DeleteContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("Failed to delete action: %+v", diags)
+	}
+	if _, _, found := mockServer.Object(name); found {
+		t.Errorf("Expected action '%s' to be deleted", name)
+	}
+}
+
+func TestMockResourceAlarmStepFields(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_alarm"
+
+	testMockCreate(t, p, meta, "shoreline_alarm", map[string]interface{}{
+		"name":                name,
+		"fire_query":          "( cpu_usage > 0 | sum ( 5 ) ) >= 2",
+		"clear_query":         "( cpu_usage < 0 | sum ( 5 ) ) >= 2",
+		"resource_query":      "host",
+		"fire_title_template": "alarm fired",
+		"metric_name":         "cpu_usage",
+		"condition_type":      "above",
+	})
+
+	imported := testMockImport(t, p, meta, "shoreline_alarm", name)
+	checks := map[string]interface{}{
+		"fire_query":          "( cpu_usage > 0 | sum ( 5 ) ) >= 2",
+		"fire_title_template": "alarm fired",
+		"metric_name":         "cpu_usage",
+		"condition_type":      "above",
+		"family":              "custom",
+	}
+	for key, want := range checks {
+		if got := imported.Get(key); got != want {
+			t.Errorf("Imported %s: expected '%v', got '%v'", key, want, got)
+		}
+	}
+}
+
+func TestMockResourceBotCompoundCommand(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_bot"
+
+	testMockCreate(t, p, meta, "shoreline_bot", map[string]interface{}{
+		"name":    name,
+		"command": "if my_alarm then my_action fi",
+		"enabled": true,
+	})
+
+	imported := testMockImport(t, p, meta, "shoreline_bot", name)
+	if got := imported.Get("command"); got != "if my_alarm then my_action fi" {
+		t.Errorf("Imported command: got '%v'", got)
+	}
+	if got := imported.Get("enabled"); got != true {
+		t.Errorf("Imported enabled: got '%v'", got)
+	}
+}
+
+func TestMockResourceSystemSettings(t *testing.T) {
+	p, meta := testMockProvider(t)
+
+	testMockCreate(t, p, meta, "shoreline_system_settings", map[string]interface{}{
+		"name":             "system_settings",
+		"environment_name": "Test \"Env\"",
+	})
+	val, found := mockServer.Setting("system_settings", "environment_name")
+	if !found || val != "Test \"Env\"" {
+		t.Errorf("Expected environment_name to be set on the backend, got (%v) '%v'", found, val)
+	}
+
+	imported := testMockImport(t, p, meta, "shoreline_system_settings", "system_settings")
+	if got := imported.Get("environment_name"); got != "Test \"Env\"" {
+		t.Errorf("Imported environment_name: got '%v'", got)
+	}
+}
+
+func TestMockProviderInstancesAreIndependent(t *testing.T) {
+	p, meta := testMockProvider(t)
+	otherServer := mockbackend.New(ObjectConfigJsonStr)
+	defer otherServer.Close()
+
+	other := New("dev")()
+	raw := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"url":     otherServer.URL,
+		"token":   otherServer.RefreshToken(),
+		"retries": 2,
+	})
+	if diags := other.Configure(context.Background(), raw); diags.HasError() {
+		t.Fatalf("Failed to configure second provider: %+v", diags)
+	}
+
+	name := RandomAlphaPrefix(5) + "_action"
+	testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":    name,
+		"command": "`hostname`",
+	})
+	otherName := RandomAlphaPrefix(5) + "_action"
+	testMockCreate(t, other, other.Meta(), "shoreline_action", map[string]interface{}{
+		"name":    otherName,
+		"command": "`uptime`",
+	})
+
+	if _, _, found := mockServer.Object(name); !found {
+		t.Errorf("Expected action '%s' on the first backend", name)
+	}
+	if _, _, found := mockServer.Object(otherName); found {
+		t.Errorf("Action '%s' leaked to the first backend", otherName)
+	}
+	if _, _, found := otherServer.Object(otherName); !found {
+		t.Errorf("Expected action '%s' on the second backend", otherName)
+	}
+	if _, _, found := otherServer.Object(name); found {
+		t.Errorf("Action '%s' leaked to the second backend", name)
+	}
+	if client := meta.(*apiClient); client.retryLimit != 0 {
+		t.Errorf("Expected the first provider to keep its own retry limit, got %d", client.retryLimit)
+	}
+}
+
+func TestMockResourceDeletedOutOfBand(t *testing.T) {
+	p, meta := testMockProvider(t)
+	res := p.ResourcesMap["shoreline_runbook"]
+	name := RandomAlphaPrefix(5) + "_runbook"
+	d := testMockCreate(t, p, meta, "shoreline_runbook", map[string]interface{}{
+		"name":  name,
+		"cells": `[{"op":"hostname"}]`,
+	})
+	if d.Id() != name {
+		t.Fatalf("Expected the runbook to be created, got id '%s'", d.Id())
+	}
+
+	// a failed list doesn't confirm anything, so the refresh fails and the state is kept
+	mockServer.FailStatements(`^list notebooks`, "backend unavailable")
+	if diags := res.ReadContext(context.Background(), d, meta); !diags.HasError() {
+		t.Errorf("Expected the refresh to fail on a backend error")
+	}
+	mockServer.ResetFaults()
+	if d.Id() != name {
+		t.Errorf("Expected the runbook to stay in state after a backend error")
+	}
+
+	// deleted in the UI
+	mockServer.DeleteObject(name)
+	if diags := res.ReadContext(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("Expected the refresh to succeed, got: %+v", diags)
+	}
+	if d.Id() != "" {
+		t.Errorf("Expected the runbook to be removed from state, got id '%s'", d.Id())
+	}
+}
+
+func TestMockResourceAdoptExisting(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.PutObject("action", name, map[string]interface{}{"command": "`uptime`", "description": "built by hand"})
+
+	d := testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":           name,
+		"command":        "`hostname`",
+		"description":    "adopted",
+		"adopt_existing": true,
+	})
+	if d.Id() != name {
+		t.Errorf("Expected the adopted action in state, got id '%s'", d.Id())
+	}
+	_, attrs, _ := mockServer.Object(name)
+	if attrs["command"] != "`hostname`" || attrs["description"] != "adopted" {
+		t.Errorf("Expected the configured fields on the adopted action, got: %v", attrs)
+	}
+}
+
+func TestMockProviderAdoptExisting(t *testing.T) {
+	p, meta := testMockProviderWithConfig(t, map[string]interface{}{"adopt_existing": true})
+	name := RandomAlphaPrefix(5) + "_action"
+	mockServer.PutObject("action", name, map[string]interface{}{"command": "`uptime`"})
+
+	d := testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":    name,
+		"command": "`hostname`",
+	})
+	if d.Id() != name {
+		t.Errorf("Expected the adopted action in state, got id '%s'", d.Id())
+	}
+}
+
+func TestMockAdoptExistingOtherType(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_alarm"
+	mockServer.PutObject("alarm", name, map[string]interface{}{"fire_query": "(cpu_usage > 0 | sum(5)) >= 2.0"})
+
+	res := p.ResourcesMap["shoreline_action"]
+	d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{
+		"name":           name,
+		"command":        "`hostname`",
+		"adopt_existing": true,
+	})
+	diags := res.CreateContext(context.Background(), d, meta)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "isn't of type 'action'") {
+		t.Errorf("Expected adopting an object of another type to fail, got: %+v", diags)
+	}
+	if typ, _, _ := mockServer.Object(name); typ != "alarm" {
+		t.Errorf("Expected the existing alarm to be left alone, got type '%s'", typ)
+	}
+}
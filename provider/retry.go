@@ -0,0 +1,72 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	prand "math/rand"
+	"net"
+	"regexp"
+	"time"
+)
+
+// Delay before the first retry, doubled on each subsequent one (up to the max backoff).
+var retryBaseDelay = 500 * time.Millisecond
+
+const defaultRetryMaxBackoffSec = 30
+const defaultRetryDeadlineSec = 300
+
+// backend messages for transient conditions, e.g. "object is locked" or "server busy"
+var transientErrorRegex = regexp.MustCompile(`(?i)\b(busy|locked|try again later|temporarily unavailable)\b`)
+
+// isRetryableError separates transient failures (network errors, 5xx, 429, busy/locked)
+// from permanent ones (validation, permission, not-found, see BackendError), which fail immediately.
+func isRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	// cancelled by terraform (a per-request timeout is still retryable, see runOpCommand for the operation's own deadline)
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var backendErr *BackendError
+	if errors.As(err, &backendErr) {
+		switch backendErr.Kind {
+		case ErrNotFound, ErrAlreadyExists, ErrValidation, ErrUnauthorized, ErrPermissionDenied:
+			return false
+		}
+	}
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode == 429 || statusErr.StatusCode >= 500 {
+			return true
+		}
+		return transientErrorRegex.MatchString(statusErr.Message)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// errors reported in the body of a successful response (e.g. by CheckUpdateResult)
+	return transientErrorRegex.MatchString(err.Error())
+}
+
+// retryBackoff returns the delay before retry number 'attempt' (starting at 0).
+// The delay grows exponentially up to maxBackoff, with (equal) jitter so that
+// parallel resource operations don't retry in lock-step.
+func retryBackoff(attempt int, maxBackoff time.Duration) time.Duration {
+	ceiling := maxBackoff
+	if attempt < 32 {
+		exp := retryBaseDelay << uint(attempt)
+		if exp > 0 && exp < ceiling {
+			ceiling = exp
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	half := ceiling / 2
+	return half + time.Duration(prand.Int63n(int64(ceiling-half)+1))
+}
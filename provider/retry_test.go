@@ -0,0 +1,201 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider/mockbackend"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"server error", &HttpStatusError{StatusCode: 503, Message: "unavailable"}, true},
+		{"rate limited", &HttpStatusError{StatusCode: 429, Message: "slow down"}, true},
+		{"locked conflict", &HttpStatusError{StatusCode: 409, Message: "object 'foo' is locked"}, true},
+		{"bad request", &HttpStatusError{StatusCode: 400, Message: "invalid value for 'timeout'"}, false},
+		{"forbidden", &HttpStatusError{StatusCode: 403, Message: "permission denied"}, false},
+		{"not found", &HttpStatusError{StatusCode: 404, Message: "symbol 'foo' is not defined"}, false},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"wrapped network", &innerError{msg: "dial failed", err: &net.OpError{Op: "dial", Err: errors.New("refused")}}, true},
+		{"busy update", errors.New("ERROR: server busy, try again later"), true},
+		{"unlocked is not locked", errors.New("ERROR: attribute 'unlocked' is invalid"), false},
+		{"validation update", errors.New("ERROR: invalid value for 'timeout'"), false},
+	}
+	for _, tt := range tests {
+		if got := isRetryableError(tt.err); got != tt.want {
+			t.Errorf("%s: isRetryableError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	maxBackoff := 4 * time.Second
+	for attempt := 0; attempt < 40; attempt++ {
+		ceiling := maxBackoff
+		if attempt < 3 {
+			ceiling = retryBaseDelay << uint(attempt)
+		}
+		for i := 0; i < 20; i++ {
+			delay := retryBackoff(attempt, maxBackoff)
+			if delay < ceiling/2 || delay > ceiling {
+				t.Fatalf("retryBackoff(%d) = %s, expected within [%s, %s]", attempt, delay, ceiling/2, ceiling)
+			}
+		}
+	}
+	if delay := retryBackoff(0, 0); delay != 0 {
+		t.Errorf("Expected no delay with a zero max backoff, got %s", delay)
+	}
+}
+
+func testMockExecuteCount() int {
+	count := 0
+	for _, req := range mockServer.Requests() {
+		if req.Path == "/v1/execute" {
+			count++
+		}
+	}
+	return count
+}
+
+func testFastRetries(t *testing.T) {
+	saved := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = saved })
+}
+
+func TestMockRetryTransientFailures(t *testing.T) {
+	_, meta := testMockProviderWithConfig(t, map[string]interface{}{"retries": 3})
+	client := meta.(*apiClient)
+	testFastRetries(t)
+	mockServer.ResetRequests()
+	mockServer.InjectFault(
+		mockbackend.Fault{Path: "/v1/execute", Status: 503, Body: "service unavailable"},
+		mockbackend.Fault{Path: "/v1/execute", Status: 429, Body: "too many requests"},
+	)
+
+	if _, err := runOpCommand(context.Background(), client, "backend_version", false); err != nil {
+		t.Fatalf("Expected the command to succeed after retries, got: %s", err)
+	}
+	if count := testMockExecuteCount(); count != 3 {
+		t.Errorf("Expected 3 execute calls, got %d", count)
+	}
+}
+
+func TestMockRetryPermanentFailure(t *testing.T) {
+	_, meta := testMockProviderWithConfig(t, map[string]interface{}{"retries": 3})
+	client := meta.(*apiClient)
+	testFastRetries(t)
+	mockServer.ResetRequests()
+	mockServer.InjectFault(mockbackend.Fault{Path: "/v1/execute", Status: 400, Body: "invalid value for 'timeout'"})
+
+	_, err := runOpCommand(context.Background(), client, "backend_version", false)
+	if err == nil || err.Error() != "invalid value for 'timeout'" {
+		t.Fatalf("Expected the validation error, got: %v", err)
+	}
+	if count := testMockExecuteCount(); count != 1 {
+		t.Errorf("Expected a single execute call for a permanent error, got %d", count)
+	}
+}
+
+func TestMockRetryLimit(t *testing.T) {
+	_, meta := testMockProviderWithConfig(t, map[string]interface{}{"retries": 2})
+	client := meta.(*apiClient)
+	testFastRetries(t)
+	mockServer.ResetRequests()
+	for i := 0; i < 5; i++ {
+		mockServer.InjectFault(mockbackend.Fault{Path: "/v1/execute", Status: 502, Body: "bad gateway"})
+	}
+	defer mockServer.ResetFaults()
+
+	if _, err := runOpCommand(context.Background(), client, "backend_version", false); err == nil {
+		t.Fatalf("Expected an error once retries are exhausted")
+	}
+	if count := testMockExecuteCount(); count != 3 {
+		t.Errorf("Expected 3 execute calls (1 + 2 retries), got %d", count)
+	}
+}
+
+func TestMockRetryDeadline(t *testing.T) {
+	_, meta := testMockProviderWithConfig(t, map[string]interface{}{"retries": 10, "retry_deadline": 1})
+	client := meta.(*apiClient)
+	saved := retryBaseDelay
+	retryBaseDelay = 2 * time.Second
+	defer func() { retryBaseDelay = saved }()
+	mockServer.ResetRequests()
+	mockServer.InjectFault(mockbackend.Fault{Path: "/v1/execute", Status: 503, Body: "service unavailable"})
+	defer mockServer.ResetFaults()
+
+	start := time.Now()
+	if _, err := runOpCommand(context.Background(), client, "backend_version", false); err == nil {
+		t.Fatalf("Expected an error when the retry deadline is exceeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to give up before sleeping past the deadline, took %s", elapsed)
+	}
+	if count := testMockExecuteCount(); count != 1 {
+		t.Errorf("Expected a single execute call, got %d", count)
+	}
+}
+
+func TestRetryRateLimitedNoDeadline(t *testing.T) {
+	client := &apiClient{retryLimit: 0, retryMaxBackoff: time.Second, retryDeadline: 0}
+	testFastRetries(t)
+	err := &HttpStatusError{StatusCode: http.StatusTooManyRequests, Message: "slow down"}
+	// long past the default deadline, but zero means no limit
+	if !client.waitToRetry(context.Background(), 5, time.Now().Add(-time.Hour), err) {
+		t.Errorf("Expected rate limited requests to be retried without a deadline")
+	}
+	client.retryDeadline = time.Minute
+	if client.waitToRetry(context.Background(), 5, time.Now().Add(-time.Hour), err) {
+		t.Errorf("Expected rate limited requests to stop at the retry deadline")
+	}
+}
+
+func TestMockRetryCancelled(t *testing.T) {
+	_, meta := testMockProviderWithConfig(t, map[string]interface{}{"retries": 10})
+	client := meta.(*apiClient)
+	saved := retryBaseDelay
+	retryBaseDelay = 5 * time.Second
+	defer func() { retryBaseDelay = saved }()
+	mockServer.ResetRequests()
+	mockServer.InjectFault(mockbackend.Fault{Path: "/v1/execute", Status: 503, Body: "service unavailable"})
+	defer mockServer.ResetFaults()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := runOpCommand(ctx, client, "backend_version", false); err == nil {
+		t.Fatalf("Expected an error when the context is cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the backoff to stop on cancellation, took %s", elapsed)
+	}
+	if count := testMockExecuteCount(); count != 1 {
+		t.Errorf("Expected a single execute call, got %d", count)
+	}
+}
+
+func TestMockExecuteCancelledContext(t *testing.T) {
+	_, meta := testMockProviderWithConfig(t, map[string]interface{}{"retries": 3})
+	client := meta.(*apiClient)
+	testFastRetries(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := runOpCommand(ctx, client, "backend_version", false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a context.Canceled error, got: %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// the statuses of a run, and of its cells
+const (
+	runbookRunSucceeded = "SUCCEEDED"
+	runbookRunFailed    = "FAILED"
+	runbookRunTimeout   = "TIMEOUT"
+	// markdown and disabled cells
+	runbookRunSkipped = "SKIPPED"
+	// the cells after a failed one
+	runbookRunNotRun = "NOT_RUN"
+)
+
+// `$NAME` or `${NAME}` references to a runbook's params, in its op cells
+var runbookParamRefRegex = regexp.MustCompile(`\$(\w+)|\$\{(\w+)\}`)
+
+// ResourceShorelineRunbookRun runs the op cells of a runbook, in order, when it's created (e.g. a post-deploy check),
+// and fails the apply if any of them fails.
+// Changing any of its inputs (e.g. 'triggers') runs the runbook again.
+func ResourceShorelineRunbookRun() *schema.Resource {
+	return &schema.Resource{
+		Description:   "A run of a runbook, e.g. to verify a deploy. The runbook's cells are run (and waited for) when the resource is created, and again whenever its inputs (e.g. `triggers`) change.",
+		CreateContext: resourceShorelineRunbookRunCreate,
+		ReadContext:   resourceShorelineRunbookRunRead,
+		UpdateContext: resourceShorelineRunbookRunRead,
+		DeleteContext: resourceShorelineRunbookRunDelete,
+		Schema: map[string]*schema.Schema{
+			"runbook_name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringMatch(opIdentRegex, "must be an alphanumeric/underscore string, starting with a letter or underscore"),
+				Description:  "The name of the runbook to run, e.g. `shoreline_runbook.post_deploy_checks.name`.",
+			},
+			"resource_query": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validateResourceQuery,
+				Description:  "The resources to run the runbook on, e.g. `hosts | app=\"payments\"`. Each op cell is then run as `<resource_query> | <cell>`.",
+			},
+			"params": {
+				Type:         schema.TypeMap,
+				Optional:     true,
+				ForceNew:     true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				ValidateFunc: validateActionParams,
+				Description:  "The values of the runbook's params, overriding their defaults. They replace the `$NAME` (or `${NAME}`) references in the runbook's op cells.",
+			},
+			"triggers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Arbitrary values that run the runbook again when they change, e.g. a deployed version.",
+			},
+			"timeout_sec": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      600,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "How long to wait for the run to finish, before failing the apply.",
+			},
+			"run_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The ID of the run, also the idempotency key of its cells' requests.",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The status of the run: `SUCCEEDED`, `FAILED` or `TIMEOUT`.",
+			},
+			"cells": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The status (and output) of each of the runbook's cells.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name":   {Type: schema.TypeString, Computed: true, Description: "The name of the cell."},
+						"status": {Type: schema.TypeString, Computed: true, Description: "The status of the cell: `SUCCEEDED`, `FAILED`, `TIMEOUT`, `SKIPPED` (markdown or disabled cells) or `NOT_RUN` (after a failed cell)."},
+						"output": {Type: schema.TypeString, Computed: true, Description: "The (JSON) output of the cell, if the runbook's `is_run_output_persisted` is true."},
+					},
+				},
+			},
+		},
+	}
+}
+
+// runbookRunParams merges the configured params over the defaults of the runbook's params,
+// failing on undeclared params, and on required params without a value.
+func runbookRunParams(runbookJs map[string]interface{}, configured map[string]interface{}) (map[string]string, error) {
+	values := map[string]string{}
+	required := map[string]bool{}
+	paramsJs, _ := GetNestedValueOrDefault(runbookJs, ToKeyPath("params"), []interface{}{}).([]interface{})
+	for _, paramJs := range paramsJs {
+		name := CastToString(GetNestedValueOrDefault(paramJs, ToKeyPath("name"), ""))
+		if name == "" {
+			continue
+		}
+		values[name] = CastToString(GetNestedValueOrDefault(paramJs, ToKeyPath("value"), ""))
+		required[name] = CastToBool(GetNestedValueOrDefault(paramJs, ToKeyPath("required"), false))
+	}
+
+	undeclared := []string{}
+	for name, val := range configured {
+		if _, found := values[name]; !found {
+			undeclared = append(undeclared, name)
+			continue
+		}
+		values[name] = CastToString(val)
+	}
+	if len(undeclared) > 0 {
+		sort.Strings(undeclared)
+		return nil, fmt.Errorf("the runbook has no params named: %s", strings.Join(undeclared, ", "))
+	}
+
+	missing := []string{}
+	for name, val := range values {
+		if required[name] && val == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing values for the required params: %s", strings.Join(missing, ", "))
+	}
+	return values, nil
+}
+
+// runbookCellStatement returns the statement of an op cell, with its param references replaced by their values,
+// and run on the resources of 'resourceQuery' (if any).
+// References to names that aren't params (e.g. shell variables) are kept as is.
+func runbookCellStatement(content string, params map[string]string, resourceQuery string) string {
+	stmt := runbookParamRefRegex.ReplaceAllStringFunc(content, func(ref string) string {
+		m := runbookParamRefRegex.FindStringSubmatch(ref)
+		name := m[1] + m[2]
+		if val, found := params[name]; found {
+			return val
+		}
+		return ref
+	})
+	if resourceQuery != "" {
+		stmt = resourceQuery + " | " + stmt
+	}
+	return stmt
+}
+
+// runRunbookCell runs an op cell, returning its status and output, and a description of its failure (if any).
+func runRunbookCell(ctx context.Context, client *apiClient, statement string, name string) (string, string, string) {
+	result, err := runOpCommand(ctx, client, statement, true)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return runbookRunTimeout, "", "timed out"
+		}
+		return runbookRunFailed, "", annotateBackendError(err, statement, "notebook", name).Error()
+	}
+	js := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(result), &js); err != nil {
+		return runbookRunFailed, result, fmt.Sprintf("Failed to parse json from command '%s': %s", statement, err.Error())
+	}
+	if _, failed := parseActionRunResults(js); len(failed) > 0 {
+		return runbookRunFailed, result, "failed on " + strings.Join(failed, ", ")
+	}
+	return runbookRunSucceeded, result, ""
+}
+
+func resourceShorelineRunbookRunCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient)
+	name := d.Get("runbook_name").(string)
+	ctx = withResourceLogging(ctx, client, "runbook_run", name)
+	logInfo(ctx, logCrud, fmt.Sprintf("Running runbook: '%s'", name))
+
+	timeout := time.Duration(d.Get("timeout_sec").(int)) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	classJs, err := runOpCommandToJson(ctx, client, opGetClassStatement("notebook", name))
+	if err != nil {
+		return diag.Errorf("Failed to read runbook '%s': %s", name, err.Error())
+	}
+	runbookJs := getNamedObjectFromClassDef(name, "notebook", classJs)
+	if len(runbookJs) == 0 {
+		return diag.Errorf("Failed to run runbook '%s': it doesn't exist", name)
+	}
+	if !CastToBool(GetNestedValueOrDefault(runbookJs, ToKeyPath("enabled"), true)) {
+		return diag.Errorf("Failed to run runbook '%s': it's disabled", name)
+	}
+	params, err := runbookRunParams(runbookJs, d.Get("params").(map[string]interface{}))
+	if err != nil {
+		return diag.Errorf("Failed to run runbook '%s': %s", name, err.Error())
+	}
+	persisted := CastToBool(GetNestedValueOrDefault(runbookJs, ToKeyPath("is_run_output_persisted"), true))
+	resourceQuery := d.Get("resource_query").(string)
+
+	runID := GetIdempotencyKey()
+	status := runbookRunSucceeded
+	failure := ""
+	cells := []interface{}{}
+	cellsJs, _ := GetNestedValueOrDefault(runbookJs, ToKeyPath("cells"), []interface{}{}).([]interface{})
+	for i, cellJs := range cellsJs {
+		cellName := CastToString(GetNestedValueOrDefault(cellJs, ToKeyPath("name"), ""))
+		if cellName == "" {
+			cellName = fmt.Sprintf("cell_%d", i)
+		}
+		cell := map[string]interface{}{"name": cellName, "status": runbookRunSkipped, "output": ""}
+		cells = append(cells, cell)
+
+		isOp := CastToString(GetNestedValueOrDefault(cellJs, ToKeyPath("type"), "")) == "OP_LANG"
+		enabled := CastToBool(GetNestedValueOrDefault(cellJs, ToKeyPath("enabled"), true))
+		if status != runbookRunSucceeded {
+			if isOp && enabled {
+				cell["status"] = runbookRunNotRun
+			}
+			continue
+		}
+		if !isOp || !enabled {
+			continue
+		}
+
+		statement := runbookCellStatement(CastToString(GetNestedValueOrDefault(cellJs, ToKeyPath("content"), "")), params, resourceQuery)
+		logDebug(ctx, logCrud, fmt.Sprintf("Running cell %d of runbook '%s'", i, name))
+		// a key per cell (the same for any retries), so that each cell only runs once
+		cellStatus, output, cellFailure := runRunbookCell(withIdempotencyKey(ctx, fmt.Sprintf("%s-%d", runID, i)), client, statement, name)
+		cell["status"] = cellStatus
+		if persisted {
+			cell["output"] = output
+		}
+		if cellStatus != runbookRunSucceeded {
+			status = cellStatus
+			failure = fmt.Sprintf("cell %d (%s) %s: %s", i, cellName, cellStatus, cellFailure)
+		}
+	}
+
+	// saved even if the run fails (or times out), so that the next apply runs it again
+	d.SetId(runID)
+	d.Set("run_id", runID)
+	d.Set("status", status)
+	d.Set("cells", cells)
+	logInfo(ctx, logCrud, fmt.Sprintf("Run %s of runbook '%s' finished: %s", runID, name, status))
+
+	if status != runbookRunSucceeded {
+		return diag.Errorf("Run %s of runbook '%s' failed: %s", runID, name, failure)
+	}
+	return nil
+}
+
+// resourceShorelineRunbookRunRead keeps the state of a run, which is only read while it's created.
+// Updates (e.g. of 'timeout_sec') don't run it again either.
+func resourceShorelineRunbookRunRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return nil
+}
+
+// resourceShorelineRunbookRunDelete forgets a run, its cells' history is kept by the backend.
+func resourceShorelineRunbookRunDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*apiClient)
+	ctx = withResourceLogging(ctx, client, "runbook_run", d.Get("runbook_name").(string))
+	logInfo(ctx, logCrud, fmt.Sprintf("Removing run %s from the state", d.Id()))
+	d.SetId("")
+	return nil
+}
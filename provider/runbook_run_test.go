@@ -0,0 +1,185 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider/mockbackend"
+)
+
+// testRunbookRun creates a runbook with the check action in its cells (and a SERVICE param), and then a
+// shoreline_runbook_run of it against the payments hosts, returning the run's state and diagnostics.
+func testRunbookRun(t *testing.T, cells string, runbook map[string]interface{}, raw map[string]interface{}) (*schema.ResourceData, diag.Diagnostics) {
+	p, meta := testMockProvider(t)
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-1", Type: "HOST", Tags: map[string]string{"app": "payments"}})
+	mockServer.PutResource(mockbackend.Resource{Name: "ip-10-0-0-2", Type: "HOST", Tags: map[string]string{"app": "payments"}})
+	t.Cleanup(func() {
+		mockServer.ResetResources()
+		mockServer.ResetActionRuns()
+	})
+
+	rawRunbook := map[string]interface{}{
+		"name":   raw["runbook_name"],
+		"cells":  cells,
+		"params": `[{"name":"SERVICE","value":"nginx"},{"name":"REGION","value":"us-west-2","required":false}]`,
+	}
+	for k, v := range runbook {
+		rawRunbook[k] = v
+	}
+	testMockCreate(t, p, meta, "shoreline_runbook", rawRunbook)
+	mockServer.ResetRequests()
+
+	if raw["resource_query"] == nil {
+		raw["resource_query"] = `hosts | app="payments"`
+	}
+	res := p.ResourcesMap["shoreline_runbook_run"]
+	d := schema.TestResourceDataRaw(t, res.Schema, raw)
+	return d, res.CreateContext(context.Background(), d, meta)
+}
+
+func TestMockRunbookRun(t *testing.T) {
+	prefix := RandomAlphaPrefix(5)
+	check := prefix + "_check"
+	mockServer.PutObject("action", check, map[string]interface{}{"enabled": true})
+	mockServer.PutActionResult(check, "ip-10-0-0-1", mockbackend.ActionResult{Stdout: "healthy"})
+
+	cells := `[{"op":"` + check + `(service = \"$SERVICE\", region = \"${REGION}\", home = \"$HOME\")","name":"check"},{"md":"# Done"},{"op":"` + check + `()","enabled":false}]`
+	d, diags := testRunbookRun(t, cells, nil, map[string]interface{}{
+		"runbook_name": prefix + "_checks",
+		"params":       map[string]interface{}{"SERVICE": "payments"},
+		"triggers":     map[string]interface{}{"version": "1.2.3"},
+	})
+	if diags.HasError() {
+		t.Fatalf("Failed to run the runbook: %+v", diags)
+	}
+	if d.Id() == "" || d.Id() != d.Get("run_id") || d.Get("status") != "SUCCEEDED" {
+		t.Errorf("Unexpected run: %s, %v, %v", d.Id(), d.Get("run_id"), d.Get("status"))
+	}
+	if d.Get("cells.#") != 3 || d.Get("cells.0.name") != "check" || d.Get("cells.0.status") != "SUCCEEDED" ||
+		d.Get("cells.1.status") != "SKIPPED" || d.Get("cells.2.status") != "SKIPPED" {
+		t.Errorf("Unexpected cells: %+v", d.Get("cells"))
+	}
+	if !strings.Contains(d.Get("cells.0.output").(string), `"healthy"`) {
+		t.Errorf("Expected the cell's output, got: %s", d.Get("cells.0.output"))
+	}
+	// the configured param overrides its default, the other params keep theirs, and other references are kept as is
+	want := `hosts | app="payments" | ` + check + `(service = "payments", region = "us-west-2", home = "$HOME")`
+	if !containsString(mockServer.Statements(), want) {
+		t.Errorf("Expected the statement %s, got: %v", want, mockServer.Statements())
+	}
+	if mockServer.ActionRuns() != 1 {
+		t.Errorf("Expected only the enabled op cell to run, got %d runs", mockServer.ActionRuns())
+	}
+}
+
+func TestMockRunbookRunFailedCell(t *testing.T) {
+	prefix := RandomAlphaPrefix(5)
+	check := prefix + "_check"
+	mockServer.PutObject("action", check, map[string]interface{}{"enabled": true})
+	mockServer.PutActionResult(check, "ip-10-0-0-2", mockbackend.ActionResult{ExitCode: 2, Stderr: "unhealthy"})
+
+	cells := `[{"op":"` + check + `()","name":"first"},{"md":"# Then"},{"op":"` + check + `()","name":"second"}]`
+	d, diags := testRunbookRun(t, cells, nil, map[string]interface{}{"runbook_name": prefix + "_checks"})
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "cell 0 (first) FAILED") || !strings.Contains(diags[0].Summary, "ip-10-0-0-2 (exit code 2)") {
+		t.Fatalf("Expected the failed cell, got: %+v", diags)
+	}
+	// saved (as tainted), so that the next apply runs it again
+	if d.Id() == "" || d.Get("status") != "FAILED" {
+		t.Errorf("Expected the failed run to be saved, got: %s, %v", d.Id(), d.Get("status"))
+	}
+	if d.Get("cells.0.status") != "FAILED" || d.Get("cells.1.status") != "SKIPPED" || d.Get("cells.2.status") != "NOT_RUN" {
+		t.Errorf("Unexpected cells: %+v", d.Get("cells"))
+	}
+	if !strings.Contains(d.Get("cells.0.output").(string), `"unhealthy"`) {
+		t.Errorf("Expected the failed cell's output, got: %s", d.Get("cells.0.output"))
+	}
+	if mockServer.ActionRuns() != 1 {
+		t.Errorf("Expected the cells after the failed one not to run, got %d runs", mockServer.ActionRuns())
+	}
+}
+
+func TestMockRunbookRunStatementError(t *testing.T) {
+	prefix := RandomAlphaPrefix(5)
+	check := prefix + "_check"
+	mockServer.PutObject("action", check, map[string]interface{}{"enabled": true})
+	mockServer.FailStatements(check+`\(\)`, "resource query timed out")
+	t.Cleanup(mockServer.ResetFaults)
+
+	d, diags := testRunbookRun(t, `[{"op":"`+check+`()"}]`, nil, map[string]interface{}{"runbook_name": prefix + "_checks"})
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "resource query timed out") {
+		t.Fatalf("Expected the statement's error, got: %+v", diags)
+	}
+	if d.Id() == "" || d.Get("status") != "FAILED" || d.Get("cells.0.name") != "unnamed" || d.Get("cells.0.status") != "FAILED" {
+		t.Errorf("Unexpected run: %s, %v, %+v", d.Id(), d.Get("status"), d.Get("cells"))
+	}
+}
+
+func TestMockRunbookRunOutputNotPersisted(t *testing.T) {
+	prefix := RandomAlphaPrefix(5)
+	check := prefix + "_check"
+	mockServer.PutObject("action", check, map[string]interface{}{"enabled": true})
+	mockServer.PutActionResult(check, "ip-10-0-0-1", mockbackend.ActionResult{Stdout: "healthy"})
+
+	d, diags := testRunbookRun(t, `[{"op":"`+check+`()"}]`, map[string]interface{}{"is_run_output_persisted": false}, map[string]interface{}{"runbook_name": prefix + "_checks"})
+	if diags.HasError() {
+		t.Fatalf("Failed to run the runbook: %+v", diags)
+	}
+	if d.Get("cells.0.status") != "SUCCEEDED" || d.Get("cells.0.output") != "" {
+		t.Errorf("Expected the cell's output not to be kept, got: %+v", d.Get("cells"))
+	}
+}
+
+func TestMockRunbookRunParamErrors(t *testing.T) {
+	tests := map[string]struct {
+		params map[string]interface{}
+		want   string
+	}{
+		"undeclared": {map[string]interface{}{"SERVICE": "payments", "VERSION": "1.2.3"}, "no params named: VERSION"},
+		"required":   {map[string]interface{}{"SERVICE": ""}, "required params: SERVICE"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			prefix := RandomAlphaPrefix(5)
+			d, diags := testRunbookRun(t, `[{"op":"hostname"}]`, nil, map[string]interface{}{"runbook_name": prefix + "_checks", "params": tc.params})
+			if !diags.HasError() || !strings.Contains(diags[0].Summary, tc.want) {
+				t.Fatalf("Expected '%s', got: %+v", tc.want, diags)
+			}
+			if d.Id() != "" || len(mockServer.Statements()) != 1 {
+				t.Errorf("Expected nothing to run, got: %s, %v", d.Id(), mockServer.Statements())
+			}
+		})
+	}
+}
+
+func TestMockRunbookRunMissingRunbook(t *testing.T) {
+	p, meta := testMockProvider(t)
+	res := p.ResourcesMap["shoreline_runbook_run"]
+	d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{"runbook_name": RandomAlphaPrefix(5) + "_missing"})
+	diags := res.CreateContext(context.Background(), d, meta)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "doesn't exist") || d.Id() != "" {
+		t.Errorf("Expected the missing runbook, got: %s, %+v", d.Id(), diags)
+	}
+}
+
+func TestRunbookRunCellStatement(t *testing.T) {
+	params := map[string]string{"SERVICE": "nginx", "S": "short"}
+	tests := []struct {
+		content, resourceQuery, want string
+	}{
+		{"hostname", "", "hostname"},
+		{`restart(service = "$SERVICE")`, `hosts | app="a"`, `hosts | app="a" | restart(service = "nginx")`},
+		{"`echo ${S}_x $S $SHELL`", "", "`echo short_x short $SHELL`"},
+	}
+	for _, tc := range tests {
+		if got := runbookCellStatement(tc.content, params, tc.resourceQuery); got != tc.want {
+			t.Errorf("runbookCellStatement(%q) = %q, want %q", tc.content, got, tc.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -11,10 +12,10 @@ import (
 )
 
 // buildRunbookDataObject builds a JSON containing the core runbook data. expects at least "cells" to be present
-func buildRunbookDataObject(d *schema.ResourceData, cells interface{}) (interface{}, error) {
+func buildRunbookDataObject(ctx context.Context, client *apiClient, d *schema.ResourceData, cells interface{}) (interface{}, error) {
 	runbookData := map[string]interface{}{}
 
-	cellsData, err := buildCellsData(cells)
+	cellsData, err := buildCellsData(ctx, client, cells)
 	if err != nil {
 		return nil, err
 	}
@@ -22,17 +23,17 @@ func buildRunbookDataObject(d *schema.ResourceData, cells interface{}) (interfac
 
 	// TODO this should be passed in, it might not always come from ResourceData
 	params, exists := d.GetOk("params")
-	appendActionLog(fmt.Sprintf("calling buildParametersData (exists:%v) from: %v\n", exists, params))
+	logDebug(ctx, logCrud, fmt.Sprintf("calling buildParametersData (exists:%v)", exists))
 	if exists {
 		params = CastToObject(params)
 	}
-	paramsData, err := buildParametersData(params, exists)
+	paramsData, err := buildParametersData(ctx, params, exists)
 	if err != nil {
 		return nil, err
 	}
 	runbookData["params"] = paramsData
 
-	externalParametersData, err := buildExternalParametersData(d)
+	externalParametersData, err := buildExternalParametersData(ctx, d)
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +53,7 @@ func buildRunbookDataObject(d *schema.ResourceData, cells interface{}) (interfac
 	return string(encodedRunbookData), nil
 }
 
-func buildCellsData(cells interface{}) (interface{}, error) {
+func buildCellsData(ctx context.Context, client *apiClient, cells interface{}) (interface{}, error) {
 
 	var decodedCells []interface{}
 	if cellsList, ok := cells.([]interface{}); ok {
@@ -62,7 +63,7 @@ func buildCellsData(cells interface{}) (interface{}, error) {
 	}
 	cellsData := []interface{}{}
 
-	appendActionLog(fmt.Sprintf("building runbook cells from: %v\n", cells))
+	logDebug(ctx, logCrud, fmt.Sprintf("building runbook cells from: %d cells", len(CastToArray(cells))))
 
 	for _, cell := range decodedCells {
 		markdownContent := GetNestedValueOrDefault(cell, ToKeyPath("md"), nil)
@@ -86,7 +87,7 @@ func buildCellsData(cells interface{}) (interface{}, error) {
 
 		secretAware := GetNestedValueOrDefault(cell, ToKeyPath("secret_aware"), nil)
 
-		cellContent, err := GetCellContent(markdownContent, oplangContent, enabled, secretAware, name)
+		cellContent, err := GetCellContent(ctx, client, markdownContent, oplangContent, enabled, secretAware, name)
 		if err != nil {
 			return nil, err
 		}
@@ -97,7 +98,7 @@ func buildCellsData(cells interface{}) (interface{}, error) {
 	return cellsData, nil
 }
 
-func GetCellContent(markdownContent interface{}, oplangContent interface{}, enabled bool, secretAware interface{}, name string) (map[string]interface{}, error) {
+func GetCellContent(ctx context.Context, client *apiClient, markdownContent interface{}, oplangContent interface{}, enabled bool, secretAware interface{}, name string) (map[string]interface{}, error) {
 	var cellContent map[string]interface{}
 	if markdownContent != nil {
 		if _, ok := markdownContent.(string); !ok {
@@ -124,7 +125,7 @@ func GetCellContent(markdownContent interface{}, oplangContent interface{}, enab
 	}
 
 	// Only add secret_aware if the backend version supports it
-	backendVersion := GetBackendVersionInfoStruct()
+	backendVersion := GetBackendVersionInfoStruct(ctx, client)
 	if IsSecretAwareSupported(backendVersion) {
 		if secretAware != nil {
 			if _, ok := secretAware.(bool); !ok {
@@ -138,8 +139,8 @@ func GetCellContent(markdownContent interface{}, oplangContent interface{}, enab
 	return cellContent, nil
 }
 
-func buildParametersData(params interface{}, exists bool) ([]interface{}, error) {
-	appendActionLog(fmt.Sprintf("building runbook params (exists:%v) from: %v\n", exists, params))
+func buildParametersData(ctx context.Context, params interface{}, exists bool) ([]interface{}, error) {
+	logDebug(ctx, logCrud, fmt.Sprintf("building runbook params (exists:%v) from: %d params", exists, len(CastToArray(params))))
 
 	paramsOut := []interface{}{}
 	paramsArray, ok := params.([]interface{})
@@ -172,12 +173,12 @@ func buildParametersData(params interface{}, exists bool) ([]interface{}, error)
 	return paramsOut, nil
 }
 
-func buildExternalParametersData(d *schema.ResourceData) ([]interface{}, error) {
+func buildExternalParametersData(ctx context.Context, d *schema.ResourceData) ([]interface{}, error) {
 	var decodedExternalParameters []interface{}
 	externalParametersData := []interface{}{}
 	externalParameters, exists := d.GetOk("external_params")
 
-	appendActionLog(fmt.Sprintf("building runbook external params from: %v\n", externalParameters))
+	logDebug(ctx, logCrud, fmt.Sprintf("building runbook external params from: %d bytes", len(CastToString(externalParameters))))
 
 	if !exists {
 		return []interface{}{}, nil
@@ -0,0 +1,234 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// All op statements sent to the backend are rendered with the helpers below, so that attribute
+// values (e.g. a description or a system setting) are always literals, and can't change the statement.
+
+var (
+	opIdentRegex    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	opDurationRegex = regexp.MustCompile(`^[0-9]+[smhd]?$`)
+)
+
+// opIdent renders an object, field or type name.
+// Names are validated at plan time (see the "label" attributes); anything else (e.g. an imported ID)
+// is rendered as a string literal, which the backend rejects instead of running.
+func opIdent(name string) string {
+	if opIdentRegex.MatchString(name) {
+		return name
+	}
+	return opString(name)
+}
+
+// opString renders a double-quoted string literal, escaping quotes, backslashes and control characters.
+func opString(val interface{}) string {
+	if val == nil {
+		return `""`
+	}
+	return strconv.Quote(CastToString(val))
+}
+
+// opStringList renders a list of string literals, e.g. [ "a", "b" ].
+func opStringList(val interface{}) string {
+	items := []string{}
+	if valArr, isArr := val.([]interface{}); isArr {
+		for _, v := range valArr {
+			items = append(items, opString(v))
+		}
+	}
+	return "[ " + strings.Join(items, ", ") + " ]"
+}
+
+func opBool(val interface{}) string {
+	return strconv.FormatBool(ForceToBool(val))
+}
+
+// opInt renders an integer, non-numeric values are rendered as 0.
+func opInt(val interface{}) string {
+	switch v := val.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatInt(int64(v), 10)
+	case string:
+		if i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
+	case bool:
+		return strconv.Itoa(ConvertBoolInt(v))
+	}
+	return "0"
+}
+
+// opFloat renders a decimal number, non-numeric values are rendered as 0.
+func opFloat(val interface{}) string {
+	switch v := val.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	case int:
+		return strconv.FormatFloat(float64(v), 'f', 6, 64)
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return strconv.FormatFloat(f, 'f', 6, 64)
+		}
+	}
+	return strconv.FormatFloat(0, 'f', 6, 64)
+}
+
+// opDuration renders a time with an (optional) unit suffix, e.g. 5m (see timeSuffixToIntSec()).
+// Anything else is rendered as a string literal.
+func opDuration(val interface{}) string {
+	str := strings.TrimSpace(CastToString(val))
+	if opDurationRegex.MatchString(str) {
+		return str
+	}
+	return opString(str)
+}
+
+// opBase64 renders a string as a base64 encoded string literal (e.g. JSON payloads).
+func opBase64(val interface{}) string {
+	str, isStr := val.(string)
+	if !isStr {
+		str = ""
+	}
+	return strconv.Quote(base64.StdEncoding.EncodeToString([]byte(str)))
+}
+
+// opJson renders a JSON encoded value as an op literal, re-encoding it so that its strings are
+// always escaped. Values that aren't valid JSON are rendered as a string literal.
+func opJson(val interface{}) string {
+	str := CastToString(val)
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(str), &parsed); err != nil {
+		return opString(str)
+	}
+	encoded, err := json.Marshal(parsed)
+	if err != nil {
+		return opString(str)
+	}
+	return string(encoded)
+}
+
+// opValue renders a (system setting) value according to its Go type.
+func opValue(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return opString(v)
+	case int, int64:
+		return opInt(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return opBool(v)
+	case []interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return opStringList(v)
+		}
+		return string(encoded)
+	}
+	return opString(fmt.Sprintf("%v", val))
+}
+
+// opDefineStatement creates an object, e.g. `action a1 = "..."`.
+func opDefineStatement(typ string, name string, valStr string) string {
+	return fmt.Sprintf("%s %s = %s", opIdent(typ), opIdent(name), valStr)
+}
+
+// opSetFieldStatement sets an object field, e.g. `a1.description = "..."`.
+func opSetFieldStatement(name string, field string, valStr string) string {
+	return fmt.Sprintf("%s.%s = %s", opIdent(name), opIdent(field), valStr)
+}
+
+// opGetFieldStatement reads an object field, e.g. `f1.uri`.
+func opGetFieldStatement(name string, field string) string {
+	return fmt.Sprintf("%s.%s", opIdent(name), opIdent(field))
+}
+
+// opListStatement lists the objects of a type with a name.
+func opListStatement(typ string, name string) string {
+	return fmt.Sprintf("list %ss | name = %s", opIdent(typ), opString(name))
+}
+
+// opListAllStatement lists all the objects of a type.
+func opListAllStatement(typ string) string {
+	return fmt.Sprintf("list %ss", opIdent(typ))
+}
+
+// opGetClassStatement reads the full definition of an object.
+func opGetClassStatement(typ string, name string) string {
+	return fmt.Sprintf("get_%s_class( %s_name = %s )", opIdent(typ), opIdent(typ), opString(name))
+}
+
+// opEnableStatement enables (or disables) an object.
+func opEnableStatement(enable bool, name string) string {
+	act := "enable"
+	if !enable {
+		act = "disable"
+	}
+	return fmt.Sprintf("%s %s", act, opIdent(name))
+}
+
+func opDeleteStatement(name string) string {
+	return fmt.Sprintf("delete %s", opIdent(name))
+}
+
+// opUpdateConfigurationStatement updates a configuration (e.g. the system settings), with the
+// settings in a stable (sorted) order.
+func opUpdateConfigurationStatement(settings map[string]interface{}) string {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := make([]string, 0, len(keys))
+	for _, k := range keys {
+		args = append(args, opIdent(k)+"="+opValue(settings[k]))
+	}
+	return "update_configuration(" + strings.Join(args, ", ") + ")"
+}
+
+// opMetricQueryStatement queries a metric, by name (e.g. `metric_query(metric_names = "cpu_usage")`) or by a
+// (validated) metric query, e.g. `cpu_usage | app="a" | window(1h)`, optionally of the resources matched by a
+// (validated) resource query (e.g. `hosts | app="a" | metric_query(...)`) and over a window (e.g. "1h").
+func opMetricQueryStatement(resourceQuery string, metric string, window string) string {
+	stmt := metric
+	if opIdentRegex.MatchString(metric) {
+		stmt = fmt.Sprintf("metric_query(metric_names = %s)", opString(metric))
+	}
+	if resourceQuery != "" {
+		stmt = resourceQuery + " | " + stmt
+	}
+	if window != "" {
+		stmt += fmt.Sprintf(" | window(%s)", opDuration(window))
+	}
+	return stmt
+}
+
+// opActionRunStatement runs an action on the resources matched by a (validated) resource query, with its
+// params in a stable (sorted) order, e.g. `hosts | app="a" | reload_config(service = "nginx")`.
+func opActionRunStatement(resourceQuery string, action string, params map[string]interface{}) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := make([]string, 0, len(keys))
+	for _, k := range keys {
+		args = append(args, opIdent(k)+" = "+opString(params[k]))
+	}
+	return fmt.Sprintf("%s | %s(%s)", resourceQuery, opIdent(action), strings.Join(args, ", "))
+}
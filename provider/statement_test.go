@@ -0,0 +1,171 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestStatementBuilder(t *testing.T) {
+	tests := map[string]string{
+		opString(`say "hi"` + "\n"):                    `"say \"hi\"\n"`,
+		opString(nil):                                  `""`,
+		opStringList([]interface{}{"a", nil, `b"c`}):   `[ "a", "", "b\"c" ]`,
+		opStringList(nil):                              `[  ]`,
+		opIdent("a1_b"):                                `a1_b`,
+		opIdent("a1; delete b2"):                       `"a1; delete b2"`,
+		opDuration("5m"):                               `5m`,
+		opDuration("5m | delete a1"):                   `"5m | delete a1"`,
+		opInt(7):                                       `7`,
+		opInt("7; delete a1"):                          `0`,
+		opFloat(1.5):                                   `1.500000`,
+		opBool("true"):                                 `true`,
+		opBase64(`{}`):                                 `"e30="`,
+		opJson(`[{"name": "g\"1", "tags": ["t"]}]`):    `[{"name":"g\"1","tags":["t"]}]`,
+		opJson(`[1] ; delete a1`):                      `"[1] ; delete a1"`,
+		opSetFieldStatement("a1", "description", `""`): `a1.description = ""`,
+		opListStatement("action", `a1" | delete "b2`):  `list actions | name = "a1\" | delete \"b2"`,
+		opGetClassStatement("action", "a1"):            `get_action_class( action_name = "a1" )`,
+		opEnableStatement(false, "a1"):                 `disable a1`,
+		opActionRunStatement("hosts", "a1", map[string]interface{}{"v": `1") | delete a1 | x("`, "b c": "y"}): `hosts | a1("b c" = "y", v = "1\") | delete a1 | x(\"")`,
+		opMetricQueryStatement("", "cpu_usage", "1h"):                                                         `metric_query(metric_names = "cpu_usage") | window(1h)`,
+		opMetricQueryStatement("", `cpu_usage | app="a"`, `1h) | delete a1`):                                  `cpu_usage | app="a" | window("1h) | delete a1")`,
+		opMetricQueryStatement(`hosts | app="a"`, "cpu_usage", ""):                                            `hosts | app="a" | metric_query(metric_names = "cpu_usage")`,
+		opUpdateConfigurationStatement(map[string]interface{}{
+			"name":    `x", admin=true, y="`,
+			"enabled": true,
+			"count":   3,
+			"tags":    []interface{}{"a"},
+		}): `update_configuration(count=3, enabled=true, name="x\", admin=true, y=\"", tags=["a"])`,
+	}
+	for got, want := range tests {
+		if got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	}
+}
+
+// opStatementTokens lexes a (valid) statement into its token texts.
+func opStatementTokens(t *testing.T, stmt string) []string {
+	if err := ValidateOpStatement(stmt); err != nil {
+		t.Fatalf("Expected a valid statement, got: %s: %s", stmt, err)
+	}
+	tokens, _ := lexOpStatement(stmt)
+	texts := []string{}
+	for _, tok := range tokens {
+		texts = append(texts, tok.text)
+	}
+	return texts
+}
+
+func FuzzOpString(f *testing.F) {
+	for _, seed := range []string{"", "plain", `"`, `\`, "`hostname`", `a" ; delete b ; "`, "line\nbreak", "\x00\xff", "ünïcode"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, val string) {
+		lit := opString(val)
+		if unquoted, err := strconv.Unquote(lit); err != nil || unquoted != val {
+			t.Fatalf("Expected %s to unquote to %q, got %q (%v)", lit, val, unquoted, err)
+		}
+		tokens := opStatementTokens(t, opSetFieldStatement("a1", "description", lit))
+		if want := []string{"a1.description", "=", lit}; !reflect.DeepEqual(tokens, want) {
+			t.Fatalf("Expected the value to be a single string, got: %q", tokens)
+		}
+	})
+}
+
+func FuzzOpIdent(f *testing.F) {
+	for _, seed := range []string{"a1", "_a", "1a", "a-b", "a1; delete b2", "a.b", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		ident := opIdent(name)
+		if ident != name {
+			if unquoted, err := strconv.Unquote(ident); err != nil || unquoted != name {
+				t.Fatalf("Expected %q to be rendered as a string literal, got %s", name, ident)
+			}
+		}
+		tokens := opStatementTokens(t, opDeleteStatement(name))
+		if want := []string{"delete", ident}; !reflect.DeepEqual(tokens, want) {
+			t.Fatalf("Expected the name to be a single token, got: %q", tokens)
+		}
+	})
+}
+
+func FuzzOpStringList(f *testing.F) {
+	f.Add("a", `b"c`)
+	f.Add("]", `", "`)
+	f.Fuzz(func(t *testing.T, a string, b string) {
+		tokens := opStatementTokens(t, opSetFieldStatement("a1", "tags", opStringList([]interface{}{a, b})))
+		if len(tokens) != 7 {
+			t.Fatalf("Expected a list of 2 strings, got: %q", tokens)
+		}
+		for i, val := range []string{a, b} {
+			if unquoted, err := strconv.Unquote(tokens[3+2*i]); err != nil || unquoted != val {
+				t.Fatalf("Expected item %d to be %q, got: %q", i, val, tokens)
+			}
+		}
+	})
+}
+
+func FuzzOpJson(f *testing.F) {
+	for _, seed := range []string{`[]`, `[{"name": "g1", "tags": ["a", "b"]}]`, `["a\"b"]`, `[1] ; delete a1`, `{"a": "` + "`x`" + `"}`, ``} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, val string) {
+		lit := opJson(val)
+		var parsed interface{}
+		if json.Unmarshal([]byte(val), &parsed) == nil {
+			var reparsed interface{}
+			if err := json.Unmarshal([]byte(lit), &reparsed); err != nil || !reflect.DeepEqual(parsed, reparsed) {
+				t.Fatalf("Expected %s to encode %s", lit, val)
+			}
+		} else if unquoted, err := strconv.Unquote(lit); err != nil || unquoted != val {
+			t.Fatalf("Expected %q to be rendered as a string literal, got %s", val, lit)
+		}
+		// whatever the value, the statement is a single assignment
+		for _, tok := range opStatementTokens(t, opSetFieldStatement("d1", "groups", lit)) {
+			if tok == "|" || tok == ";" || tok == "`" {
+				t.Fatalf("Unexpected %q in the statement for %q", tok, val)
+			}
+		}
+	})
+}
+
+func FuzzOpUpdateConfiguration(f *testing.F) {
+	f.Add("Env", "#673ab7")
+	f.Add(`x", admin=true, y="`, `)`)
+	f.Fuzz(func(t *testing.T, a string, b string) {
+		stmt := opUpdateConfigurationStatement(map[string]interface{}{"configuration_name": "system_settings", "a": a, "b": b})
+		tokens := opStatementTokens(t, stmt)
+		want := []string{"update_configuration", "(", "a", "=", opString(a), ",", "b", "=", opString(b), ",", "configuration_name", "=", `"system_settings"`, ")"}
+		if !reflect.DeepEqual(tokens, want) {
+			t.Fatalf("Expected 3 settings, got: %q", tokens)
+		}
+	})
+}
+
+func TestMockStatementQuoting(t *testing.T) {
+	p, meta := testMockProvider(t)
+	name := RandomAlphaPrefix(5) + "_action"
+	description := `quoted "description" \ with a; delete ` + name + "\nand a newline"
+
+	testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":        name,
+		"command":     "`hostname`",
+		"description": description,
+	})
+	_, attrs, found := mockServer.Object(name)
+	if !found || attrs["description"] != description {
+		t.Errorf("Expected the description to be set verbatim, got: %v", attrs)
+	}
+	for _, stmt := range mockServer.Statements() {
+		if stmt == "delete "+name {
+			t.Errorf("Unexpected statement: %s", stmt)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package tests
+
+import (
+	"testing"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider"
+)
+
+func TestValidateApiUrl(t *testing.T) {
+	testCases := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://acme.us.api.shoreline-acme.io", true},
+		{"http://localhost:8080", true},
+		{"https://gateway.acme.io/shoreline", true},
+		{"https://gateway.acme.io/tools/shoreline-api/", true},
+		{"ftp://acme.io", false},
+		{"https://acme.io/path with spaces", false},
+		{"https://acme.io?query=1", false},
+		{"acme.io", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			if got := provider.ValidateApiUrl(tc.url); got != tc.valid {
+				t.Errorf("ValidateApiUrl(%q) = %v, want %v", tc.url, got, tc.valid)
+			}
+		})
+	}
+}
+
+func TestJoinApiBasePath(t *testing.T) {
+	testCases := []struct {
+		url      string
+		basePath string
+		expected string
+	}{
+		{"https://acme.io", "", "https://acme.io"},
+		{"https://acme.io/", "", "https://acme.io"},
+		{"https://acme.io", "shoreline", "https://acme.io/shoreline"},
+		{"https://acme.io/", "/shoreline/", "https://acme.io/shoreline"},
+		{"https://acme.io/tools", "/api/v2", "https://acme.io/tools/api/v2"},
+	}
+
+	for _, tc := range testCases {
+		if got := provider.JoinApiBasePath(tc.url, tc.basePath); got != tc.expected {
+			t.Errorf("JoinApiBasePath(%q, %q) = %q, want %q", tc.url, tc.basePath, got, tc.expected)
+		}
+	}
+}
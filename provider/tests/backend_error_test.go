@@ -0,0 +1,73 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider"
+)
+
+func TestCheckUpdateResult(t *testing.T) {
+	testCases := []struct {
+		name    string
+		result  string
+		kind    error
+		objType string
+		message string
+	}{
+		{"success", `{"define_action": {"name": "a1"}}`, nil, "", ""},
+		{"other result", `{"get_backend_version": "{}"}`, nil, "", ""},
+		{"already exists", `{"define_action": {"error": {"message": "symbol 'a1' already exists (action)"}}}`, provider.ErrAlreadyExists, "action", "symbol 'a1' already exists (action)"},
+		{"error code", `{"update_time_trigger": {"error": {"code": "PERMISSION_DENIED", "message": "nope"}}}`, provider.ErrPermissionDenied, "time_trigger", "nope"},
+		{"nested message", `{"update_bot": {"error": {"message": "rpc error: message: \\\"invalid value for 'timeout'\\\""}}}`, nil, "bot", "invalid value for 'timeout'"},
+		{"validation errors", `{"update_notebook": {"error": {"validation_errors": [{"message": "bad cell"}, {"message": "bad param"}]}}}`, provider.ErrValidation, "notebook", "bad cell\nbad param"},
+		{"statement error", `{"execute_statement_errors": [{"errors": ["symbol 'a1' is not defined"]}]}`, provider.ErrNotFound, "", "symbol 'a1' is not defined"},
+		{"unknown error", `{"delete_alarm": {"error": {"message": "timeout must be positive"}}}`, nil, "alarm", "timeout must be positive"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := provider.CheckUpdateResult(tc.result)
+			if tc.message == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %s", err)
+				}
+				return
+			}
+			var backendErr *provider.BackendError
+			if !errors.As(err, &backendErr) {
+				t.Fatalf("Expected a BackendError, got: %v", err)
+			}
+			if backendErr.Kind != tc.kind || (tc.kind != nil && !errors.Is(err, tc.kind)) {
+				t.Errorf("Expected kind %v, got %v", tc.kind, backendErr.Kind)
+			}
+			if backendErr.ObjectType != tc.objType || backendErr.Message != tc.message {
+				t.Errorf("Unexpected error fields: %+v", backendErr)
+			}
+			if err.Error() != "ERROR: "+tc.message+".\n" {
+				t.Errorf("Unexpected error string: %q", err.Error())
+			}
+		})
+	}
+}
+
+func TestCheckUpdateResultInvalidJson(t *testing.T) {
+	err := provider.CheckUpdateResult("not json")
+	var backendErr *provider.BackendError
+	if err == nil || errors.As(err, &backendErr) {
+		t.Errorf("Expected a (non-backend) parse error, got: %v", err)
+	}
+}
+
+func TestBackendErrorKinds(t *testing.T) {
+	err := &provider.BackendError{Message: "gone", Kind: provider.ErrNotFound}
+	if !errors.Is(err, provider.ErrNotFound) || errors.Is(err, provider.ErrAlreadyExists) {
+		t.Errorf("Expected only ErrNotFound to match")
+	}
+	if errors.Is(&provider.BackendError{Message: "unknown"}, provider.ErrNotFound) {
+		t.Errorf("Expected an unclassified error not to match")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider"
+)
+
+func TestValidateOpStatementValid(t *testing.T) {
+	statements := []string{
+		"",
+		"host",
+		"hosts",
+		"every 5m",
+		"cpu_usage + 4",
+		"host | pod | app='bookstore'",
+		"host | limit=$limit | az=$az",
+		"(cpu_usage > 1 | sum(5)) >= 2.75",
+		"cpu_threshold_action(cpu_threshold=75) == 1",
+		"if alarm_a then action_b('/tmp') fi",
+		"if alarm_a then action_b(JVM_PROCESS_REGEX='java', S3_BUCKET='bucket') fi",
+		"if alarm_a then action_b else action_c fi",
+		"if (cpu_usage > 1 | sum(5)) >= 2 then host | action_b fi",
+		"host | action_b",
+		"`ls ${dir}; export FOO='bar'`",
+		"`if [ $[100-$(vmstat 1 2|tail -1|awk '{print $15}')] -gt $cpu_threshold ]; then exit 1; fi`",
+		"`echo \"it's \\` quoted\"`",
+		`host | name =~ "ip-10-.*" | limit=1`,
+		"host | pod | app in [\"a\", \"b\"]",
+	}
+	for _, stmt := range statements {
+		if err := provider.ValidateOpStatement(stmt); err != nil {
+			t.Errorf("Expected %q to be valid, got: %s", stmt, err)
+		}
+	}
+}
+
+func TestValidateOpStatementInvalid(t *testing.T) {
+	testCases := []struct {
+		stmt    string
+		column  int
+		message string
+	}{
+		{"host | app='bookstore", 12, "unterminated string"},
+		{`action_b("/tmp)`, 10, "unterminated string"},
+		{"`ls -l", 1, "unterminated shell command"},
+		{"(cpu_usage > 1 | sum(5) >= 2.75", 1, "unbalanced '('"},
+		{"cpu_usage > 1)", 14, "unbalanced ')'"},
+		{"action_b(x=[1, 2)]", 17, "doesn't match the '[' at column 12"},
+		{"| host", 1, "missing expression before '|'"},
+		{"host | | pod", 8, "missing expression before '|'"},
+		{"host | pod |", 12, "missing expression after '|'"},
+		{"(cpu_usage |) > 1", 12, "missing expression after '|'"},
+		{"f(host |, 2)", 8, "missing expression after '|'"},
+		{"if alarm_a then action_b", 1, "'if' is missing 'fi'"},
+		{"if alarm_a action_b fi", 21, "'fi' before 'then'"},
+		{"if alarm_a", 1, "'if' is missing 'then'"},
+		{"alarm_a then action_b fi", 9, "'then' without a matching 'if'"},
+		{"action_b fi", 10, "'fi' without a matching 'if'"},
+		{"if then action_b fi", 4, "missing condition"},
+		{"if alarm_a then fi", 17, "missing statement before 'fi'"},
+		{"if alarm_a then else action_c fi", 17, "missing statement between 'then' and 'else'"},
+		{"if alarm_a else action_c fi", 12, "'else' without a matching 'if ... then'"},
+		{"if alarm_a | then action_b fi", 12, "missing expression after '|'"},
+		{"f(if alarm_a then action_b)", 3, "'if' is missing 'fi'"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.stmt, func(t *testing.T) {
+			err := provider.ValidateOpStatement(tc.stmt)
+			var syntaxErr *provider.OpSyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Expected a syntax error, got: %v", err)
+			}
+			if syntaxErr.Column != tc.column || !strings.Contains(syntaxErr.Message, tc.message) {
+				t.Errorf("Expected %q at column %d, got: %s", tc.message, tc.column, err)
+			}
+		})
+	}
+}
+
+func TestCommandAttributeValidation(t *testing.T) {
+	p := provider.New("dev")()
+	for _, attr := range []struct{ resource, key string }{
+		{"shoreline_action", "command"},
+		{"shoreline_action", "resource_query"},
+		{"shoreline_alarm", "fire_query"},
+		{"shoreline_alarm", "clear_query"},
+		{"shoreline_bot", "command"},
+		{"shoreline_metric", "value"},
+	} {
+		sch := p.ResourcesMap[attr.resource].Schema[attr.key]
+		if sch == nil || sch.ValidateFunc == nil {
+			t.Errorf("Expected %s.%s to be validated", attr.resource, attr.key)
+			continue
+		}
+		if _, errs := sch.ValidateFunc("host | pod |", attr.key); len(errs) != 1 || !strings.Contains(errs[0].Error(), "column 12") {
+			t.Errorf("Expected a syntax error with the column for %s.%s, got: %v", attr.resource, attr.key, errs)
+		}
+		if _, errs := sch.ValidateFunc("host | pod", attr.key); len(errs) != 0 {
+			t.Errorf("Expected no errors for %s.%s, got: %v", attr.resource, attr.key, errs)
+		}
+	}
+}
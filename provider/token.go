@@ -0,0 +1,170 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Fraction of an access token's lifetime after which it's refreshed (ahead of the actual expiry).
+const defaultTokenRefreshFraction = 0.8
+
+// TokenManager owns the access token for an API server, and is shared by all of the
+// (parallel) resource operations of a provider instance.
+// Only one refresh is in flight at a time, and the expiry is taken from the token's 'exp' claim.
+type TokenManager struct {
+	refreshFraction float64
+	// exchanges the refresh token for an access token (and possibly a new, rotated, refresh token)
+	fetch func(ctx context.Context, refreshToken string) (access string, refresh string, err error)
+	// called (outside the lock) after the API server rotated the refresh token
+	onRotate func(ctx context.Context, oldToken string, newToken string)
+
+	mu          sync.Mutex
+	apiToken    string
+	accessToken string
+	refreshAt   time.Time
+	expiresAt   time.Time
+	inflight    *tokenRefresh
+}
+
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+func NewTokenManager(apiToken string, refreshFraction float64, fetch func(ctx context.Context, refreshToken string) (string, string, error)) *TokenManager {
+	if refreshFraction <= 0 || refreshFraction > 1 {
+		refreshFraction = defaultTokenRefreshFraction
+	}
+	return &TokenManager{
+		apiToken:        apiToken,
+		refreshFraction: refreshFraction,
+		fetch:           fetch,
+	}
+}
+
+// AccessToken returns a valid access token, refreshing it if it's (nearly) expired.
+func (tm *TokenManager) AccessToken(ctx context.Context) (string, error) {
+	apiToken := tm.ApiToken()
+	decoded := DecodeAuthToken(apiToken)
+	if decoded == nil {
+		return "", fmt.Errorf("Invalid auth token.")
+	}
+	if decoded.Type == "access" {
+		// not a refresh token, so use it directly (until it expires)
+		if decoded.Expiry <= time.Now().Unix() {
+			return "", fmt.Errorf("Auth token is an access token (not refresh) but has expired.")
+		}
+		return apiToken, nil
+	}
+
+	for {
+		tm.mu.Lock()
+		now := time.Now()
+		if tm.accessToken != "" && now.Before(tm.refreshAt) {
+			token := tm.accessToken
+			tm.mu.Unlock()
+			return token, nil
+		}
+		if flight := tm.inflight; flight != nil {
+			stillValid := tm.accessToken != "" && now.Before(tm.expiresAt)
+			token := tm.accessToken
+			tm.mu.Unlock()
+			if stillValid {
+				// due for an early refresh, which another request is already doing
+				return token, nil
+			}
+			select {
+			case <-flight.done:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			if flight.err != nil && (errors.Is(flight.err, context.Canceled) || errors.Is(flight.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+				// the refreshing request was cancelled, but this one wasn't, so try again
+				continue
+			}
+			return flight.token, flight.err
+		}
+		flight := &tokenRefresh{done: make(chan struct{})}
+		tm.inflight = flight
+		refreshToken := tm.apiToken
+		tm.mu.Unlock()
+
+		var rotated string
+		flight.token, rotated, flight.err = tm.fetch(ctx, refreshToken)
+
+		if flight.err != nil {
+			logWarn(ctx, logAuth, "Failed to refresh the access token", map[string]interface{}{logFieldError: flight.err.Error()})
+		} else {
+			logDebug(ctx, logAuth, "Refreshed the access token", map[string]interface{}{"rotated": rotated != "" && rotated != refreshToken})
+		}
+
+		tm.mu.Lock()
+		if flight.err == nil {
+			tm.setAccessToken(flight.token, now)
+			if rotated != "" && rotated != refreshToken {
+				// the old refresh token may no longer be accepted
+				registerSecret(rotated)
+				tm.apiToken = rotated
+			} else {
+				rotated = ""
+			}
+		}
+		tm.inflight = nil
+		onRotate := tm.onRotate
+		tm.mu.Unlock()
+		close(flight.done)
+		if rotated != "" && onRotate != nil {
+			onRotate(ctx, refreshToken, rotated)
+		}
+		return flight.token, flight.err
+	}
+}
+
+// ApiToken is the current refresh (or access) token, which the API server may have rotated.
+func (tm *TokenManager) ApiToken() string {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return tm.apiToken
+}
+
+// OnRotate registers a callback for when the API server rotates the refresh token,
+// e.g. to save the new one.
+func (tm *TokenManager) OnRotate(fn func(ctx context.Context, oldToken string, newToken string)) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.onRotate = fn
+}
+
+// Invalidate drops the access token after it was rejected (e.g. a 401), so the next request refreshes it.
+// Only the given token is dropped, in case a parallel request already replaced it.
+func (tm *TokenManager) Invalidate(token string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.accessToken == token {
+		tm.accessToken = ""
+	}
+}
+
+// setAccessToken records a fresh token, with the expiry from its 'exp' claim.
+// The lifetime is measured from 'issued' (before the request), to allow for network delays.
+func (tm *TokenManager) setAccessToken(token string, issued time.Time) {
+	registerSecret(token)
+	tm.accessToken = token
+	tm.expiresAt = issued.Add(accessTokenTTL * time.Second)
+	decoded := DecodeAuthToken(token)
+	if decoded != nil && decoded.Expiry > 0 {
+		tm.expiresAt = time.Unix(decoded.Expiry, 0)
+	}
+	lifetime := tm.expiresAt.Sub(issued)
+	if lifetime < 0 {
+		lifetime = 0
+	}
+	tm.refreshAt = issued.Add(time.Duration(float64(lifetime) * tm.refreshFraction))
+}
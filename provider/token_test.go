@@ -0,0 +1,199 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"shoreline.io/terraform/terraform-provider-shoreline/provider/mockbackend"
+)
+
+// testTokenManager hands out access tokens (from the mock backend) with the given lifetime.
+func testTokenManager(t *testing.T, fraction float64, lifetime time.Duration, fetches *int32, gate <-chan struct{}) *TokenManager {
+	if mockServer == nil {
+		t.Skip("SHORELINE_URL is set, skipping mock backend tests")
+	}
+	return NewTokenManager(mockServer.RefreshToken(), fraction, func(ctx context.Context, refreshToken string) (string, string, error) {
+		atomic.AddInt32(fetches, 1)
+		if gate != nil {
+			<-gate
+		}
+		return mockServer.NewToken("access", "test_customer", "test_user@shoreline.io", time.Now().Add(lifetime)), "", nil
+	})
+}
+
+func testMockRefreshCount() int {
+	count := 0
+	for _, req := range mockServer.Requests() {
+		if req.Path == "/v1/token/refresh" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestTokenManagerSingleFlight(t *testing.T) {
+	var fetches int32
+	gate := make(chan struct{})
+	tm := testTokenManager(t, 0.8, time.Hour, &fetches, gate)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 20)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], _ = tm.AccessToken(context.Background())
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	if fetches != 1 {
+		t.Errorf("Expected a single token refresh, got %d", fetches)
+	}
+	for _, token := range tokens {
+		if token == "" || token != tokens[0] {
+			t.Fatalf("Expected all callers to share one access token, got: %v", tokens)
+		}
+	}
+}
+
+func TestTokenManagerExpiryFromClaim(t *testing.T) {
+	var fetches int32
+	tm := testTokenManager(t, 0.5, 100*time.Second, &fetches, nil)
+
+	start := time.Now()
+	first, err := tm.AccessToken(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get an access token: %s", err)
+	}
+	tm.mu.Lock()
+	refreshIn := tm.refreshAt.Sub(start)
+	tm.mu.Unlock()
+	// half of the 100s lifetime (the 'exp' claim has a 1s granularity)
+	if refreshIn < 48*time.Second || refreshIn > 51*time.Second {
+		t.Errorf("Expected a refresh after half the token lifetime, got %s", refreshIn)
+	}
+
+	if second, _ := tm.AccessToken(context.Background()); second != first || fetches != 1 {
+		t.Errorf("Expected the access token to be reused, got %d refreshes", fetches)
+	}
+
+	// once due, it's refreshed
+	tm.mu.Lock()
+	tm.refreshAt = time.Now().Add(-time.Second)
+	tm.mu.Unlock()
+	if _, err := tm.AccessToken(context.Background()); err != nil || fetches != 2 {
+		t.Errorf("Expected the access token to be refreshed (err: %v), got %d refreshes", err, fetches)
+	}
+}
+
+func TestTokenManagerEarlyRefreshKeepsValidToken(t *testing.T) {
+	var fetches int32
+	gate := make(chan struct{})
+	tm := testTokenManager(t, 0.8, time.Hour, &fetches, gate)
+	close(gate)
+	first, _ := tm.AccessToken(context.Background())
+
+	// due for an early refresh, which is blocked
+	gate = make(chan struct{})
+	tm.fetch = func(ctx context.Context, refreshToken string) (string, string, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-gate
+		return mockServer.NewToken("access", "test_customer", "test_user@shoreline.io", time.Now().Add(2*time.Hour)), "", nil
+	}
+	tm.mu.Lock()
+	tm.refreshAt = time.Now().Add(-time.Second)
+	tm.mu.Unlock()
+
+	refreshed := make(chan string)
+	go func() {
+		token, _ := tm.AccessToken(context.Background())
+		refreshed <- token
+	}()
+	for atomic.LoadInt32(&fetches) != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if token, err := tm.AccessToken(context.Background()); err != nil || token != first {
+		t.Errorf("Expected the still valid token during an early refresh, got '%s' (err: %v)", token, err)
+	}
+	close(gate)
+	if token := <-refreshed; token == first {
+		t.Errorf("Expected a new access token from the refresh")
+	}
+}
+
+func TestTokenManagerInvalidate(t *testing.T) {
+	var fetches int32
+	tm := testTokenManager(t, 0.8, time.Hour, &fetches, nil)
+	first, _ := tm.AccessToken(context.Background())
+
+	tm.Invalidate("some other token")
+	if token, _ := tm.AccessToken(context.Background()); token != first || fetches != 1 {
+		t.Errorf("Expected a non-matching invalidation to be ignored, got %d refreshes", fetches)
+	}
+	tm.Invalidate(first)
+	if _, err := tm.AccessToken(context.Background()); err != nil || fetches != 2 {
+		t.Errorf("Expected the invalidated token to be refreshed (err: %v), got %d refreshes", err, fetches)
+	}
+}
+
+func TestMockTokenSharedAcrossCommands(t *testing.T) {
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	mockServer.ResetRequests()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := runOpCommand(context.Background(), client, "backend_version", false); err != nil {
+				t.Errorf("Failed to run command: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if count := testMockRefreshCount(); count != 1 {
+		t.Errorf("Expected a single token refresh for parallel commands, got %d", count)
+	}
+}
+
+func TestMockTokenRefreshOnUnauthorized(t *testing.T) {
+	_, meta := testMockProvider(t)
+	client := meta.(*apiClient)
+	if _, err := runOpCommand(context.Background(), client, "backend_version", false); err != nil {
+		t.Fatalf("Failed to run command: %s", err)
+	}
+	mockServer.ResetRequests()
+	mockServer.InjectFault(mockbackend.Fault{Path: "/v1/execute", Status: 401, Body: "expired access token"})
+	defer mockServer.ResetFaults()
+
+	if _, err := runOpCommand(context.Background(), client, "backend_version", false); err != nil {
+		t.Fatalf("Expected the command to succeed after a token refresh, got: %s", err)
+	}
+	if count := testMockRefreshCount(); count != 1 {
+		t.Errorf("Expected the rejected token to be refreshed once, got %d", count)
+	}
+	if count := testMockExecuteCount(); count != 2 {
+		t.Errorf("Expected the command to be re-sent with the new token, got %d execute calls", count)
+	}
+	keys := map[string]bool{}
+	for _, req := range mockServer.Requests() {
+		if req.IdempotencyKey == "" {
+			t.Errorf("Expected an idempotency key for %s", req.Path)
+		}
+		keys[req.IdempotencyKey] = true
+	}
+	if len(keys) != 2 {
+		t.Errorf("Expected the token refresh to have its own idempotency key (apart from the command's), got %d keys", len(keys))
+	}
+}
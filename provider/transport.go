@@ -0,0 +1,82 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// HttpClientConfig holds the transport settings used for the API server,
+// as well as for presigned file upload/download urls.
+type HttpClientConfig struct {
+	CaCertFile     string // extra (PEM) CA certificates, e.g. for a private CA
+	ClientCertFile string // client certificate for mTLS
+	ClientKeyFile  string
+	ProxyUrl       string // defaults to the HTTPS_PROXY/HTTP_PROXY/NO_PROXY env vars
+	RequestTimeout time.Duration
+}
+
+// NewHttpClient builds an http.Client from the provider's transport settings.
+func NewHttpClient(conf HttpClientConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if conf.CaCertFile != "" {
+		pem, err := os.ReadFile(conf.CaCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't read CA certificate file '%s': %s", conf.CaCertFile, err.Error())
+		}
+		// add to the system CAs, so that e.g. presigned (cloud storage) urls still work
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No PEM encoded certificates in CA certificate file '%s'", conf.CaCertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if conf.ClientCertFile != "" || conf.ClientKeyFile != "" {
+		if conf.ClientCertFile == "" || conf.ClientKeyFile == "" {
+			return nil, fmt.Errorf("Both a client certificate file and a client key file are required for mTLS")
+		}
+		cert, err := tls.LoadX509KeyPair(conf.ClientCertFile, conf.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't load client certificate '%s' (key '%s'): %s", conf.ClientCertFile, conf.ClientKeyFile, err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if conf.ProxyUrl != "" {
+		proxy, err := url.Parse(conf.ProxyUrl)
+		if err != nil || proxy.Scheme == "" || proxy.Host == "" {
+			return nil, fmt.Errorf("Invalid proxy url '%s'", conf.ProxyUrl)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   conf.RequestTimeout,
+	}, nil
+}
+
+// JoinApiBasePath appends an (optional) base path, e.g. for a reverse proxy, to the API server url.
+func JoinApiBasePath(apiUrl string, basePath string) string {
+	apiUrl = strings.TrimRight(apiUrl, "/")
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return apiUrl
+	}
+	return apiUrl + "/" + basePath
+}
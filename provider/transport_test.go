@@ -0,0 +1,136 @@
+// Copyright 2021, Shoreline Software Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHttpClientCustomCa(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	plain, err := NewHttpClient(HttpClientConfig{RequestTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Failed to build http client: %s", err)
+	}
+	if _, err := plain.Get(server.URL); err == nil {
+		t.Errorf("Expected an unknown CA to be rejected")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPem, 0600); err != nil {
+		t.Fatalf("Failed to write CA file: %s", err)
+	}
+	withCa, err := NewHttpClient(HttpClientConfig{CaCertFile: caFile, RequestTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Failed to build http client with CA: %s", err)
+	}
+	resp, err := withCa.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the custom CA to be trusted, got: %s", err)
+	}
+	resp.Body.Close()
+}
+
+func TestHttpClientConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPem := filepath.Join(dir, "not.pem")
+	os.WriteFile(notPem, []byte("not a certificate"), 0600)
+
+	tests := []struct {
+		name string
+		conf HttpClientConfig
+		want string
+	}{
+		{"missing ca file", HttpClientConfig{CaCertFile: filepath.Join(dir, "missing.pem")}, "Couldn't read CA certificate file"},
+		{"invalid ca file", HttpClientConfig{CaCertFile: notPem}, "No PEM encoded certificates"},
+		{"cert without key", HttpClientConfig{ClientCertFile: notPem}, "Both a client certificate file and a client key file"},
+		{"invalid client cert", HttpClientConfig{ClientCertFile: notPem, ClientKeyFile: notPem}, "Couldn't load client certificate"},
+		{"invalid proxy", HttpClientConfig{ProxyUrl: "not a url"}, "Invalid proxy url"},
+	}
+	for _, tt := range tests {
+		_, err := NewHttpClient(tt.conf)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing '%s', got: %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestHttpClientRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client, err := NewHttpClient(HttpClientConfig{RequestTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to build http client: %s", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Errorf("Expected the request to time out")
+	}
+}
+
+// The provider talks to a (fake) host through a proxy, which strips the API base path
+// and forwards to the mock backend.
+func TestMockProxyAndApiBasePath(t *testing.T) {
+	if mockServer == nil {
+		t.Skip("SHORELINE_URL is set, skipping mock backend tests")
+	}
+	target, _ := url.Parse(mockServer.URL)
+	forward := httputil.NewSingleHostReverseProxy(target)
+	var mu sync.Mutex
+	proxied := []string{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.Host+r.URL.Path)
+		mu.Unlock()
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/shoreline")
+		forward.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	p, meta := testMockProviderWithConfig(t, map[string]interface{}{
+		"url":           "http://shoreline.example.com",
+		"api_base_path": "/shoreline/",
+		"proxy_url":     proxy.URL,
+	})
+	if _, err := runOpCommand(context.Background(), meta.(*apiClient), "backend_version", false); err != nil {
+		t.Fatalf("Failed to run command through the proxy: %s", err)
+	}
+	name := RandomAlphaPrefix(5) + "_action"
+	testMockCreate(t, p, meta, "shoreline_action", map[string]interface{}{
+		"name":    name,
+		"command": "`hostname`",
+	})
+	if _, _, found := mockServer.Object(name); !found {
+		t.Errorf("Expected action '%s' to be created through the proxy", name)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(proxied) == 0 || proxied[0] != "shoreline.example.com/shoreline/v1/token/refresh" {
+		t.Errorf("Expected the token refresh to go through the proxy with the base path, got: %v", proxied)
+	}
+	for _, path := range proxied {
+		if !strings.HasPrefix(path, "shoreline.example.com/shoreline/v1/") {
+			t.Errorf("Unexpected proxied request: %s", path)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
@@ -35,3 +36,197 @@ func getExtraShorelineNotebookDataFields(data interface{}) []string {
 	}
 	return extraKeys
 }
+
+// validateOpCommand checks the syntax of 'command' attributes (op statements) at plan time,
+// instead of failing part way through an apply.
+func validateOpCommand(val interface{}, key string) (warns []string, errs []error) {
+	stmt, isStr := val.(string)
+	if !isStr {
+		return
+	}
+	if err := ValidateOpStatement(stmt); err != nil {
+		errs = append(errs, fmt.Errorf("%q is not a valid op statement, %s", key, err.Error()))
+	}
+	return
+}
+
+// the resource types a resource query may select (and switch to, e.g. `host | pod`)
+var resourceQueryTypes = map[string]bool{
+	"host": true, "hosts": true, "pod": true, "pods": true, "container": true, "containers": true,
+}
+
+// the operators of a resource query's tag filters, e.g. `app="payments"` or `app=~"pay.*"`
+var resourceQueryFilterOperators = map[string]bool{"=": true, "!=": true, "=~": true, "!~": true}
+
+// validateResourceQuery checks that a data source's query only selects resources. Data sources are read
+// during plans, so only resource types (e.g. `hosts`) followed by tag, regex and `limit=` filters are
+// allowed, rather than anything that could run something (actions, shell commands, several statements).
+func validateResourceQuery(val interface{}, key string) (warns []string, errs []error) {
+	warns, errs = validateOpCommand(val, key)
+	stmt, isStr := val.(string)
+	if !isStr || len(errs) > 0 {
+		return
+	}
+	tokens, _ := lexOpStatement(stmt)
+	if len(tokens) == 0 {
+		errs = append(errs, fmt.Errorf("%q must not be empty", key))
+		return
+	}
+	stages := [][]opToken{{}}
+	for _, tok := range tokens {
+		switch tok.kind {
+		case opTokenShell:
+			errs = append(errs, fmt.Errorf("%q must be a resource query, got a shell command at column %d", key, tok.column))
+			return
+		case opTokenSeparator:
+			errs = append(errs, fmt.Errorf("%q must be a single resource query, got ';' at column %d", key, tok.column))
+			return
+		case opTokenPipe:
+			stages = append(stages, []opToken{})
+		default:
+			stages[len(stages)-1] = append(stages[len(stages)-1], tok)
+		}
+	}
+	for i, stage := range stages {
+		if err := checkResourceQueryStage(stage, i == 0); err != "" {
+			errs = append(errs, fmt.Errorf("%q must be a resource query (a resource type followed by tag, regex or 'limit=' filters), %s", key, err))
+			return
+		}
+	}
+	return
+}
+
+// checkResourceQueryStage checks one ('|' separated) stage of a resource query, returning what's wrong with it (if anything).
+func checkResourceQueryStage(stage []opToken, first bool) string {
+	if len(stage) == 0 {
+		return "got an empty stage"
+	}
+	head := stage[0]
+	if head.kind != opTokenWord {
+		return fmt.Sprintf("got %s at column %d", head.text, head.column)
+	}
+	if len(stage) == 1 && resourceQueryTypes[head.text] {
+		return ""
+	}
+	if first {
+		return fmt.Sprintf("got '%s' at column %d, instead of a resource type (e.g. 'hosts')", head.text, head.column)
+	}
+	if len(stage) < 3 || stage[1].kind != opTokenOperator || !resourceQueryFilterOperators[stage[1].text] {
+		return fmt.Sprintf("got '%s' at column %d, instead of a filter (e.g. app=\"payments\")", head.text, head.column)
+	}
+	value := stage[2:]
+	if head.text == "limit" {
+		if stage[1].text != "=" || len(value) != 1 || value[0].kind != opTokenWord {
+			return fmt.Sprintf("got an invalid limit at column %d", head.column)
+		}
+		return ""
+	}
+	// a single value, or a list of them, e.g. namespace=["a", "b"]
+	if len(value) > 1 {
+		if value[0].text != "[" || value[len(value)-1].text != "]" {
+			return fmt.Sprintf("got an invalid filter value at column %d", value[0].column)
+		}
+		for j, tok := range value[1 : len(value)-1] {
+			if (j%2 == 0 && tok.kind != opTokenString && tok.kind != opTokenWord) || (j%2 == 1 && tok.kind != opTokenComma) {
+				return fmt.Sprintf("got an invalid filter value at column %d", tok.column)
+			}
+		}
+		return ""
+	}
+	if value[0].kind != opTokenString && value[0].kind != opTokenWord {
+		return fmt.Sprintf("got an invalid filter value at column %d", value[0].column)
+	}
+	return ""
+}
+
+// the functions of a metric query (after the metric), e.g. `cpu_usage | window(60s) | mean(60)`
+var metricQueryFunctions = map[string]bool{"window": true, "mean": true, "min": true, "max": true, "sum": true, "count": true}
+
+// validateMetricQuery checks a metric query: a metric name (e.g. `cpu_usage`), or a metric
+// (or `metric_query(metric_names="...")`) followed by tag filters and functions like `window(60s)`.
+// Like validateResourceQuery(), anything that could run something is rejected, as it's read during plans.
+func validateMetricQuery(val interface{}, key string) (warns []string, errs []error) {
+	warns, errs = validateOpCommand(val, key)
+	stmt, isStr := val.(string)
+	if !isStr || len(errs) > 0 {
+		return
+	}
+	tokens, _ := lexOpStatement(stmt)
+	if len(tokens) == 0 {
+		errs = append(errs, fmt.Errorf("%q must not be empty", key))
+		return
+	}
+	stages := [][]opToken{{}}
+	for _, tok := range tokens {
+		if tok.kind == opTokenPipe {
+			stages = append(stages, []opToken{})
+		} else {
+			stages[len(stages)-1] = append(stages[len(stages)-1], tok)
+		}
+	}
+	for i, stage := range stages {
+		if err := checkMetricQueryStage(stage, i == 0); err != "" {
+			errs = append(errs, fmt.Errorf("%q must be a metric query (a metric followed by tag filters or functions like 'window(60s)'), %s", key, err))
+			return
+		}
+	}
+	return
+}
+
+// checkMetricQueryStage checks one ('|' separated) stage of a metric query, returning what's wrong with it (if anything).
+func checkMetricQueryStage(stage []opToken, first bool) string {
+	if len(stage) == 0 {
+		return "got an empty stage"
+	}
+	head := stage[0]
+	if head.kind != opTokenWord {
+		return fmt.Sprintf("got %s at column %d", head.text, head.column)
+	}
+	if first && len(stage) == 1 && opIdentRegex.MatchString(head.text) && !resourceQueryTypes[head.text] {
+		return ""
+	}
+	isCall := len(stage) >= 3 && stage[1].text == "(" && stage[len(stage)-1].text == ")"
+	switch {
+	case first && head.text == "metric_query" && isCall:
+		// metric_query(metric_names = "m")
+		args := stage[2 : len(stage)-1]
+		if len(args) != 3 || args[0].text != "metric_names" || args[1].text != "=" || args[2].kind != opTokenString {
+			return fmt.Sprintf("got invalid metric_query arguments at column %d, instead of metric_names=\"...\"", stage[2].column)
+		}
+		return ""
+	case first:
+		return fmt.Sprintf("got '%s' at column %d, instead of a metric (e.g. 'cpu_usage')", head.text, head.column)
+	case metricQueryFunctions[head.text] && isCall:
+		// e.g. window(60s), with a single (duration or count) argument
+		args := stage[2 : len(stage)-1]
+		if len(args) != 1 || args[0].kind != opTokenWord {
+			return fmt.Sprintf("got invalid %s arguments at column %d", head.text, stage[2].column)
+		}
+		return ""
+	}
+	return checkResourceQueryStage(stage, false)
+}
+
+// validateMetricQueryWindow checks a metric query window, e.g. "1h" (see timeSuffixToIntSec()).
+func validateMetricQueryWindow(val interface{}, key string) (warns []string, errs []error) {
+	str, isStr := val.(string)
+	if !isStr {
+		return
+	}
+	if !opDurationRegex.MatchString(str) || timeSuffixToIntSec(str) <= 0 {
+		errs = append(errs, fmt.Errorf("%q must be a positive duration, e.g. \"30s\", \"5m\" or \"1h\", got: %s", key, str))
+	}
+	return
+}
+
+// validateMetricQueryTime checks a metric query time: "now", a time relative to now (e.g. "-7d") or an RFC3339 time.
+func validateMetricQueryTime(val interface{}, key string) (warns []string, errs []error) {
+	str, isStr := val.(string)
+	if !isStr {
+		return
+	}
+	if _, err := parseMetricQueryTime(str, time.Now()); err != nil {
+		errs = append(errs, fmt.Errorf("%q %s", key, err.Error()))
+	}
+	return
+}